@@ -15,6 +15,12 @@ import (
 )
 
 // A Word represents a single digit of a multi-precision unsigned integer.
+//
+// A Word has the size of a machine word, like uint, and is the limb type
+// used by Nat. It's also used in the public API, for example with Select.
+//
+// The size of a Word in bits is WordBits. Vectors of Words can be manipulated
+// directly, in constant-time, with AddWords, SubWords, and MulAddWord.
 type Word uint
 
 const (
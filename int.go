@@ -2,6 +2,7 @@ package saferith
 
 import (
 	"errors"
+	"hash"
 	"math/big"
 	"math/bits"
 )
@@ -45,6 +46,19 @@ func (i *Int) MarshalBinary() ([]byte, error) {
 	return out, nil
 }
 
+// WriteDigest writes an unambiguous encoding of this Int into a hash.
+//
+// This is like Nat.WriteDigest, except that a different tag is used, and the
+// sign is included as an extra byte before the length prefixed absolute value.
+// Negative zero is written with a positive sign, so that equal values produce the
+// same encoding.
+//
+// This doesn't leak the value of z, or its sign, only its announced length.
+func (z *Int) WriteDigest(h hash.Hash) {
+	sign := z.sign & (1 ^ z.abs.EqZero())
+	h.Write(z.abs.AppendLengthPrefixed([]byte{digestTagInt, byte(sign)}))
+}
+
 // UnmarshalBinary implements encoding.BinaryUnmarshaler.
 // Returns an error when the length of data is 0,
 // since we always expect the first byte to encode the sign.
@@ -112,20 +126,59 @@ func (z *Int) Big() *big.Int {
 }
 
 // Resize adjust the announced size of this number, possibly truncating the absolute value.
+//
+// If the absolute value gets truncated to zero, the sign is cleared, to avoid
+// producing a negative zero.
 func (z *Int) Resize(cap int) *Int {
 	z.abs.Resize(cap)
+	z.sign &= 1 ^ z.abs.EqZero()
 	return z
 }
 
+// SetString modifies the value of z to hold a signed string in a given base, returning z
+//
+// The string can start with an optional '-' or '+' sign, followed by the absolute
+// value, following the same rules as Nat.SetString. In particular, a base of 0
+// will infer the base from a prefix after the sign, like with big.Int.
+//
+// Negative zero is normalized to positive zero.
+//
+// If the string is invalid, the value of z will be undefined, and an error will be returned.
+func (z *Int) SetString(s string, base int) (*Int, error) {
+	sign := Choice(0)
+	if len(s) > 0 && (s[0] == '-' || s[0] == '+') {
+		if s[0] == '-' {
+			sign = 1
+		}
+		s = s[1:]
+	}
+	if _, err := z.abs.SetString(s, base); err != nil {
+		return nil, err
+	}
+	z.sign = sign & (1 ^ z.abs.EqZero())
+	return z, nil
+}
+
 // String formats this number as a signed hex string.
 //
 // This isn't a format that Int knows how to parse. This function exists mainly
-// to help debugging, and whatnot.
+// to help debugging, and whatnot. Text should be preferred for displaying numbers.
 func (z *Int) String() string {
 	sign := ctIfElse(z.sign, Word('-'), Word('+'))
 	return string(rune(sign)) + z.abs.String()
 }
 
+// Text formats this number as a signed string in a given base.
+//
+// The base must be between 2 and 36. Letters are used for digits above 9, and
+// a leading '-' is only present for negative numbers. Negative zero is formatted as "0".
+// This is the same format as big.Int.Text, and can be parsed back with SetString.
+//
+// This function will leak the value of z, and is intended for display, and not secret data.
+func (z *Int) Text(base int) string {
+	return z.Big().Text(base)
+}
+
 // Eq checks if this Int has the same value as another Int.
 //
 // Note that negative zero and positive zero are the same number.
@@ -142,11 +195,34 @@ func (z *Int) Abs() *Nat {
 	return new(Nat).SetNat(&z.abs)
 }
 
+// AbsNat returns the absolute value of this Int, along with whether or not it's negative.
+//
+// This is like calling Abs and IsNegative, but in a single call. The sign of
+// a negative zero is reported as positive.
+//
+// This doesn't leak the sign, or the value, of z.
+func (z *Int) AbsNat() (*Nat, Choice) {
+	return z.Abs(), z.sign & (1 ^ z.abs.EqZero())
+}
+
 // IsNegative checks if this value is negative
 func (z *Int) IsNegative() Choice {
 	return z.sign
 }
 
+// IsZero checks if this value is zero.
+//
+// Note that negative zero and positive zero are the same number, so both are
+// considered to be zero.
+func (z *Int) IsZero() Choice {
+	return z.abs.EqZero()
+}
+
+// IsOne checks if this value is exactly one, i.e. positive with an absolute value of one.
+func (z *Int) IsOne() Choice {
+	return (1 ^ z.sign) & z.abs.EqOne()
+}
+
 // AnnouncedLen returns the announced size of this int's absolute value.
 //
 // See Nat.AnnouncedLen
@@ -163,11 +239,12 @@ func (z *Int) TrueLen() int {
 	return z.abs.TrueLen()
 }
 
-// Neg calculates z <- -x.
+// Neg calculates z <- -z, if doit is 1, returning z.
 //
-// The result has the same announced size.
+// The result has the same announced size. Negating zero leaves it positive,
+// so this never produces a negative zero.
 func (z *Int) Neg(doit Choice) *Int {
-	z.sign ^= doit
+	z.sign = (z.sign ^ doit) & (1 ^ z.abs.EqZero())
 	return z
 }
 
@@ -177,9 +254,51 @@ func (z *Int) SetInt(x *Int) *Int {
 	return z
 }
 
+// AddChecked calculates z <- x + y, also returning whether or not the sum overflowed.
+//
+// This is like Add, except that the sum is first calculated exactly, and then
+// truncated to cap bits. The returned Choice is 1 if the absolute value of the
+// true sum didn't fit in cap bits. In that case, z keeps the sign of the true sum,
+// but its absolute value is truncated, like with Mul. If the truncated value is
+// zero, then z is positive, so this never produces a negative zero.
+//
+// If cap < 0, cap gets set to max(x.AnnouncedLen(), y.AnnouncedLen()) + 1,
+// which can never overflow.
+func (z *Int) AddChecked(x *Int, y *Int, cap int) (*Int, Choice) {
+	wide := x.abs.maxAnnounced(&y.abs) + 1
+	if cap < 0 {
+		cap = wide
+	}
+	if cap > wide {
+		wide = cap
+	}
+	z.Add(x, y, wide)
+	overflow := bitsAbove(z.abs.limbs, cap)
+	z.Resize(cap)
+	return z, overflow
+}
+
+// bitsAbove checks if any bits at or beyond position cap are set in limbs, returning 1 if so.
+//
+// This doesn't leak the value of the limbs, only their number, and cap.
+func bitsAbove(limbs []Word, cap int) Choice {
+	var high Word
+	// LEAK: the value of cap, and the number of limbs
+	// OK: these are public
+	i := cap / _W
+	if i < len(limbs) {
+		high = limbs[i] >> (uint(cap) % _W)
+		for _, limb := range limbs[i+1:] {
+			high |= limb
+		}
+	}
+	return 1 ^ ctEq(high, 0)
+}
+
 // Mul calculates z <- x * y, returning z.
 //
 // This will truncate the resulting absolute value, based on the bit capacity passed in.
+// The sign is always that of the true product, even if the absolute value was truncated.
 //
 // If cap < 0, then capacity is x.AnnouncedLen() + y.AnnouncedLen().
 func (z *Int) Mul(x *Int, y *Int, cap int) *Int {
@@ -189,6 +308,58 @@ func (z *Int) Mul(x *Int, y *Int, cap int) *Int {
 	return z
 }
 
+// MulChecked calculates z <- x * y, also returning whether or not the product overflowed.
+//
+// This is like Mul, except that the product is first calculated exactly, and then
+// truncated to cap bits. The returned Choice is 1 if the absolute value of the
+// true product didn't fit in cap bits. Like with Mul, z has the sign of the
+// true product, even if its absolute value was truncated, except that a value
+// truncated to zero is positive, so this never produces a negative zero.
+//
+// If cap < 0, then capacity is x.AnnouncedLen() + y.AnnouncedLen(), which can never overflow.
+func (z *Int) MulChecked(x *Int, y *Int, cap int) (*Int, Choice) {
+	wide := x.abs.announced + y.abs.announced
+	if cap < 0 {
+		cap = wide
+	}
+	if cap > wide {
+		wide = cap
+	}
+	z.Mul(x, y, wide)
+	overflow := bitsAbove(z.abs.limbs, cap)
+	z.Resize(cap)
+	return z, overflow
+}
+
+// SubNat calculates z <- x - y, as a signed integer.
+//
+// Unlike Nat.Sub, the result doesn't wrap around when y > x, but is instead negative.
+//
+// This doesn't leak which of x and y is larger, only their announced lengths.
+//
+// The cap determines the number of bits to use for the absolute value of the result.
+//
+// If cap < 0, cap gets set to max(x.AnnouncedLen(), y.AnnouncedLen())
+func (z *Int) SubNat(x *Nat, y *Nat, cap int) *Int {
+	maxBits := x.maxAnnounced(y)
+	if cap < 0 {
+		cap = maxBits
+	}
+	xLimbs := x.resizedLimbs(maxBits)
+	yLimbs := y.resizedLimbs(maxBits)
+	diff := make([]Word, len(xLimbs))
+	// If we borrowed, then the result is negative, and we hold its two's complement.
+	borrow := subVV(diff, xLimbs, yLimbs)
+	negateTwos(Choice(borrow), diff)
+	z.abs.limbs = diff
+	z.abs.announced = maxBits
+	z.abs.reduced = nil
+	z.abs.Resize(cap)
+	// Truncation might have produced a zero, which we want to be positive.
+	z.sign = Choice(borrow) & (1 ^ z.abs.EqZero())
+	return z
+}
+
 // Mod calculates z mod M, handling negatives correctly.
 //
 // As indicated by the types, this function will return a number in the range 0..m-1.
@@ -199,13 +370,74 @@ func (z *Int) Mod(m *Modulus) *Nat {
 	return out
 }
 
+// AbsDiff calculates z <- |x - y|, as a natural number.
+//
+// The announced length of the result is max(x.AnnouncedLen(), y.AnnouncedLen()) + 1,
+// which is enough to hold the difference in every case.
+//
+// This doesn't leak the sign of x - y, nor the values of x and y.
+func (z *Nat) AbsDiff(x *Int, y *Int) *Nat {
+	negY := new(Int).SetInt(y).Neg(1)
+	diff := new(Int).Add(x, negY, -1)
+	return z.SetNat(&diff.abs)
+}
+
+// ModInt calculates z <- x mod m, handling negatives correctly, and returns z.
+//
+// This is like Mod, except that the result is kept as an Int, which will always
+// be non-negative, in the range 0..m-1.
+//
+// The capacity of the resulting number matches the capacity of the modulus.
+func (z *Int) ModInt(x *Int, m *Modulus) *Int {
+	z.abs.SetNat(x.Mod(m))
+	z.sign = 0
+	return z
+}
+
+// ModAdd calculates z <- x + y mod m, handling negatives correctly, and returns z.
+//
+// Like ModInt, the result is always non-negative, in the range 0..m-1.
+//
+// The capacity of the resulting number matches the capacity of the modulus.
+func (z *Int) ModAdd(x *Int, y *Int, m *Modulus) *Int {
+	z.abs.ModAdd(x.Mod(m), y.Mod(m), m)
+	z.sign = 0
+	return z
+}
+
+// ModSub calculates z <- x - y mod m, handling negatives correctly, and returns z.
+//
+// Like ModInt, the result is always non-negative, in the range 0..m-1.
+//
+// The capacity of the resulting number matches the capacity of the modulus.
+func (z *Int) ModSub(x *Int, y *Int, m *Modulus) *Int {
+	z.abs.ModSub(x.Mod(m), y.Mod(m), m)
+	z.sign = 0
+	return z
+}
+
+// ModMul calculates z <- x * y mod m, handling negatives correctly, and returns z.
+//
+// Like ModInt, the result is always non-negative, in the range 0..m-1.
+//
+// The capacity of the resulting number matches the capacity of the modulus.
+func (z *Int) ModMul(x *Int, y *Int, m *Modulus) *Int {
+	z.abs.ModMul(x.Mod(m), y.Mod(m), m)
+	z.sign = 0
+	return z
+}
+
 // SetModSymmetric takes a number x mod M, and returns a signed number centered around 0.
 //
 // This effectively takes numbers in the range:
 //    {0, .., m - 1}
 // And returns numbers in the range:
 //    {-(m - 1)/2, ..., 0, ..., (m - 1)/2}
-// In the case that m is even, there will simply be an extra negative number.
+// In the case that m is even, there will simply be an extra negative number,
+// giving the range:
+//    {-m/2, ..., 0, ..., m/2 - 1}
+//
+// Zero is always returned with a positive sign.
 func (z *Int) SetModSymmetric(x *Nat, m *Modulus) *Int {
 	z.abs.Mod(x, m)
 	negated := new(Nat).ModNeg(&z.abs, m)
@@ -213,20 +445,123 @@ func (z *Int) SetModSymmetric(x *Nat, m *Modulus) *Int {
 	negatedLeq := 1 ^ gt
 	// Always use the smaller value
 	z.abs.CondAssign(negatedLeq, negated)
-	// A negative modular number, by definition, will have it's negation <= itself
-	z.sign = negatedLeq
+	// A negative modular number, by definition, will have it's negation <= itself,
+	// with the exception of 0, which is its own negation.
+	z.sign = negatedLeq & (1 ^ z.abs.EqZero())
+	return z
+}
+
+// CenteredBytes encodes z compactly, as a signed number centered around 0 modulo m.
+//
+// z should be in the range produced by SetModSymmetric, as checked by CheckInRange.
+// The result uses (m.BitLen() + 7) / 8 bytes, holding z in big endian two's complement.
+// The width only depends on the modulus, which is public.
+//
+// SetCenteredBytes can be used to decode the result.
+//
+// This doesn't leak the value of z, or its sign, only its announced length.
+func (z *Int) CenteredBytes(m *Modulus) []byte {
+	length := (m.BitLen() + 7) / 8
+	out := new(Nat)
+	out.limbs = make([]Word, limbCount(8*length))
+	out.announced = 8 * length
+	toTwos(z.sign, z.abs.limbs, out.limbs)
+	return out.FillBytes(make([]byte, length))
+}
+
+// SetCenteredBytes decodes an Int encoded with CenteredBytes.
+//
+// The data is interpreted as a big endian two's complement number. The announced
+// length of the result is the size of m.
+//
+// This doesn't leak the value of data, only its length.
+func (z *Int) SetCenteredBytes(data []byte, m *Modulus) *Int {
+	z.setTwosBytes(data)
+	z.abs.Resize(m.BitLen())
+	return z
+}
+
+// setTwosBytes sets z to the big endian two's complement number in data.
+//
+// The announced length of the result is 8 * len(data).
+func (z *Int) setTwosBytes(data []byte) *Int {
+	width := 8 * len(data)
+	z.abs.SetBytes(data)
+	z.abs.limbs = z.abs.resizedLimbs(width)
+	var sign Choice
+	if len(data) > 0 {
+		sign = Choice(data[0] >> 7)
+	}
+	// Extend the sign into the remaining bits of the last limb, so that we can negate the full limbs
+	if len(z.abs.limbs) > 0 {
+		z.abs.limbs[len(z.abs.limbs)-1] |= ctIfElse(sign, ^limbMask(width), 0)
+	}
+	negateTwos(sign, z.abs.limbs)
+	z.sign = sign
+	z.abs.announced = len(z.abs.limbs) * _W
+	z.abs.reduced = nil
+	z.abs.Resize(width)
 	return z
 }
 
+// OrderedBytes encodes z in width bytes, such that comparing encodings as bytes
+// matches comparing the numbers they encode.
+//
+// This is useful for storing numbers as keys in databases which sort their keys
+// as bytes. The encoding is z in big endian two's complement, with the top
+// bit flipped, so that negative numbers come before positive ones.
+//
+// z should be in the range -2^(8 * width - 1) <= z < 2^(8 * width - 1),
+// otherwise the result silently wraps around.
+//
+// SetOrderedBytes can be used to decode the result.
+//
+// This doesn't leak the value of z, or its sign, only its announced length, and width.
+//
+// This panics if width < 0.
+func (z *Int) OrderedBytes(width int) []byte {
+	if width < 0 {
+		panic("OrderedBytes: negative width")
+	}
+	out := new(Nat)
+	out.limbs = make([]Word, limbCount(8*width))
+	out.announced = 8 * width
+	toTwos(z.sign, z.abs.limbs, out.limbs)
+	data := out.FillBytes(make([]byte, width))
+	if width > 0 {
+		data[0] ^= 0x80
+	}
+	return data
+}
+
+// SetOrderedBytes decodes an Int encoded with OrderedBytes, returning z.
+//
+// The announced length of the result is 8 * len(data).
+//
+// This doesn't leak the value of data, only its length.
+func (z *Int) SetOrderedBytes(data []byte) *Int {
+	twos := make([]byte, len(data))
+	copy(twos, data)
+	if len(twos) > 0 {
+		twos[0] ^= 0x80
+	}
+	return z.setTwosBytes(twos)
+}
+
 // CheckInRange checks whether or not this Int is in the range for SetModSymmetric.
+//
+// For an even modulus, -m/2 is in range, but m/2 isn't.
 func (z *Int) CheckInRange(m *Modulus) Choice {
 	// First check that the absolute value makes sense
 	_, _, absOk := z.abs.CmpMod(m)
 
 	negated := new(Nat).ModNeg(&z.abs, m)
-	_, _, lt := negated.Cmp(&z.abs)
+	_, eq, lt := negated.Cmp(&z.abs)
 	// If the negated value is strictly smaller, then we have a number out of range
 	signOk := 1 ^ lt
+	// If the negated value is equal, then either z is 0, or m is even and z is
+	// m/2, which is only in range when negative.
+	signOk &= 1 ^ (eq & (1 ^ z.abs.EqZero()) & (1 ^ z.sign))
 
 	return absOk & signOk
 }
@@ -241,6 +576,25 @@ func (z *Nat) ExpI(x *Nat, i *Int, m *Modulus) *Nat {
 	return z
 }
 
+// ExpInt calculates z <- x^y mod m, returning whether or not this succeeded.
+//
+// A negative exponent y calculates the inverse of x^|y| mod m. This requires
+// x to be invertible mod m: if it isn't, then z is set to 0, and 0 is returned.
+// With a non-negative exponent, this always succeeds, and x^0 is 1.
+//
+// Unlike ExpI, this works for any modulus, and checks that the inverse exists.
+//
+// This doesn't leak the sign of y, or whether or not x was invertible,
+// apart from through the result.
+//
+// The capacity of the resulting number matches the capacity of the modulus
+func (z *Nat) ExpInt(x *Nat, y *Int, m *Modulus) (*Nat, Choice) {
+	z.Exp(x, &y.abs, m)
+	inverted, invertible := new(Nat).ModInverseEven(z, m)
+	z.CondAssign(y.sign, inverted)
+	return z, invertible | (1 ^ y.sign)
+}
+
 // conditionally negate a slice of words based on two's complement
 func negateTwos(doit Choice, z []Word) {
 	if len(z) <= 0 {
@@ -276,6 +630,9 @@ func fromTwos(bits int, mut []Word) Choice {
 // The cap determines the number of bits to use for the absolute value of the result.
 //
 // If cap < 0, cap gets set to max(x.AnnouncedLen(), y.AnnouncedLen()) + 1
+//
+// If the sum doesn't fit in cap bits, the result silently wraps around, and
+// its sign may not match that of the true sum. AddChecked can be used to detect this.
 func (z *Int) Add(x *Int, y *Int, cap int) *Int {
 	// Rough idea, convert x and y to two's complement representation, add, and
 	// then convert back, before truncating as necessary.
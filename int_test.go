@@ -2,8 +2,11 @@ package saferith
 
 import (
 	"bytes"
+	"math/big"
 	"math/rand"
 	"reflect"
+	"sort"
+	"strings"
 	"testing"
 	"testing/quick"
 )
@@ -100,6 +103,292 @@ func TestIntModRoundtrip(t *testing.T) {
 	}
 }
 
+func testIntCenteredBytesRoundtrip(x Nat, m Modulus) bool {
+	xModM := new(Nat).Mod(&x, &m)
+	i := new(Int).SetModSymmetric(xModM, &m)
+	data := i.CenteredBytes(&m)
+	if len(data) != (m.BitLen()+7)/8 {
+		return false
+	}
+	decoded := new(Int).SetCenteredBytes(data, &m)
+	if decoded.Eq(i) != 1 {
+		return false
+	}
+	return decoded.Mod(&m).Eq(xModM) == 1
+}
+
+func TestIntCenteredBytesRoundtrip(t *testing.T) {
+	err := quick.Check(testIntCenteredBytesRoundtrip, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestIntCenteredBytesExamples(t *testing.T) {
+	// Every value in the symmetric range, including both endpoints, for odd and even moduli
+	for _, mU64 := range []uint64{13, 16, 255, 256} {
+		m := ModulusFromUint64(mU64)
+		for x := uint64(0); x < mU64; x++ {
+			i := new(Int).SetModSymmetric(new(Nat).SetUint64(x), m)
+			decoded := new(Int).SetCenteredBytes(i.CenteredBytes(m), m)
+			if decoded.Eq(i) != 1 {
+				t.Errorf("%+v != %+v", i, decoded)
+			}
+		}
+	}
+	m := ModulusFromUint64(255)
+	x := new(Int).SetUint64(127).Neg(1)
+	expected := []byte{0x81}
+	actual := x.CenteredBytes(m)
+	if !bytes.Equal(expected, actual) {
+		t.Errorf("%+v != %+v", expected, actual)
+	}
+	m = ModulusFromUint64(256)
+	x = new(Int).SetUint64(128).Neg(1)
+	expected = []byte{0xFF, 0x80}
+	actual = x.CenteredBytes(m)
+	if !bytes.Equal(expected, actual) {
+		t.Errorf("%+v != %+v", expected, actual)
+	}
+}
+
+func testIntOrderedBytesPreservesOrder(x *Int, y *Int) bool {
+	// Enough bytes for both numbers, and their sign
+	width := x.abs.maxAnnounced(&y.abs)/8 + 1
+	xData := x.OrderedBytes(width)
+	yData := y.OrderedBytes(width)
+	if len(xData) != width || len(yData) != width {
+		return false
+	}
+	if bytes.Compare(xData, yData) != x.Big().Cmp(y.Big()) {
+		return false
+	}
+	decoded := new(Int).SetOrderedBytes(xData)
+	return decoded.AnnouncedLen() == 8*width && decoded.Eq(x) == 1
+}
+
+func TestIntOrderedBytesPreservesOrder(t *testing.T) {
+	err := quick.Check(testIntOrderedBytesPreservesOrder, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestIntOrderedBytesExamples(t *testing.T) {
+	// Sorting the encodings should sort the numbers
+	r := rand.New(rand.NewSource(0))
+	ints := make([]*Int, 100)
+	for i := 0; i < len(ints); i++ {
+		ints[i] = new(Int).SetUint64(r.Uint64() >> 1).Neg(Choice(r.Intn(2)))
+	}
+	ints = append(ints, new(Int), negativeZero())
+	encoded := make([][]byte, len(ints))
+	for i, x := range ints {
+		encoded[i] = x.OrderedBytes(8)
+	}
+	sort.Slice(ints, func(i, j int) bool {
+		return ints[i].Big().Cmp(ints[j].Big()) < 0
+	})
+	sort.Slice(encoded, func(i, j int) bool {
+		return bytes.Compare(encoded[i], encoded[j]) < 0
+	})
+	for i := 0; i < len(ints); i++ {
+		decoded := new(Int).SetOrderedBytes(encoded[i])
+		if decoded.Eq(ints[i]) != 1 {
+			t.Errorf("%+v != %+v", ints[i], decoded)
+		}
+	}
+	// The endpoints of the range for a single byte
+	examples := []struct {
+		x        *Int
+		expected []byte
+	}{
+		{new(Int).SetUint64(128).Neg(1), []byte{0x00}},
+		{new(Int).SetUint64(1).Neg(1), []byte{0x7F}},
+		{new(Int), []byte{0x80}},
+		{negativeZero(), []byte{0x80}},
+		{new(Int).SetUint64(127), []byte{0xFF}},
+	}
+	for _, example := range examples {
+		actual := example.x.OrderedBytes(1)
+		if !bytes.Equal(example.expected, actual) {
+			t.Errorf("%+v != %+v", example.expected, actual)
+		}
+	}
+	if len(new(Int).SetUint64(5).OrderedBytes(0)) != 0 {
+		t.Errorf("expected an empty encoding")
+	}
+}
+
+func testExpIntNegativeIsInverse(x Nat, k Nat, m Modulus) bool {
+	if x.Coprime(&m.nat) != 1 {
+		return true
+	}
+	expected := new(Nat).Exp(&x, &k, &m)
+	expected.ModInverse(expected, &m)
+	y := new(Int).SetNat(&k).Neg(1)
+	actual, ok := new(Nat).ExpInt(&x, y, &m)
+	if ok != 1 || !actual.checkInvariants() {
+		return false
+	}
+	return expected.Eq(actual) == 1
+}
+
+func TestExpIntNegativeIsInverse(t *testing.T) {
+	err := quick.Check(testExpIntNegativeIsInverse, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestExpIntExamples(t *testing.T) {
+	m := ModulusFromUint64(13)
+	x := new(Nat).SetUint64(2)
+	// 2^-3 = 8^-1 = 5 mod 13
+	expected := new(Nat).SetUint64(5)
+	actual, ok := new(Nat).ExpInt(x, new(Int).SetUint64(3).Neg(1), m)
+	if ok != 1 || expected.Eq(actual) != 1 {
+		t.Errorf("%+v != %+v", expected, actual)
+	}
+	expected.SetUint64(1)
+	actual, ok = new(Nat).ExpInt(x, new(Int), m)
+	if ok != 1 || expected.Eq(actual) != 1 {
+		t.Errorf("%+v != %+v", expected, actual)
+	}
+	// 3 isn't invertible mod 15
+	m = ModulusFromUint64(15)
+	x.SetUint64(3)
+	expected.SetUint64(0)
+	actual, ok = new(Nat).ExpInt(x, new(Int).SetUint64(2).Neg(1), m)
+	if ok != 0 || expected.Eq(actual) != 1 {
+		t.Errorf("%+v != %+v", expected, actual)
+	}
+	expected.SetUint64(9)
+	actual, ok = new(Nat).ExpInt(x, new(Int).SetUint64(2), m)
+	if ok != 1 || expected.Eq(actual) != 1 {
+		t.Errorf("%+v != %+v", expected, actual)
+	}
+	// Even moduli work too, 3^-5 = 243^-1 = 59 mod 1024, and 2 isn't invertible
+	m = ModulusFromUint64(1 << 10)
+	expected.SetUint64(59)
+	actual, ok = new(Nat).ExpInt(x, new(Int).SetUint64(5).Neg(1), m)
+	if ok != 1 || expected.Eq(actual) != 1 {
+		t.Errorf("%+v != %+v", expected, actual)
+	}
+	_, ok = new(Nat).ExpInt(new(Nat).SetUint64(2), new(Int).SetUint64(5).Neg(1), m)
+	if ok != 0 {
+		t.Errorf("expected 2 to have no inverse mod 1024")
+	}
+	m = ModulusFromBytes(modulus2048Even())
+	y := new(Int).SetUint64(0x10001).Neg(1)
+	expectedBig := new(big.Int).Exp(big.NewInt(3), big.NewInt(0x10001), m.Big())
+	expectedBig.ModInverse(expectedBig, m.Big())
+	actual, ok = new(Nat).ExpInt(x, y, m)
+	if ok != 1 || actual.Big().Cmp(expectedBig) != 0 {
+		t.Errorf("%+v != %+v", expectedBig, actual)
+	}
+}
+
+func testIntResizeNoNegativeZero(x *Int, cap uint16) bool {
+	c := int(cap & 511)
+	sign := x.IsNegative()
+	z := new(Int).SetInt(x).Resize(c)
+	if !z.abs.checkInvariants() {
+		return false
+	}
+	if z.Eq(new(Int)) == 1 {
+		return z.IsNegative() == 0
+	}
+	return z.IsNegative() == sign
+}
+
+func TestIntResizeNoNegativeZero(t *testing.T) {
+	err := quick.Check(testIntResizeNoNegativeZero, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestIntResizeExamples(t *testing.T) {
+	x := new(Int).SetUint64(256).Neg(1)
+	x.Resize(8)
+	if x.IsNegative() != 0 || x.IsZero() != 1 {
+		t.Errorf("expected -256 truncated to 8 bits to be positive zero")
+	}
+	x = new(Int).SetUint64(257).Neg(1)
+	x.Resize(8)
+	expected := new(Int).SetUint64(1).Neg(1)
+	if x.IsNegative() != 1 || expected.Eq(x) != 1 {
+		t.Errorf("%+v != %+v", expected, x)
+	}
+}
+
+func testIntAbsNatRoundtrip(x *Int) bool {
+	abs, negative := x.AbsNat()
+	if !abs.checkInvariants() || abs.Eq(x.Abs()) != 1 {
+		return false
+	}
+	if negative != x.IsNegative()&(1^x.IsZero()) {
+		return false
+	}
+	roundTrip := new(Int).SetNat(abs).Neg(negative)
+	return roundTrip.Eq(x) == 1
+}
+
+func TestIntAbsNatRoundtrip(t *testing.T) {
+	err := quick.Check(testIntAbsNatRoundtrip, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestIntAbsNatExamples(t *testing.T) {
+	x := new(Int).SetUint64(7).Neg(1)
+	abs, negative := x.AbsNat()
+	expected := new(Nat).SetUint64(7)
+	if negative != 1 || expected.Eq(abs) != 1 {
+		t.Errorf("%+v != %+v", expected, abs)
+	}
+	// Modifying the result shouldn't affect the Int
+	abs.SetUint64(3)
+	if x.Abs().Eq(expected) != 1 {
+		t.Errorf("%+v != %+v", expected, x.Abs())
+	}
+	_, negative = negativeZero().AbsNat()
+	if negative != 0 {
+		t.Errorf("expected negative zero to be reported as positive")
+	}
+}
+
+// negativeZero returns a zero with its sign set, which Neg never produces.
+func negativeZero() *Int {
+	z := new(Int).SetUint64(0)
+	z.sign = 1
+	return z
+}
+
+func TestIntNegZeroExamples(t *testing.T) {
+	for _, x := range []*Int{new(Int), new(Int).SetUint64(0), new(Int).SetUint64(0).Resize(300)} {
+		expected, _ := x.MarshalBinary()
+		x.Neg(1)
+		if x.IsNegative() != 0 {
+			t.Errorf("expected the negation of zero to be positive")
+		}
+		actual, _ := x.MarshalBinary()
+		if !bytes.Equal(expected, actual) {
+			t.Errorf("%+v != %+v", expected, actual)
+		}
+		if x.String() != new(Int).SetUint64(0).Resize(x.AnnouncedLen()).String() {
+			t.Errorf("%+v != %+v", new(Int).SetUint64(0), x)
+		}
+	}
+	// Negating twice should give back the original value
+	x := new(Int).SetUint64(5)
+	if x.Neg(1).IsNegative() != 1 || x.Neg(1).IsNegative() != 0 {
+		t.Errorf("unexpected sign for %+v", x)
+	}
+}
+
 func testIntAddNegZero(i *Int) bool {
 	zero := new(Int)
 	neg := new(Int).SetInt(i).Neg(1)
@@ -140,12 +429,82 @@ func TestIntAddZeroIdentity(t *testing.T) {
 	}
 }
 
+func TestSetModSymmetricExamples(t *testing.T) {
+	// Every residue should map to a distinct representative in range, for odd and even moduli
+	for _, mU64 := range []uint64{1, 2, 3, 4, 13, 16, 255, 256} {
+		m := ModulusFromUint64(mU64)
+		seen := make(map[int64]bool)
+		for x := uint64(0); x < mU64; x++ {
+			xNat := new(Nat).SetUint64(x)
+			i := new(Int).SetModSymmetric(xNat, m)
+			if i.CheckInRange(m) != 1 {
+				t.Errorf("%+v not in range of %+v", i, m)
+			}
+			if i.Mod(m).Eq(xNat) != 1 {
+				t.Errorf("%+v != %+v", xNat, i.Mod(m))
+			}
+			value := int64(i.abs.Uint64())
+			if i.IsNegative() == 1 {
+				value = -value
+			}
+			if value < -int64(mU64/2) || value > int64((mU64-1)/2) {
+				t.Errorf("%d out of the symmetric range of %d", value, mU64)
+			}
+			if seen[value] {
+				t.Errorf("%d produced twice for modulus %d", value, mU64)
+			}
+			seen[value] = true
+		}
+	}
+	m := ModulusFromUint64(16)
+	x := new(Int).SetModSymmetric(new(Nat).SetUint64(8), m)
+	expected := new(Int).SetUint64(8).Neg(1)
+	if x.Eq(expected) != 1 || x.IsNegative() != 1 {
+		t.Errorf("%+v != %+v", expected, x)
+	}
+	x = new(Int).SetModSymmetric(new(Nat).SetUint64(0), m)
+	if x.IsNegative() != 0 {
+		t.Errorf("expected zero to be positive")
+	}
+}
+
+func TestIntWriteDigestExamples(t *testing.T) {
+	var positive, negative, zero, negZero recordingHash
+	new(Int).SetUint64(5).WriteDigest(&positive)
+	new(Int).SetUint64(5).Neg(1).WriteDigest(&negative)
+	if bytes.Equal(positive.Bytes(), negative.Bytes()) {
+		t.Errorf("expected the sign to be part of the encoding")
+	}
+	expected := []byte{'I', 1, 0, 0, 0, 8, 0, 0, 0, 0, 0, 0, 0, 5}
+	if !bytes.Equal(expected, negative.Bytes()) {
+		t.Errorf("%+v != %+v", expected, negative.Bytes())
+	}
+	new(Int).SetUint64(0).WriteDigest(&zero)
+	negativeZero().WriteDigest(&negZero)
+	if !bytes.Equal(zero.Bytes(), negZero.Bytes()) {
+		t.Errorf("%+v != %+v", zero.Bytes(), negZero.Bytes())
+	}
+}
+
 func TestCheckInRangeExamples(t *testing.T) {
 	x := new(Int).SetUint64(0)
 	m := ModulusFromUint64(13)
 	if x.CheckInRange(m) != 1 {
 		t.Errorf("expected zero to be in range of modulus")
 	}
+	// For even moduli, -m/2 is in range, but not m/2
+	m = ModulusFromUint64(16)
+	x = new(Int).SetUint64(8)
+	if x.CheckInRange(m) != 0 {
+		t.Errorf("expected m/2 to be out of range")
+	}
+	if x.Neg(1).CheckInRange(m) != 1 {
+		t.Errorf("expected -m/2 to be in range")
+	}
+	x = new(Int).SetUint64(7)
+	if x.CheckInRange(m) != 1 {
+		t.Errorf("expected m/2 - 1 to be in range")
+	}
 }
 
 func TestIntAddExamples(t *testing.T) {
@@ -198,3 +557,398 @@ func TestInvalidInt(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func testIntSubNatAntisymmetric(x Nat, y Nat) bool {
+	xMinusY := new(Int).SubNat(&x, &y, -1)
+	yMinusX := new(Int).SubNat(&y, &x, -1)
+	if !(xMinusY.abs.checkInvariants() && yMinusX.abs.checkInvariants()) {
+		return false
+	}
+	if xMinusY.Eq(yMinusX.Neg(1)) != 1 {
+		return false
+	}
+	expected := new(big.Int).Sub(x.Big(), y.Big())
+	return xMinusY.Big().Cmp(expected) == 0
+}
+
+func TestIntSubNatAntisymmetric(t *testing.T) {
+	err := quick.Check(testIntSubNatAntisymmetric, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestIntSubNatExamples(t *testing.T) {
+	x := new(Nat).SetUint64(3)
+	y := new(Nat).SetUint64(5).Resize(128)
+	actual := new(Int).SubNat(x, y, -1)
+	expected := new(Int).SetUint64(2).Neg(1)
+	if expected.Eq(actual) != 1 {
+		t.Errorf("%+v != %+v", expected, actual)
+	}
+	if actual.AnnouncedLen() != 128 {
+		t.Errorf("%+v != %+v", actual.AnnouncedLen(), 128)
+	}
+	actual.SubNat(y, y, -1)
+	if actual.IsNegative() != 0 || actual.abs.EqZero() != 1 {
+		t.Errorf("expected x - x to be positive zero")
+	}
+}
+
+func testIntModIntMatchesMod(x *Int, m Modulus) bool {
+	expected := x.Mod(&m)
+	actual := new(Int).ModInt(x, &m)
+	if !actual.abs.checkInvariants() {
+		return false
+	}
+	if actual.IsNegative() != 0 || actual.abs.reduced != &m {
+		return false
+	}
+	// Aliasing should also work
+	aliased := x.Clone()
+	aliased.ModInt(aliased, &m)
+	return expected.Eq(&actual.abs) == 1 && expected.Eq(&aliased.abs) == 1
+}
+
+func TestIntModIntMatchesMod(t *testing.T) {
+	err := quick.Check(testIntModIntMatchesMod, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func testIntModOpsMatchBig(x *Int, y *Int, m Modulus) bool {
+	mBig := m.Big()
+	xBig, yBig := x.Big(), y.Big()
+	results := []struct {
+		actual   *Int
+		expected *big.Int
+	}{
+		{new(Int).ModAdd(x, y, &m), new(big.Int).Add(xBig, yBig)},
+		{new(Int).ModSub(x, y, &m), new(big.Int).Sub(xBig, yBig)},
+		{new(Int).ModMul(x, y, &m), new(big.Int).Mul(xBig, yBig)},
+	}
+	for _, r := range results {
+		// big.Int's Mod uses Euclidean reduction, so the result is always non-negative
+		r.expected.Mod(r.expected, mBig)
+		if !r.actual.abs.checkInvariants() || r.actual.IsNegative() != 0 {
+			return false
+		}
+		if r.actual.Big().Cmp(r.expected) != 0 {
+			return false
+		}
+	}
+	// Aliasing should also work
+	aliased := x.Clone()
+	aliased.ModSub(aliased, y, &m)
+	return aliased.Eq(results[1].actual) == 1
+}
+
+func TestIntModOpsMatchBig(t *testing.T) {
+	err := quick.Check(testIntModOpsMatchBig, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestIntModOpsExamples(t *testing.T) {
+	m := ModulusFromUint64(13)
+	x := new(Int).SetUint64(5).Neg(1)
+	y := new(Int).SetUint64(3)
+	expected := new(Int).SetUint64(11)
+	actual := new(Int).ModAdd(x, y, m)
+	if expected.Eq(actual) != 1 {
+		t.Errorf("%+v != %+v", expected, actual)
+	}
+	expected = new(Int).SetUint64(5)
+	actual = new(Int).ModSub(x, y, m)
+	if expected.Eq(actual) != 1 {
+		t.Errorf("%+v != %+v", expected, actual)
+	}
+	expected = new(Int).SetUint64(11)
+	actual = new(Int).ModMul(x, y, m)
+	if expected.Eq(actual) != 1 {
+		t.Errorf("%+v != %+v", expected, actual)
+	}
+}
+
+func testIntIsZeroIsOne(x *Int) bool {
+	zero := new(Int)
+	one := new(Int).SetUint64(1)
+	expectedZero := x.Eq(zero)
+	expectedOne := x.Eq(one)
+	return x.IsZero() == expectedZero && x.IsOne() == expectedOne
+}
+
+func TestIntIsZeroIsOne(t *testing.T) {
+	err := quick.Check(testIntIsZeroIsOne, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestIntIsZeroIsOneExamples(t *testing.T) {
+	if new(Int).IsZero() != 1 {
+		t.Errorf("expected empty Int to be zero")
+	}
+	if negativeZero().IsZero() != 1 {
+		t.Errorf("expected negative zero to be zero")
+	}
+	if new(Int).IsOne() != 0 {
+		t.Errorf("expected empty Int to not be one")
+	}
+	if new(Int).SetUint64(1).Resize(256).IsOne() != 1 {
+		t.Errorf("expected padded one to be one")
+	}
+	if new(Int).SetUint64(1).Neg(1).IsOne() != 0 {
+		t.Errorf("expected negative one to not be one")
+	}
+	if new(Int).SetUint64(1<<40+1).IsOne() != 0 {
+		t.Errorf("expected 2^40 + 1 to not be one")
+	}
+}
+
+func testIntAddCheckedMatchesBig(x *Int, y *Int, cap uint16) bool {
+	c := int(cap & 1023)
+	z, overflow := new(Int).AddChecked(x, y, c)
+	if !z.abs.checkInvariants() || z.AnnouncedLen() != c {
+		return false
+	}
+	exact := new(big.Int).Add(x.Big(), y.Big())
+	expectedOverflow := Choice(0)
+	if exact.BitLen() > c {
+		expectedOverflow = 1
+	}
+	if overflow != expectedOverflow {
+		return false
+	}
+	if overflow == 0 {
+		return z.Big().Cmp(exact) == 0
+	}
+	return true
+}
+
+func TestIntAddCheckedMatchesBig(t *testing.T) {
+	err := quick.Check(testIntAddCheckedMatchesBig, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func testIntAddCheckedDefaultCapNoOverflow(x *Int, y *Int) bool {
+	expected := new(Int).Add(x, y, -1)
+	actual, overflow := new(Int).AddChecked(x, y, -1)
+	return overflow == 0 && expected.Eq(actual) == 1
+}
+
+func TestIntAddCheckedDefaultCapNoOverflow(t *testing.T) {
+	err := quick.Check(testIntAddCheckedDefaultCapNoOverflow, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestIntAddCheckedExamples(t *testing.T) {
+	x := new(Int).SetUint64(200).Resize(8)
+	y := new(Int).SetUint64(100).Resize(8)
+	_, overflow := new(Int).AddChecked(x, y, 8)
+	if overflow != 1 {
+		t.Errorf("expected 200 + 100 to overflow 8 bits")
+	}
+	actual, overflow := new(Int).AddChecked(x, y.Neg(1), 8)
+	expected := new(Int).SetUint64(100)
+	if overflow != 0 || expected.Eq(actual) != 1 {
+		t.Errorf("%+v != %+v", expected, actual)
+	}
+	x.Neg(1)
+	_, overflow = new(Int).AddChecked(x, y, 8)
+	if overflow != 1 {
+		t.Errorf("expected -200 - 100 to overflow 8 bits")
+	}
+	// -256 needs 9 bits for its absolute value, and truncates to zero, which is positive
+	actual, overflow = new(Int).AddChecked(new(Int).SetUint64(128).Neg(1), new(Int).SetUint64(128).Neg(1), 8)
+	if overflow != 1 || actual.IsZero() != 1 || actual.IsNegative() != 0 {
+		t.Errorf("expected -128 - 128 to overflow 8 bits, truncating to a positive zero")
+	}
+}
+
+func testIntMulCheckedMatchesBig(x *Int, y *Int, cap uint16) bool {
+	c := int(cap & 1023)
+	z, overflow := new(Int).MulChecked(x, y, c)
+	if !z.abs.checkInvariants() || z.AnnouncedLen() != c {
+		return false
+	}
+	exact := new(big.Int).Mul(x.Big(), y.Big())
+	expectedOverflow := Choice(0)
+	if exact.BitLen() > c {
+		expectedOverflow = 1
+	}
+	if overflow != expectedOverflow {
+		return false
+	}
+	// The product of an a bit number and a b bit number always fits in a + b bits
+	if c >= x.TrueLen()+y.TrueLen() && overflow != 0 {
+		return false
+	}
+	if overflow == 0 {
+		return z.Big().Cmp(exact) == 0
+	}
+	return true
+}
+
+func TestIntMulCheckedMatchesBig(t *testing.T) {
+	err := quick.Check(testIntMulCheckedMatchesBig, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestIntMulCheckedExamples(t *testing.T) {
+	x := new(Int).SetUint64(16).Resize(8)
+	y := new(Int).SetUint64(16).Neg(1).Resize(8)
+	// -256 needs 9 bits for its absolute value, and truncates to zero, which is positive
+	actual, overflow := new(Int).MulChecked(x, y, 8)
+	if overflow != 1 || actual.IsZero() != 1 || actual.IsNegative() != 0 {
+		t.Errorf("expected 16 * -16 to overflow 8 bits, truncating to a positive zero")
+	}
+	// -272 truncates to -16, keeping its sign
+	actual, overflow = new(Int).MulChecked(x, new(Int).SetUint64(17).Neg(1).Resize(8), 8)
+	expected := new(Int).SetUint64(16).Neg(1)
+	if overflow != 1 || expected.Eq(actual) != 1 || actual.IsNegative() != 1 {
+		t.Errorf("%+v != %+v", expected, actual)
+	}
+	actual, overflow = new(Int).MulChecked(x, y, 9)
+	expected = new(Int).SetUint64(256).Neg(1)
+	if overflow != 0 || expected.Eq(actual) != 1 {
+		t.Errorf("%+v != %+v", expected, actual)
+	}
+	actual, overflow = new(Int).MulChecked(x, y, -1)
+	if overflow != 0 || expected.Eq(actual) != 1 || actual.AnnouncedLen() != 16 {
+		t.Errorf("%+v != %+v", expected, actual)
+	}
+}
+
+func testIntSetStringMatchesBig(x *Int) bool {
+	for _, base := range []int{2, 10, 16} {
+		str := x.Big().Text(base)
+		actual, err := new(Int).SetString(str, base)
+		if err != nil {
+			return false
+		}
+		if !actual.abs.checkInvariants() {
+			return false
+		}
+		expected, ok := new(big.Int).SetString(str, base)
+		if !ok || actual.Big().Cmp(expected) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestIntSetStringMatchesBig(t *testing.T) {
+	err := quick.Check(testIntSetStringMatchesBig, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestIntSetStringExamples(t *testing.T) {
+	for _, str := range []string{"-0x1f", "+31", "-0b11111", "0o37", "-037"} {
+		actual, err := new(Int).SetString(str, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected, _ := new(big.Int).SetString(str, 0)
+		if actual.Big().Cmp(expected) != 0 {
+			t.Errorf("%s: %+v != %+v", str, expected, actual)
+		}
+	}
+	actual, err := new(Int).SetString("-0", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual.IsNegative() != 0 || actual.IsZero() != 1 {
+		t.Errorf("expected -0 to be normalized to positive zero")
+	}
+	for _, str := range []string{"", "-", "+-1", "--1", "1-"} {
+		_, err = new(Int).SetString(str, 10)
+		if err == nil {
+			t.Errorf("expected error for %q", str)
+		}
+	}
+	_, err = new(Int).SetString("-12z", 10)
+	if err == nil || !strings.Contains(err.Error(), "'z'") {
+		t.Errorf("expected error mentioning 'z', got %v", err)
+	}
+}
+
+func testIntTextRoundTrip(x *Int) bool {
+	for _, base := range []int{2, 10, 16, 36} {
+		text := x.Text(base)
+		if text != x.Big().Text(base) {
+			return false
+		}
+		y, err := new(Int).SetString(text, base)
+		if err != nil || y.Eq(x) != 1 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestIntTextRoundTrip(t *testing.T) {
+	err := quick.Check(testIntTextRoundTrip, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestIntTextExamples(t *testing.T) {
+	x := new(Int).SetUint64(1234).Neg(1)
+	if x.Text(10) != "-1234" {
+		t.Errorf("%q != %q", x.Text(10), "-1234")
+	}
+	x.Neg(1)
+	if x.Text(10) != "1234" {
+		t.Errorf("%q != %q", x.Text(10), "1234")
+	}
+	negZero := new(Int).SetUint64(0).Resize(64).Neg(1)
+	if negZero.Text(10) != "0" {
+		t.Errorf("%q != %q", negZero.Text(10), "0")
+	}
+}
+
+func testAbsDiffSymmetric(x *Int, y *Int) bool {
+	way1 := new(Nat).AbsDiff(x, y)
+	way2 := new(Nat).AbsDiff(y, x)
+	if !(way1.checkInvariants() && way2.checkInvariants()) {
+		return false
+	}
+	if way1.Eq(way2) != 1 {
+		return false
+	}
+	expected := new(big.Int).Sub(x.Big(), y.Big())
+	expected.Abs(expected)
+	return way1.Big().Cmp(expected) == 0
+}
+
+func TestAbsDiffSymmetric(t *testing.T) {
+	err := quick.Check(testAbsDiffSymmetric, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestAbsDiffExamples(t *testing.T) {
+	x := new(Int).SetUint64(3).Neg(1)
+	y := new(Int).SetUint64(5).Resize(128)
+	actual := new(Nat).AbsDiff(x, y)
+	expected := new(Nat).SetUint64(8)
+	if expected.Eq(actual) != 1 {
+		t.Errorf("%+v != %+v", expected, actual)
+	}
+	if actual.AnnouncedLen() != 129 {
+		t.Errorf("%+v != %+v", actual.AnnouncedLen(), 129)
+	}
+}
@@ -1,10 +1,17 @@
 package saferith
 
 import (
+	"encoding/binary"
+	"errors"
 	"fmt"
+	"hash"
+	"io"
 	"math/big"
 	"math/bits"
+	"runtime"
 	"strings"
+	"sync/atomic"
+	"unicode"
 )
 
 // General utilities
@@ -22,7 +29,10 @@ func add(a, b, carry Word) (sum Word, newCarry Word) {
 
 // Choice represents a constant-time boolean.
 //
-// The value of Choice is always either 1 or 0.
+// The value of Choice is always either 1 or 0. This is part of the contract
+// of this type: every Choice produced by this package satisfies it, and every
+// function accepting a Choice assumes it. Constructing a Choice with any other
+// value, e.g. Choice(2), leads to undefined results.
 //
 // We use a separate type instead of bool, in order to be able to make decisions without leaking
 // which decision was made.
@@ -65,6 +75,16 @@ func ctIfElse(v Choice, x, y Word) Word {
 	return y ^ (mask & (y ^ x))
 }
 
+// Select returns ifYes if v = 1, and ifNo otherwise.
+//
+// This is the constant-time selection used throughout this package, which allows
+// building other constant-time routines on top of Choice values.
+//
+// This doesn't leak the value of any of its inputs.
+func Select(v Choice, ifYes, ifNo Word) Word {
+	return ctIfElse(v, ifYes, ifNo)
+}
+
 // ctCondCopy copies y into x, if v == 1, otherwise does nothing
 //
 // Both slices must have the same length.
@@ -119,6 +139,33 @@ func (z *Nat) CondAssign(yes Choice, x *Nat) *Nat {
 	return z
 }
 
+// CondAssignUint64 sets z <- yes ? x : z.
+//
+// This is like CondAssign, but with a small constant, which avoids having to
+// create a Nat holding x first.
+//
+// The announced length of z will be the max of its current length and 64.
+//
+// This function doesn't leak any information about whether the assignment happened.
+func (z *Nat) CondAssignUint64(yes Choice, x uint64) *Nat {
+	maxBits := z.announced
+	if maxBits < 64 {
+		maxBits = 64
+	}
+	z.limbs = z.resizedLimbs(maxBits)
+	// LEAK: Number of limbs
+	// OK: This only depends on the announced length of z
+	for i := 0; i < len(z.limbs); i++ {
+		z.limbs[i] = ctIfElse(yes, Word(x), z.limbs[i])
+		// Shifting in two steps avoids an invalid shift when _W = 64
+		x = x >> 32 >> (_W - 32)
+	}
+	// The value we're potentially assigning isn't reduced
+	z.reduced = nil
+	z.announced = maxBits
+	return z
+}
+
 // "Missing" Functions
 // These are routines that could in theory be implemented in assembly,
 // but aren't already present in Go's big number routines
@@ -192,6 +239,13 @@ type Nat struct {
 	limbs []Word
 }
 
+// NewZero creates a Nat holding 0, with a capacity of bits.
+//
+// This is the same as new(Nat).Resize(bits), and is useful for initializing accumulators.
+func NewZero(bits int) *Nat {
+	return new(Nat).Resize(bits)
+}
+
 // checkInvariants does some internal sanity checks.
 //
 // This is useful for tests.
@@ -275,6 +329,26 @@ func (z *Nat) unaliasedLimbs(x *Nat) []Word {
 	return res
 }
 
+// IsAlias checks whether or not z and x share the same underlying storage.
+//
+// This returns true if z and x are the same Nat, or if their limbs use the same
+// backing array.
+//
+// This is an advanced aid, for callers writing their own wrappers around this library.
+// Methods taking multiple arguments only handle the case where an argument is the
+// same Nat as the receiver, making a copy of that argument when necessary. Distinct
+// Nats whose limbs overlap aren't handled, and using them together gives undefined results.
+//
+// This will leak whether or not z and x are aliased, but not their values.
+func (z *Nat) IsAlias(x *Nat) bool {
+	if z == x {
+		return true
+	}
+	// Two slices with the same backing array will end at the same place in memory.
+	return cap(z.limbs) > 0 && cap(x.limbs) > 0 &&
+		&z.limbs[:cap(z.limbs)][cap(z.limbs)-1] == &x.limbs[:cap(x.limbs)][cap(x.limbs)-1]
+}
+
 // trueSize calculates the actual size necessary for representing these limbs
 //
 // This is the size with leading zeros removed. This leaks the number
@@ -299,7 +373,8 @@ func (z *Nat) AnnouncedLen() int {
 // For most purposes, `AnnouncedLen` should be used instead.
 //
 // That being said, this function does try to limit its leakage, and should
-// only leak the number of leading zero bits in the number.
+// only leak the number of leading zero bits in the number. If even that is too
+// much, SecretBitLen returns the length as a Nat instead.
 func (z *Nat) TrueLen() int {
 	limbSize := trueSize(z.limbs)
 	size := limbSize * _W
@@ -309,32 +384,103 @@ func (z *Nat) TrueLen() int {
 	return size
 }
 
+// TrimToTrueLen resizes z to exactly TrueLen bits, returning z.
+//
+// This is the same as z.Resize(z.TrueLen()), and is useful to speed up operations
+// on public values which have been padded to a larger announced length.
+//
+// This function leaks the true length of z, which becomes its announced length.
+// This should only be used if the size of z isn't sensitive information.
+func (z *Nat) TrimToTrueLen() *Nat {
+	return z.Resize(z.TrueLen())
+}
+
+// SecretBitLen calculates the exact number of bits needed to represent z, as a Nat.
+//
+// Unlike TrueLen, this function doesn't leak the number of leading zero bits in z.
+// Every limb is scanned, and the length is selected without branching on it,
+// so only the announced length of z is leaked.
+//
+// The capacity of the result is the number of bits needed to represent z.AnnouncedLen().
+func (z *Nat) SecretBitLen() *Nat {
+	var length Word
+	// LEAK: Number of limbs
+	// OK: The number of limbs is public
+	for i, x := range z.limbs {
+		nonZero := 1 ^ ctEq(x, 0)
+		limbLen := Word(i*_W + _W - leadingZeros(x))
+		length = ctIfElse(nonZero, limbLen, length)
+	}
+	out := new(Nat)
+	out.limbs = []Word{length}
+	out.announced = _W
+	return out.Resize(bits.Len(uint(z.announced)))
+}
+
 // FillBytes writes out the big endian bytes of a natural number.
 //
 // This will always write out the full capacity of the number, without
 // any kind trimming.
 func (z *Nat) FillBytes(buf []byte) []byte {
-	for i := 0; i < len(buf); i++ {
-		buf[i] = 0
-	}
-
-	i := len(buf)
 	// LEAK: Number of limbs
 	// OK: The number of limbs is public
 	// LEAK: The addresses touched in the out array
 	// OK: Every member of out is touched
-Outer:
-	for _, x := range z.limbs {
-		y := x
-		for j := 0; j < _S; j++ {
-			i--
-			if i < 0 {
-				break Outer
-			}
-			buf[i] = byte(y)
+
+	// First, write out the limbs fitting entirely in buf, a full word at a time
+	full := len(buf) / _S
+	if full > len(z.limbs) {
+		full = len(z.limbs)
+	}
+	i := len(buf)
+	for _, x := range z.limbs[:full] {
+		i -= _S
+		putWordBytes(buf[i:i+_S], x)
+	}
+	// Then, the bytes of the next limb which still fit, if any
+	if full < len(z.limbs) {
+		y := z.limbs[full]
+		for ; i > 0; i-- {
+			buf[i-1] = byte(y)
 			y >>= 8
 		}
 	}
+	for j := 0; j < i; j++ {
+		buf[j] = 0
+	}
+	return buf
+}
+
+// wordFromBytes reads a Word from exactly _S bytes, in big endian order
+func wordFromBytes(b []byte) Word {
+	if _W == 64 {
+		return Word(binary.BigEndian.Uint64(b))
+	}
+	return Word(binary.BigEndian.Uint32(b))
+}
+
+// putWordBytes writes a Word into exactly _S bytes, in big endian order
+func putWordBytes(b []byte, x Word) {
+	if _W == 64 {
+		binary.BigEndian.PutUint64(b, uint64(x))
+	} else {
+		binary.BigEndian.PutUint32(b, uint32(x))
+	}
+}
+
+// FillBytesLE writes out the little endian bytes of a natural number.
+//
+// This is like FillBytes, except for the order of the bytes. The length of buf
+// is public, and the number will be truncated, or padded with zeros, to fit.
+func (z *Nat) FillBytesLE(buf []byte) []byte {
+	for i := 0; i < len(buf); i++ {
+		buf[i] = 0
+	}
+	// LEAK: Number of limbs, and the length of buf
+	// OK: These are both public
+	for i := 0; i < len(buf) && i/_S < len(z.limbs); i++ {
+		buf[i] = byte(z.limbs[i/_S] >> (8 * (i % _S)))
+	}
 	return buf
 }
 
@@ -347,12 +493,17 @@ func (z *Nat) SetBytes(buf []byte) *Nat {
 	z.reduced = nil
 	z.announced = 8 * len(buf)
 	z.limbs = z.resizedLimbs(z.announced)
-	bufI := len(buf) - 1
-	for i := 0; i < len(z.limbs) && bufI >= 0; i++ {
-		z.limbs[i] = 0
-		for shift := 0; shift < _W && bufI >= 0; shift += 8 {
-			z.limbs[i] |= Word(buf[bufI]) << shift
-			bufI--
+	// Each limb apart from the last is made of _S bytes, which we read at once
+	full := len(buf) / _S
+	for i := 0; i < full; i++ {
+		end := len(buf) - i*_S
+		z.limbs[i] = wordFromBytes(buf[end-_S : end])
+	}
+	// The remaining bytes at the start of buf make up the last limb
+	if full < len(z.limbs) {
+		z.limbs[full] = 0
+		for bufI := 0; bufI < len(buf)-full*_S; bufI++ {
+			z.limbs[full] = z.limbs[full]<<8 | Word(buf[bufI])
 		}
 	}
 	return z
@@ -361,12 +512,151 @@ func (z *Nat) SetBytes(buf []byte) *Nat {
 // Bytes creates a slice containing the contents of this Nat, in big endian
 //
 // This will always fill the output byte slice based on the announced length of this Nat.
+//
+// If this Nat holds a secret, the output should be scrubbed with SecureZero once
+// it's no longer needed, since it will otherwise stay in memory until it gets
+// garbage collected, and overwritten.
 func (z *Nat) Bytes() []byte {
 	length := (z.announced + 7) / 8
 	out := make([]byte, length)
 	return z.FillBytes(out)
 }
 
+// SecureZero overwrites the contents of b with zeros.
+//
+// This is meant for scrubbing the outputs of methods like Bytes, Hex, or MarshalBinary,
+// once they're no longer needed, when they contain secret values. Unlike a simple loop,
+// b is kept alive until the writes are done, which in practice stops the compiler from
+// removing them, even if b isn't used afterwards. Go doesn't formally guarantee that
+// dead stores won't be eliminated, however, so this is a best effort.
+//
+// Note that this can't do anything about copies of b that may have been made elsewhere,
+// and that strings, like the output of Hex, can't be scrubbed, since they're immutable.
+func SecureZero(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+	runtime.KeepAlive(b)
+}
+
+// secureZeroLimbs overwrites limbs with zeros, in the same way as SecureZero.
+func secureZeroLimbs(limbs []Word) {
+	for i := range limbs {
+		limbs[i] = 0
+	}
+	runtime.KeepAlive(limbs)
+}
+
+// Zero overwrites the value of z with zero, returning z.
+//
+// This scrubs the limbs of z in the same way as SecureZero, including any spare
+// capacity, so that a secret held by z doesn't linger in memory. The announced
+// length of z is left unchanged.
+func (z *Nat) Zero() *Nat {
+	secureZeroLimbs(z.limbs[:cap(z.limbs)])
+	return z
+}
+
+// AppendBytes appends the big endian bytes of this Nat to dst, returning the extended slice.
+//
+// This writes the same bytes as Bytes, but avoids allocating a new slice, if dst
+// has enough capacity, like the standard append.
+func (z *Nat) AppendBytes(dst []byte) []byte {
+	length := (z.announced + 7) / 8
+	start := len(dst)
+	dst = append(dst, make([]byte, length)...)
+	z.FillBytes(dst[start:])
+	return dst
+}
+
+// AppendLengthPrefixed appends the bytes of this Nat, prefixed by their length, to dst.
+//
+// The length is written as 4 big endian bytes, followed by the output of MarshalBinary.
+// This makes it possible to frame multiple numbers in a single message.
+//
+// This will panic if the encoding is longer than 2^32 - 1 bytes.
+func (z *Nat) AppendLengthPrefixed(dst []byte) []byte {
+	length := (z.announced + 7) / 8
+	if uint64(length) > 0xFFFF_FFFF {
+		panic("AppendLengthPrefixed: number too large")
+	}
+	dst = append(dst, byte(length>>24), byte(length>>16), byte(length>>8), byte(length))
+	return z.AppendBytes(dst)
+}
+
+// These tags are written before values by WriteDigest, to separate the encoding
+// of a Nat from that of an Int.
+const (
+	digestTagNat byte = 'N'
+	digestTagInt byte = 'I'
+)
+
+// WriteDigest writes an unambiguous encoding of this Nat into a hash.
+//
+// The encoding consists of a tag byte, separating it from the encoding of an Int,
+// followed by the output of AppendLengthPrefixed. Because each value is framed by
+// its length, writing several values in a row can't produce the same input to
+// the hash for different sequences of values.
+//
+// This doesn't leak the value of z, only its announced length.
+func (z *Nat) WriteDigest(h hash.Hash) {
+	h.Write(z.AppendLengthPrefixed([]byte{digestTagNat}))
+}
+
+// BytesPadded creates a slice containing exactly length big endian bytes of this Nat.
+//
+// Unlike FillBytes, this will not silently truncate the number: if the value
+// doesn't fit in length bytes, an error is returned instead.
+func (z *Nat) BytesPadded(length int) ([]byte, error) {
+	if length < 0 {
+		return nil, errors.New("negative length")
+	}
+	full := z.Bytes()
+	// LEAK: the announced length of z, and length
+	// OK: these are both public
+	if len(full) <= length {
+		return z.FillBytes(make([]byte, length)), nil
+	}
+	var extra byte
+	for _, b := range full[:len(full)-length] {
+		extra |= b
+	}
+	// LEAK: whether or not the value fits in length bytes
+	// OK: this is revealed by the result anyways
+	if extra != 0 {
+		return nil, fmt.Errorf("value doesn't fit in %d bytes", length)
+	}
+	return full[len(full)-length:], nil
+}
+
+// SetBytesLE interprets a number in little-endian format, stores it in z, and returns z.
+//
+// This is like SetBytes, except for the order of the bytes. The same rules around
+// the length of the buffer apply.
+func (z *Nat) SetBytesLE(buf []byte) *Nat {
+	z.reduced = nil
+	z.announced = 8 * len(buf)
+	z.limbs = z.resizedLimbs(z.announced)
+	for i := 0; i < len(z.limbs); i++ {
+		z.limbs[i] = 0
+	}
+	// LEAK: the length of buf
+	// OK: this is public
+	for i := 0; i < len(buf); i++ {
+		z.limbs[i/_S] |= Word(buf[i]) << (8 * (i % _S))
+	}
+	return z
+}
+
+// BytesLE creates a slice containing the contents of this Nat, in little endian
+//
+// This will always fill the output byte slice based on the announced length of this Nat.
+func (z *Nat) BytesLE() []byte {
+	length := (z.announced + 7) / 8
+	out := make([]byte, length)
+	return z.FillBytesLE(out)
+}
+
 // MarshalBinary implements encoding.BinaryMarshaler.
 // Returns the same value as Bytes().
 func (i *Nat) MarshalBinary() ([]byte, error) {
@@ -387,6 +677,13 @@ func nibbletoASCII(nibble byte) byte {
 	return byte(value)
 }
 
+// convert a 4 bit value into a lowercase ASCII value in constant time
+func nibbletoASCIILower(nibble byte) byte {
+	w := Word(nibble)
+	value := ctIfElse(ctGt(w, 9), w-0xA+Word('a'), w+Word('0'))
+	return byte(value)
+}
+
 // convert an ASCII value into a 4 bit value, returning whether or not this value is valid.
 func nibbleFromASCII(ascii byte) (byte, Choice) {
 	w := Word(ascii)
@@ -405,6 +702,9 @@ func nibbleFromASCII(ascii byte) (byte, Choice) {
 //
 // The value of the string shouldn't be leaked, except in the case where the string
 // contains invalid characters.
+//
+// The announced length of z will be 4 times the length of the string. Each character
+// fills exactly one nibble, so a string of odd length, like "ABC", announces 12 bits.
 func (z *Nat) SetHex(hex string) (*Nat, error) {
 	z.reduced = nil
 	z.announced = 4 * len(hex)
@@ -424,9 +724,203 @@ func (z *Nat) SetHex(hex string) (*Nat, error) {
 	return z, nil
 }
 
+// convert a lowercase ASCII hex letter into uppercase, in constant time, leaving other values unchanged
+func hexToUpper(ascii byte) byte {
+	w := Word(ascii)
+	isLower := ctGt(w, Word('a')-1) & (1 ^ ctGt(w, Word('f')))
+	return byte(ctIfElse(isLower, w-Word('a'-'A'), w))
+}
+
+// SetHexFlexible modifies the value of z to hold a hex string, returning z
+//
+// Unlike SetHex, this accepts a few common variations on the format of the string.
+// Leading and trailing whitespace is trimmed, an optional "0x" or "0X" prefix is then
+// stripped, whitespace and ':' separators are ignored, and lowercase digits are accepted.
+// Whitespace is anything unicode.IsSpace accepts. Any other characters will produce an
+// error, and leave the value of z undefined.
+//
+// The announced length of z will be 4 times the number of hex digits in the string,
+// ignoring the prefix and separators.
+//
+// The value of the digits shouldn't be leaked, but the positions of the separators will be.
+func (z *Nat) SetHexFlexible(hex string) (*Nat, error) {
+	hex = strings.TrimSpace(hex)
+	if strings.HasPrefix(hex, "0x") || strings.HasPrefix(hex, "0X") {
+		hex = hex[2:]
+	}
+	var builder strings.Builder
+	builder.Grow(len(hex))
+	for _, r := range hex {
+		// LEAK: the position of separators
+		// OK: this is part of the formatting of the string, and not the value
+		if r == ':' || unicode.IsSpace(r) {
+			continue
+		}
+		// Anything outside of ASCII isn't a hex digit, and gets rejected by SetHex
+		if r > unicode.MaxASCII {
+			_, _ = builder.WriteRune(r)
+			continue
+		}
+		_ = builder.WriteByte(hexToUpper(byte(r)))
+	}
+	return z.SetHex(builder.String())
+}
+
+// ScanHex reads a hex number from r, modifying z to hold its value, and returning z
+//
+// This follows the same rules as SetHex, except that the number is read from a stream
+// of runes, one at a time. Reading stops at the end of the stream, or at the first
+// whitespace rune, which is consumed, unless r is an io.RuneScanner, in which case it's
+// unread. Any other rune outside of 0..9, A..F will produce an error, and the value
+// of z will be undefined.
+//
+// The announced length of z will be 4 times the number of hex digits consumed.
+//
+// The value of the digits shouldn't be leaked, only how many of them there are,
+// except in the case where the stream contains invalid characters.
+func (z *Nat) ScanHex(r io.RuneReader) (*Nat, error) {
+	const nibblesPerLimb = _W / 4
+	// We accumulate full limbs in big endian order, since we don't know in advance
+	// how many digits there will be, along with the partial limb we're currently filling.
+	var limbsBE []Word
+	var current Word
+	currentNibbles := 0
+	for {
+		ch, _, err := r.ReadRune()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		nibble, valid := nibbleFromASCII(byte(ch))
+		// LEAK: whether or not this rune is a valid digit
+		// OK: this only fails at the end of the number, or on invalid input
+		if ch >= 0x80 || valid != 1 {
+			if unicode.IsSpace(ch) {
+				if s, ok := r.(io.RuneScanner); ok {
+					_ = s.UnreadRune()
+				}
+				break
+			}
+			return nil, fmt.Errorf("invalid hex character: %c", ch)
+		}
+		current = (current << 4) | Word(nibble)
+		currentNibbles++
+		if currentNibbles == nibblesPerLimb {
+			limbsBE = append(limbsBE, current)
+			current = 0
+			currentNibbles = 0
+		}
+	}
+
+	z.reduced = nil
+	z.announced = 4 * (len(limbsBE)*nibblesPerLimb + currentNibbles)
+	// We need one extra limb, to shift the full limbs up by the partial limb.
+	full := z.resizedLimbs(_W * (len(limbsBE) + 1))
+	for i := 0; i < len(limbsBE); i++ {
+		full[i] = limbsBE[len(limbsBE)-1-i]
+	}
+	full[len(limbsBE)] = 0
+	shlVU(full, full, uint(4*currentNibbles))
+	full[0] |= current
+	z.limbs = full
+	z.limbs = z.resizedLimbs(z.announced)
+	return z, nil
+}
+
+// convert an ASCII value into a digit in a given base, returning whether or not this value is valid.
+//
+// Both lowercase and uppercase letters are accepted, for bases up to 36.
+func digitFromASCII(ascii byte, base Word) (Word, Choice) {
+	w := Word(ascii)
+	isDigit := ctGt(w, Word('0')-1) & (1 ^ ctGt(w, Word('9')))
+	isUpper := ctGt(w, Word('A')-1) & (1 ^ ctGt(w, Word('Z')))
+	isLower := ctGt(w, Word('a')-1) & (1 ^ ctGt(w, Word('z')))
+	digit := ctIfElse(isDigit, w-Word('0'), 0)
+	digit |= ctIfElse(isUpper, w-Word('A')+10, 0)
+	digit |= ctIfElse(isLower, w-Word('a')+10, 0)
+	valid := (isDigit | isUpper | isLower) & ctGt(base, digit)
+	return digit, valid
+}
+
+// digitsBitLen calculates the number of bits needed to hold any number with n digits in a given base.
+func digitsBitLen(n int, base int) int {
+	// LEAK: n and base
+	// OK: these are public
+	if base&(base-1) == 0 {
+		return n * bits.TrailingZeros(uint(base))
+	}
+	max := new(big.Int).Exp(big.NewInt(int64(base)), big.NewInt(int64(n)), nil)
+	return max.Sub(max, big.NewInt(1)).BitLen()
+}
+
+// SetString modifies the value of z to hold a string in a given base, returning z
+//
+// The base must be between 2 and 36, or 0. Letters are used for digits above 9,
+// and both lowercase and uppercase letters are accepted. With a base of 0, the base
+// is inferred from the prefix of the string, like with big.Int: "0x" or "0X"
+// selects base 16, "0b" or "0B" selects base 2, "0o", "0O", or just "0" select
+// base 8, and base 10 is used otherwise. Unlike big.Int, underscores aren't accepted.
+//
+// If the string contains invalid characters, the value of z will be undefined,
+// and an error will be returned.
+//
+// The announced length of z will be the number of bits needed to hold any number
+// with as many digits as the string.
+//
+// The value of the digits shouldn't be leaked, only how many of them there are,
+// except in the case where the string contains invalid characters.
+func (z *Nat) SetString(s string, base int) (*Nat, error) {
+	if base == 0 {
+		base = 10
+		if len(s) >= 2 && s[0] == '0' {
+			switch s[1] {
+			case 'x', 'X':
+				base = 16
+				s = s[2:]
+			case 'b', 'B':
+				base = 2
+				s = s[2:]
+			case 'o', 'O':
+				base = 8
+				s = s[2:]
+			default:
+				base = 8
+				s = s[1:]
+			}
+		}
+	}
+	if base < 2 || base > 36 {
+		return nil, fmt.Errorf("invalid base: %d", base)
+	}
+	if len(s) == 0 {
+		return nil, errors.New("no digits in string")
+	}
+
+	z.reduced = nil
+	z.announced = digitsBitLen(len(s), base)
+	z.limbs = z.resizedLimbs(z.announced)
+	for i := 0; i < len(z.limbs); i++ {
+		z.limbs[i] = 0
+	}
+	for i := 0; i < len(s); i++ {
+		digit, valid := digitFromASCII(s[i], Word(base))
+		if valid != 1 {
+			return nil, fmt.Errorf("invalid character in base %d: %q", base, s[i])
+		}
+		// The announced length is large enough that this will never carry out
+		mulAddVWW(z.limbs, z.limbs, Word(base), digit)
+	}
+	return z, nil
+}
+
 // Hex converts this number into a hexadecimal string.
 //
-// This string will be a multiple of 8 bits.
+// This string will be a multiple of 8 bits. If the announced length of this Nat
+// isn't a multiple of 8, e.g. because it was created by SetHex with a string of odd
+// length, the string is padded with a leading 0, so the round trip through SetHex
+// preserves the value, but not necessarily the announced length.
 //
 // This shouldn't leak any information about the value of this Nat, only its length.
 func (z *Nat) Hex() string {
@@ -439,6 +933,22 @@ func (z *Nat) Hex() string {
 	return builder.String()
 }
 
+// HexLower converts this number into a lowercase hexadecimal string.
+//
+// This is like Hex, except that the letters a..f are used instead of A..F.
+// SetHexFlexible can parse the result.
+//
+// This shouldn't leak any information about the value of this Nat, only its length.
+func (z *Nat) HexLower() string {
+	bytes := z.Bytes()
+	var builder strings.Builder
+	for _, b := range bytes {
+		_ = builder.WriteByte(nibbletoASCIILower((b >> 4) & 0xF))
+		_ = builder.WriteByte(nibbletoASCIILower(b & 0xF))
+	}
+	return builder.String()
+}
+
 // the number of bytes to print in the string representation before an underscore
 const underscoreAfterNBytes = 4
 
@@ -478,6 +988,42 @@ func (z *Nat) Byte(i int) byte {
 	return byte(z.limbs[i/bytesPerLimb] >> (8 * (i % bytesPerLimb)))
 }
 
+// Bit will access the ith bit in this nat, with 0 being the least significant bit.
+//
+// Bits past the announced length of z are 0.
+//
+// This will leak the value of i, and panic if i is < 0.
+func (z *Nat) Bit(i int) Choice {
+	if i < 0 {
+		panic("negative bit")
+	}
+	if i >= _W*len(z.limbs) {
+		return 0
+	}
+	return Choice((z.limbs[i/_W] >> (i % _W)) & 1)
+}
+
+// SetBit sets the ith bit of z to b, returning z.
+//
+// If i is past the announced length of z, the announced length grows to i + 1,
+// so that the bit fits, and the bits in between are 0.
+//
+// This will leak the value of i, but not the value of b, and panic if i is < 0.
+func (z *Nat) SetBit(i int, b Choice) *Nat {
+	if i < 0 {
+		panic("negative bit")
+	}
+	if i >= z.announced {
+		z.limbs = z.resizedLimbs(i + 1)
+		z.announced = i + 1
+	}
+	limb := &z.limbs[i/_W]
+	mask := Word(1) << (i % _W)
+	*limb = ctIfElse(b, *limb|mask, *limb&^mask)
+	z.reduced = nil
+	return z
+}
+
 // Big converts a Nat into a big.Int
 //
 // This will leak information about the true size of z, so caution
@@ -499,6 +1045,7 @@ func (z *Nat) Big() *big.Int {
 func (z *Nat) SetBig(x *big.Int, size int) *Nat {
 	z.announced = size
 	z.limbs = z.resizedLimbs(size)
+	z.reduced = nil
 	bigLimbs := x.Bits()
 	for i := 0; i < len(z.limbs) && i < len(bigLimbs); i++ {
 		z.limbs[i] = Word(bigLimbs[i])
@@ -516,7 +1063,7 @@ func (z *Nat) SetUint64(x uint64) *Nat {
 	z.limbs = z.resizedLimbs(z.announced)
 	for i := 0; i < len(z.limbs); i++ {
 		z.limbs[i] = Word(x)
-		x >>= _W
+		x = x >> 32 >> (_W - 32)
 	}
 	return z
 }
@@ -527,7 +1074,7 @@ func (z *Nat) SetUint64(x uint64) *Nat {
 func (z *Nat) Uint64() uint64 {
 	var ret uint64
 	for i := len(z.limbs) - 1; i >= 0; i-- {
-		ret = (ret << _W) | uint64(z.limbs[i])
+		ret = (ret << 32 << (_W - 32)) | uint64(z.limbs[i])
 	}
 	return ret
 }
@@ -553,6 +1100,11 @@ func (z *Nat) Clone() *Nat {
 // Resize resizes z to a certain number of bits, returning z.
 func (z *Nat) Resize(cap int) *Nat {
 	z.limbs = z.resizedLimbs(cap)
+	// A reduced value always has the same size as its modulus, so changing the
+	// size means we can no longer claim to be reduced.
+	if z.reduced != nil && cap != z.reduced.nat.announced {
+		z.reduced = nil
+	}
 	z.announced = cap
 	return z
 }
@@ -572,6 +1124,14 @@ type Modulus struct {
 	m0inv Word
 	// If true, then this modulus is even
 	even bool
+	// If true, then this modulus is a power of two
+	powerOfTwo bool
+	// A cached *Nat, holding a quadratic non-residue, see QuadraticNonResidue
+	nonResidue atomic.Value
+	// A cached []Word, holding the constant for Barrett reduction, see barrettMu
+	mu atomic.Value
+	// A cached []Word, holding R^2 mod m, see rSquared
+	r2 atomic.Value
 }
 
 // invertModW calculates x^-1 mod _W
@@ -589,25 +1149,47 @@ func invertModW(x Word) Word {
 // This sets the leading number of bits, leaking the true bit size of m,
 // as well as the inverse of the least significant limb (without leaking it).
 //
-// This will also do integrity checks, namely that the modulus isn't empty or even
+// This will also do integrity checks, namely that the modulus isn't empty,
+// panicking if this isn't the case.
 func (m *Modulus) precomputeValues() {
+	if err := m.precomputeValuesChecked(); err != nil {
+		panic(err)
+	}
+}
+
+// precomputeValuesChecked is like precomputeValues, but returns an error instead of panicking.
+func (m *Modulus) precomputeValuesChecked() error {
 	announced := m.nat.TrueLen()
 	m.nat.announced = announced
 	m.nat.limbs = m.nat.resizedLimbs(announced)
 	if len(m.nat.limbs) < 1 {
-		panic("Modulus is empty")
+		return errors.New("modulus is empty")
 	}
+	m.nonResidue = atomic.Value{}
+	m.mu = atomic.Value{}
+	m.r2 = atomic.Value{}
 	m.leading = leadingZeros(m.nat.limbs[len(m.nat.limbs)-1])
 	// I think checking the bit directly might leak more data than we'd like
 	m.even = ctEq(m.nat.limbs[0]&1, 0) == 1
 	// There's no point calculating this if m isn't even, and we can leak evenness
+	// LEAK: whether or not m is a power of two
+	// OK: moduli are allowed to leak this, like their evenness
+	ones := 0
+	for _, limb := range m.nat.limbs {
+		ones += bits.OnesCount(uint(limb))
+	}
+	m.powerOfTwo = ones == 1
+	m.m0inv = 0
 	if !m.even {
 		m.m0inv = invertModW(m.nat.limbs[0])
 		m.m0inv = -m.m0inv
 	}
+	return nil
 }
 
 // ModulusFromUint64 sets the modulus according to an integer
+//
+// This will panic if x is 0. See ModulusFromUint64Checked for a variant returning an error.
 func ModulusFromUint64(x uint64) *Modulus {
 	var m Modulus
 	m.nat.SetUint64(x)
@@ -615,10 +1197,23 @@ func ModulusFromUint64(x uint64) *Modulus {
 	return &m
 }
 
+// ModulusFromUint64Checked is like ModulusFromUint64, but returns an error if x is 0.
+func ModulusFromUint64Checked(x uint64) (*Modulus, error) {
+	var m Modulus
+	m.nat.SetUint64(x)
+	if err := m.precomputeValuesChecked(); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
 // ModulusFromBytes creates a new Modulus, converting from big endian bytes
 //
 // This function will remove leading zeros, thus leaking the true size of the modulus.
 // See the documentation for the Modulus type, for more information about this contract.
+//
+// This will panic if the bytes represent 0. See ModulusFromBytesChecked for a variant
+// returning an error instead, which is more appropriate for untrusted input.
 func ModulusFromBytes(bytes []byte) *Modulus {
 	var m Modulus
 	// TODO: You could allocate a smaller buffer to begin with, versus using the Nat method
@@ -627,19 +1222,33 @@ func ModulusFromBytes(bytes []byte) *Modulus {
 	return &m
 }
 
+// ModulusFromBytesChecked is like ModulusFromBytes, but returns an error if the bytes represent 0.
+func ModulusFromBytesChecked(bytes []byte) (*Modulus, error) {
+	var m Modulus
+	m.nat.SetBytes(bytes)
+	if err := m.precomputeValuesChecked(); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
 // ModulusFromHex creates a new modulus from a hex string.
 //
 // The same rules as Nat.SetHex apply.
 //
 // Additionally, this function will remove leading zeros, leaking the true size of the modulus.
 // See the documentation for the Modulus type, for more information about this contract.
+//
+// An error is returned if the string represents 0.
 func ModulusFromHex(hex string) (*Modulus, error) {
 	var m Modulus
 	_, err := m.nat.SetHex(hex)
 	if err != nil {
 		return nil, err
 	}
-	m.precomputeValues()
+	if err := m.precomputeValuesChecked(); err != nil {
+		return nil, err
+	}
 	return &m, nil
 }
 
@@ -648,6 +1257,8 @@ func ModulusFromHex(hex string) (*Modulus, error) {
 // This will leak the true size of this natural number. Because of this,
 // the true size of the number should not be sensitive information. This is
 // a stronger requirement than we usually have for Nat.
+//
+// This will panic if nat is 0. See ModulusFromNatChecked for a variant returning an error.
 func ModulusFromNat(nat *Nat) *Modulus {
 	var m Modulus
 	m.nat.SetNat(nat)
@@ -655,6 +1266,16 @@ func ModulusFromNat(nat *Nat) *Modulus {
 	return &m
 }
 
+// ModulusFromNatChecked is like ModulusFromNat, but returns an error if nat is 0.
+func ModulusFromNatChecked(nat *Nat) (*Modulus, error) {
+	var m Modulus
+	m.nat.SetNat(nat)
+	if err := m.precomputeValuesChecked(); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
 // Nat returns the value of this modulus as a Nat.
 //
 // This will create a copy of this modulus value, so the Nat can be safely
@@ -674,10 +1295,11 @@ func (i *Modulus) MarshalBinary() ([]byte, error) {
 }
 
 // UnmarshalBinary implements encoding.BinaryUnmarshaler.
+//
+// Returns an error if data represents 0.
 func (i *Modulus) UnmarshalBinary(data []byte) error {
 	i.nat.SetBytes(data)
-	i.precomputeValues()
-	return nil
+	return i.precomputeValuesChecked()
 }
 
 // Big returns the value of this Modulus as a big.Int
@@ -709,6 +1331,17 @@ func (m *Modulus) BitLen() int {
 	return m.nat.announced
 }
 
+// NewZero creates a Nat holding 0, with the same capacity as this modulus.
+//
+// Unlike NewZero(m.BitLen()), the result is marked as already being reduced modulo m,
+// which lets operations like ModAdd skip reducing it. This makes this function
+// the natural starting point for accumulators, e.g. when summing values modulo m.
+func (m *Modulus) NewZero() *Nat {
+	z := NewZero(m.nat.announced)
+	z.reduced = m
+	return z
+}
+
 // Cmp compares two moduli, returning results for (>, =, <).
 //
 // This will not leak information about the value of these relations, or the moduli.
@@ -716,7 +1349,112 @@ func (m *Modulus) Cmp(n *Modulus) (Choice, Choice, Choice) {
 	return m.nat.Cmp(&n.nat)
 }
 
-// shiftAddInCommon exists to unify behavior between shiftAddIn and shiftAddInGeneric
+// Equal checks whether or not two moduli are equal.
+//
+// This is like taking the equality result of Cmp, and won't leak whether or
+// not the moduli are equal.
+func (m *Modulus) Equal(n *Modulus) Choice {
+	_, eq, _ := m.Cmp(n)
+	return eq
+}
+
+// EqualVartime checks whether or not two moduli are equal.
+//
+// LEAK: This function is *not* constant-time. Moduli are usually public, so this
+// can be used to check that one matches an expected value, e.g. when validating
+// parameters.
+func (m *Modulus) EqualVartime(n *Modulus) bool {
+	return m.nat.CmpVartime(&n.nat) == 0
+}
+
+// QuadraticNonResidue returns a fixed quadratic non-residue modulo m.
+//
+// The modulus must be an odd prime. This returns the smallest number n >= 2
+// whose Legendre symbol modulo m is -1. This value is only calculated once,
+// and then cached for subsequent calls.
+//
+// Assuming the generalized Riemann hypothesis, the smallest non-residue modulo
+// a prime is below 2 ln(m)^2, so only the numbers below m.BitLen()^2 are checked.
+// If no non-residue is found, e.g. because m = 2, or because m isn't prime,
+// then this returns nil.
+//
+// This function will leak information about the modulus, but not about any
+// other value.
+func (m *Modulus) QuadraticNonResidue() *Nat {
+	if cached, ok := m.nonResidue.Load().(*Nat); ok {
+		// A nil value means that we already searched, without finding anything
+		if cached == nil {
+			return nil
+		}
+		return new(Nat).SetNat(cached)
+	}
+	one := new(Nat).SetUint64(1)
+	pMinusOne := new(Nat).Sub(&m.nat, one, m.BitLen())
+	halfPMinusOne := new(Nat).Rsh(pMinusOne, 1, m.BitLen())
+	scratch := new(Nat)
+	limit := new(Nat).SetUint64(uint64(m.BitLen()) * uint64(m.BitLen()))
+	var found *Nat
+	// LEAK: the value of the non-residue
+	// OK: this is a function of the modulus, which is public
+	for n := new(Nat).SetUint64(2); n.CmpVartime(&m.nat) < 0 && n.CmpVartime(limit) < 0; n.Add(n, one, m.BitLen()) {
+		if scratch.Exp(n, halfPMinusOne, m).Eq(pMinusOne) == 1 {
+			found = n.Resize(m.BitLen())
+			found.reduced = m
+			break
+		}
+	}
+	m.nonResidue.Store(found)
+	if found == nil {
+		return nil
+	}
+	return new(Nat).SetNat(found)
+}
+
+// TotientPrime returns phi(m) = m - 1, Euler's totient function, assuming that m is prime.
+//
+// The primality of m isn't checked, and the result is meaningless if m isn't prime.
+//
+// The capacity of the result matches that of the modulus.
+func (m *Modulus) TotientPrime() *Nat {
+	one := new(Nat).SetUint64(1)
+	return new(Nat).Sub(&m.nat, one, m.nat.announced)
+}
+
+// TotientFromFactors calculates Euler's totient function phi(n), given the prime factorization of n.
+//
+// factors should contain each prime factor of n, repeated according to its multiplicity.
+// For example, n = 2^3 * 5 would be passed as [2, 2, 2, 5]. The primality of the factors
+// isn't checked, and the result is meaningless if they aren't prime.
+//
+// The result is calculated as the product of (p - 1) * p^(e - 1), for each distinct
+// prime p with multiplicity e.
+//
+// LEAK: This function is not constant-time, and will leak which factors are equal.
+// The factors are treated as public information.
+//
+// The capacity of the result will be 1 more than the sum of the bit lengths of the factors.
+func TotientFromFactors(factors []*Modulus) *Nat {
+	one := new(Nat).SetUint64(1)
+	out := new(Nat).SetUint64(1).Resize(1)
+	for i, p := range factors {
+		seen := false
+		for _, q := range factors[:i] {
+			if p.nat.CmpVartime(&q.nat) == 0 {
+				seen = true
+				break
+			}
+		}
+		// The first occurrence contributes p - 1, and the other occurrences contribute p.
+		if seen {
+			out.Mul(out, p.Nat(), -1)
+		} else {
+			out.Mul(out, new(Nat).Sub(&p.nat, one, p.nat.announced), -1)
+		}
+	}
+	return out
+}
+
+// shiftAddInCommon exists to unify behavior between shiftAddIn and shiftAddInGeneric
 //
 // z, scratch, and m should have the same length.
 //
@@ -855,6 +1593,96 @@ func shiftAddInGeneric(z, scratch []Word, x Word, m []Word) Word {
 	return shiftAddInCommon(z, scratch, m, hi, a2, a1, b1)
 }
 
+// reduceLimbs calculates out <- x mod m
+//
+// out and scratch should have the same length as m, and not alias x.
+//
+// LEAK: the length of x, and of m
+func reduceLimbs(out, scratch, x []Word, m *Modulus) {
+	size := len(out)
+	for i := 0; i < size; i++ {
+		out[i] = 0
+	}
+	// Multiple times in this section:
+	// LEAK: the length of x
+	// OK: this is public information
+	i := len(x) - 1
+	// We can inject at least size - 1 limbs while staying under m
+	// Thus, we start injecting from index size - 2
+	start := size - 2
+	// That is, if there are at least that many limbs to choose from
+	if i < start {
+		start = i
+	}
+	for j := start; j >= 0; j-- {
+		out[j] = x[i]
+		i--
+	}
+	// We shift in the remaining limbs, making sure to reduce modulo M each time
+	for ; i >= 0; i-- {
+		shiftAddIn(out, scratch, x[i], m)
+	}
+}
+
+// truncated returns a copy of x, truncated to a certain number of bits
+//
+// Unlike Resize, this never modifies x.
+func truncated(x *Nat, bits int) *Nat {
+	out := new(Nat)
+	out.limbs = maskedLimbs(x, bits)
+	out.announced = bits
+	return out
+}
+
+// modPowerOfTwo calculates z <- x mod m, when m is a power of two
+//
+// This only requires masking off the high bits of x.
+func (z *Nat) modPowerOfTwo(x *Nat, m *Modulus) *Nat {
+	z.limbs = maskedLimbs(x, m.BitLen()-1)
+	z.limbs = z.resizedLimbs(m.nat.announced)
+	z.announced = m.nat.announced
+	z.reduced = m
+	return z
+}
+
+// Canonicalize brings z into the range [0, m), assuming it's in the range [0, 2m).
+//
+// This performs a single conditional subtraction of m, which is much cheaper than
+// a full reduction. This is useful to establish the canonical representative of
+// a value after operations which might leave it in [m, 2m).
+//
+// This will produce nonsense if z isn't in the range [0, 2m).
+//
+// This doesn't leak the value of z, only its announced length.
+//
+// The capacity of the resulting number matches the capacity of the modulus
+func (z *Nat) Canonicalize(m *Modulus) *Nat {
+	size := len(m.nat.limbs)
+	// Since z < 2m, z fits in size + 1 limbs, and we use the rest as scratch space
+	scratch := z.resizedLimbs(_W * (2*size + 1))
+	hi := scratch[size]
+	subResult := scratch[size+1:]
+	// Like in montgomeryMul, since z < 2m, we keep the subtraction exactly when hi == c
+	c := subVV(subResult, scratch[:size], m.nat.limbs)
+	ctCondCopy(ctEq(hi, c), scratch[:size], subResult)
+	z.limbs = scratch[:size]
+	z.limbs = z.resizedLimbs(m.nat.announced)
+	z.announced = m.nat.announced
+	z.reduced = m
+	return z
+}
+
+// reducedLimbs returns the limbs of x, reduced modulo m.
+//
+// If x is already reduced modulo m, its limbs are returned directly, without
+// making a copy. Otherwise, a new buffer holding x mod m is returned.
+func (x *Nat) reducedLimbs(m *Modulus) []Word {
+	if x.reduced == m {
+		return x.limbs
+	}
+	return new(Nat).Mod(x, m).limbs
+}
+
 // Mod calculates z <- x mod m
 //
 // The capacity of the resulting number matches the capacity of the modulus.
@@ -863,37 +1691,177 @@ func (z *Nat) Mod(x *Nat, m *Modulus) *Nat {
 		z.SetNat(x)
 		return z
 	}
+	if m.powerOfTwo {
+		return z.modPowerOfTwo(x, m)
+	}
 	size := len(m.nat.limbs)
 	xLimbs := x.unaliasedLimbs(z)
 	z.limbs = z.resizedLimbs(2 * _W * size)
-	for i := 0; i < len(z.limbs); i++ {
-		z.limbs[i] = 0
+	reduceLimbs(z.limbs[:size], z.limbs[size:], xLimbs, m)
+	z.limbs = z.resizedLimbs(m.nat.announced)
+	z.announced = m.nat.announced
+	z.reduced = m
+	return z
+}
+
+// PowerOfTwoDivMod calculates 2^twoPower / m, and 2^twoPower mod m, returning the quotient and the remainder.
+//
+// Instead of creating a Nat holding 2^twoPower, and then dividing it by m, the limbs
+// of the dividend are produced as the division needs them, since all but one of them are 0.
+// This is useful to precompute the constants used by reduction algorithms, like
+// the one used by Barrett reduction, or R^2 mod m for Montgomery multiplication.
+//
+// The capacity of the quotient is twoPower + 1 bits, and the capacity of the
+// remainder matches the capacity of the modulus.
+//
+// This will leak twoPower, which should be public, but not the value of m.
+//
+// This panics if twoPower < 0.
+func PowerOfTwoDivMod(twoPower int, m *Modulus) (*Nat, *Nat) {
+	if twoPower < 0 {
+		panic("PowerOfTwoDivMod: negative power")
 	}
-	// Multiple times in this section:
-	// LEAK: the length of x
-	// OK: this is public information
-	i := len(xLimbs) - 1
-	// We can inject at least size - 1 limbs while staying under m
-	// Thus, we start injecting from index size - 2
+	size := len(m.nat.limbs)
+	top := twoPower / _W
+	// LEAK: the position of the single bit set in the dividend
+	// OK: twoPower is public
+	limb := func(i int) Word {
+		if i == top {
+			return Word(1) << (twoPower % _W)
+		}
+		return 0
+	}
+
+	remainder := make([]Word, size)
+	scratch := make([]Word, size)
+	quotient := make([]Word, top+1)
+
+	i := top
+	// Like in Div, we can inject size - 1 limbs for free, while staying under m
 	start := size - 2
-	// That is, if there are at least that many limbs to choose from
 	if i < start {
 		start = i
 	}
 	for j := start; j >= 0; j-- {
-		z.limbs[j] = xLimbs[i]
+		remainder[j] = limb(i)
 		i--
 	}
-	// We shift in the remaining limbs, making sure to reduce modulo M each time
 	for ; i >= 0; i-- {
-		shiftAddIn(z.limbs[:size], z.limbs[size:], xLimbs[i], m)
+		quotient[i] = shiftAddIn(remainder, scratch, limb(i), m)
+	}
+
+	q := &Nat{limbs: quotient, announced: _W * len(quotient)}
+	r := &Nat{limbs: remainder, announced: m.nat.announced, reduced: m}
+	return q.Resize(twoPower + 1), r
+}
+
+// barrettMu returns floor(2^(2 * _W * size) / m), with size the number of limbs in m.
+//
+// This is calculated once, and then cached. The result has size + 2 limbs.
+func (m *Modulus) barrettMu() []Word {
+	if cached, ok := m.mu.Load().([]Word); ok {
+		return cached
+	}
+	size := len(m.nat.limbs)
+	quotient, _ := PowerOfTwoDivMod(2*_W*size, m)
+	// Since m >= 2^(_W * (size - 1)), this quotient fits in _W * (size + 1) + 1 bits
+	mu := quotient.Resize(_W*(size+1) + 1).limbs
+	m.mu.Store(mu)
+	return mu
+}
+
+// barrettScratchSize returns the number of limbs barrettReduce needs as scratch space.
+func barrettScratchSize(size int, mu []Word) int {
+	return (size + 1) + (size + 1) + len(mu) + (size + 1)
+}
+
+// barrettReduce calculates out <- x mod m, using Barrett reduction.
+//
+// x must have twice as many limbs as m, and will be clobbered. out must have
+// as many limbs as m. mu should be m.barrettMu(), and scratch should have
+// barrettScratchSize limbs.
+func barrettReduce(out, x, scratch, mu []Word, m *Modulus) {
+	size := len(m.nat.limbs)
+	for i := 0; i < len(scratch); i++ {
+		scratch[i] = 0
+	}
+	// c.f. Handbook of Applied Cryptography, Algorithm 14.42
+	mPadded := scratch[:size+1]
+	r := scratch[size+1 : 2*size+2]
+	// Holds q1 * mu, which we then shift to get q3
+	q2 := scratch[2*size+2:]
+	copy(mPadded, m.nat.limbs)
+
+	// q1 = floor(x / b^(size - 1))
+	q1 := x[size-1:]
+	for i := 0; i < len(q1); i++ {
+		q2[i+len(mu)] = addMulVVW(q2[i:i+len(mu)], mu, q1[i])
+	}
+	// q3 = floor(q2 / b^(size + 1)), of which we only need the lower size + 1 limbs
+	q3 := q2[size+1 : 2*size+2]
+
+	// r = (x - q3 * m) mod b^(size + 1)
+	for i := 0; i < len(r); i++ {
+		addMulVVW(r[i:], mPadded, q3[i])
+	}
+	subVV(r, x[:size+1], r)
+
+	// At this point r < 3m, so two conditional subtractions suffice.
+	// q1 is no longer needed, so we can use it as scratch space.
+	for i := 0; i < 2; i++ {
+		borrow := subVV(q1, r, mPadded)
+		ctCondCopy(1^Choice(borrow), r, q1)
+	}
+	copy(out, r)
+}
+
+// ModWide calculates z <- wide mod m, for a number at most twice as large as m
+//
+// This is meant to reduce the result of multiplying two numbers modulo m,
+// for callers doing the multiplication themselves. Instead of shifting in
+// the limbs of wide one at a time, like Mod, this uses a single pass of Barrett
+// reduction. The constant needed for this is calculated once per modulus.
+//
+// If wide has more than twice as many limbs as m, this falls back to Mod.
+//
+// The capacity of the resulting number matches the capacity of the modulus.
+func (z *Nat) ModWide(wide *Nat, m *Modulus) *Nat {
+	size := len(m.nat.limbs)
+	// LEAK: the announced length of wide
+	// OK: this is public
+	if len(wide.limbs) > 2*size || wide.reduced == m {
+		return z.Mod(wide, m)
 	}
+	mu := m.barrettMu()
+	scratch := make([]Word, 3*size+barrettScratchSize(size, mu))
+	x := scratch[:2*size]
+	copy(x, wide.limbs)
+	r := scratch[2*size : 3*size]
+	barrettReduce(r, x, scratch[3*size:], mu, m)
+
+	z.limbs = z.resizedLimbs(_W * size)
+	copy(z.limbs, r)
 	z.limbs = z.resizedLimbs(m.nat.announced)
 	z.announced = m.nat.announced
 	z.reduced = m
 	return z
 }
 
+// ModPair calculates x mod p and x mod q, returning both results.
+//
+// The first result, x mod p, is stored in z, and the second result is a new Nat.
+//
+// This is useful for CRT based computations, where the same value needs to be
+// reduced by each factor of a modulus.
+//
+// The capacity of each result matches the capacity of the corresponding modulus.
+func (z *Nat) ModPair(x *Nat, p *Modulus, q *Modulus) (*Nat, *Nat) {
+	// We need to reduce by q first, since z might alias x.
+	xModQ := new(Nat).Mod(x, q)
+	z.Mod(x, p)
+	return z, xModQ
+}
+
 // Div calculates z <- x / m, with m a Modulus.
 //
 // This might seem like an odd signature, but by using a Modulus,
@@ -901,10 +1869,16 @@ func (z *Nat) Mod(x *Nat, m *Modulus) *Nat {
 // an arbitrary Nat.
 //
 // cap determines the number of bits to keep in the result. If cap < 0, then
-// the number of bits will be x.AnnouncedLen() - m.BitLen() + 2
+// the number of bits will be x.AnnouncedLen() - m.BitLen() + 2, or 1, whichever
+// is larger. Since m >= 2^(m.BitLen() - 1), the quotient is always
+// < 2^(x.AnnouncedLen() - m.BitLen() + 1), so this capacity never truncates it.
 func (z *Nat) Div(x *Nat, m *Modulus, cap int) *Nat {
 	if cap < 0 {
 		cap = x.announced - m.nat.announced + 2
+		// If x is much smaller than m, the quotient is 0, but we still want a valid size
+		if cap < 1 {
+			cap = 1
+		}
 	}
 	if len(x.limbs) < len(m.nat.limbs) || x.reduced == m {
 		z.limbs = z.resizedLimbs(cap)
@@ -969,17 +1943,78 @@ func (z *Nat) Div(x *Nat, m *Modulus, cap int) *Nat {
 	return z
 }
 
+// DivNat calculates x / d and x mod d, returning the quotient and the remainder.
+//
+// The quotient is stored in z, and a new Nat is created for the remainder.
+// Unlike Div, the divisor doesn't need to be a Modulus, which avoids the cost
+// of creating one when only a single division is needed.
+//
+// cap determines the number of bits to keep in the quotient. If cap < 0, then
+// the number of bits will be x.AnnouncedLen(). The capacity of the remainder
+// matches the capacity of d.
+//
+// This function will leak the true number of limbs in d, but not the value of x.
+//
+// This function panics if d is zero.
+func (z *Nat) DivNat(x *Nat, d *Nat, cap int) (*Nat, *Nat) {
+	if cap < 0 {
+		cap = x.announced
+	}
+	// LEAK: the true number of limbs in d
+	// OK: this is documented
+	dLimbs := d.limbs[:trueSize(d.limbs)]
+	if len(dLimbs) == 0 {
+		panic("DivNat: division by zero")
+	}
+	// divDouble leaves the top limbs of the quotient untouched, so they need to start zeroed
+	quotient := make([]Word, len(x.limbs))
+	r := divDouble(x.limbs, dLimbs, quotient)
+
+	z.limbs = quotient
+	z.announced = len(quotient) * _W
+	z.reduced = nil
+	z.Resize(cap)
+
+	remainder := &Nat{limbs: r, announced: len(r) * _W}
+	return z, remainder.Resize(d.announced)
+}
+
+// ModByNat calculates z <- x mod modulus, with modulus an arbitrary Nat.
+//
+// This avoids creating a Modulus, which is convenient for a single reduction.
+// Repeated reductions by the same number should still use a Modulus, since
+// Mod is faster, and the precomputation is shared between calls.
+//
+// The capacity of the resulting number matches the capacity of modulus.
+//
+// This function will leak the true number of limbs in modulus, but not the value of x.
+//
+// This function panics if modulus is zero.
+func (z *Nat) ModByNat(x *Nat, modulus *Nat) *Nat {
+	// LEAK: the true number of limbs in modulus
+	// OK: this is documented
+	mLimbs := modulus.limbs[:trueSize(modulus.limbs)]
+	if len(mLimbs) == 0 {
+		panic("ModByNat: division by zero")
+	}
+	announced := modulus.announced
+	r := divDouble(x.limbs, mLimbs, nil)
+	z.limbs = r
+	z.announced = len(r) * _W
+	z.reduced = nil
+	return z.Resize(announced)
+}
+
 // ModAdd calculates z <- x + y mod m
 //
 // The capacity of the resulting number matches the capacity of the modulus.
 func (z *Nat) ModAdd(x *Nat, y *Nat, m *Modulus) *Nat {
-	var xModM, yModM Nat
 	// This is necessary for the correctness of the algorithm, since
 	// we don't assume that x and y are in range.
 	// Furthermore, we can now assume that x and y have the same number
 	// of limbs as m
-	xModM.Mod(x, m)
-	yModM.Mod(y, m)
+	xLimbs := x.reducedLimbs(m)
+	yLimbs := y.reducedLimbs(m)
 
 	// The only thing we have to resize is z, everything else has m's length
 	size := limbCount(m.nat.announced)
@@ -989,7 +2024,7 @@ func (z *Nat) ModAdd(x *Nat, y *Nat, m *Modulus) *Nat {
 	z.limbs = scratch[:size]
 	subResult := scratch[size:]
 
-	addCarry := addVV(z.limbs, xModM.limbs, yModM.limbs)
+	addCarry := addVV(z.limbs, xLimbs, yLimbs)
 	subCarry := subVV(subResult, z.limbs, m.nat.limbs)
 	// Three cases are possible:
 	//
@@ -1013,17 +2048,16 @@ func (z *Nat) ModAdd(x *Nat, y *Nat, m *Modulus) *Nat {
 }
 
 func (z *Nat) ModSub(x *Nat, y *Nat, m *Modulus) *Nat {
-	var xModM, yModM Nat
 	// First reduce x and y mod m
-	xModM.Mod(x, m)
-	yModM.Mod(y, m)
+	xLimbs := x.reducedLimbs(m)
+	yLimbs := y.reducedLimbs(m)
 
 	size := len(m.nat.limbs)
 	scratch := z.resizedLimbs(_W * 2 * size)
 	z.limbs = scratch[:size]
 	addResult := scratch[size:]
 
-	subCarry := subVV(z.limbs, xModM.limbs, yModM.limbs)
+	subCarry := subVV(z.limbs, xLimbs, yLimbs)
 	underflow := ctEq(subCarry, 1)
 	addVV(addResult, z.limbs, m.nat.limbs)
 	ctCondCopy(underflow, z.limbs, addResult)
@@ -1056,6 +2090,118 @@ func (z *Nat) ModNeg(x *Nat, m *Modulus) *Nat {
 	return z
 }
 
+// LazyNat accumulates a sum of residues modulo m, only reducing it at the end.
+//
+// Each call to ModAdd needs to conditionally subtract m from the result, but
+// the sum of k numbers in [0, m) is < k * m, and fits in a slightly larger buffer.
+// This defers the reduction to a single call to Reduce, which is useful when
+// calculating inner products, or evaluating polynomials.
+//
+// A LazyNat is created with NewLazyNat.
+type LazyNat struct {
+	m *Modulus
+	// The number of additions this accumulator was created with
+	count int
+	// The number of additions which can still be made without overflowing
+	remaining int
+	// The unreduced sum, with one more limb than m
+	acc []Word
+}
+
+// NewLazyNat creates a new accumulator, which can add up to count residues modulo m.
+//
+// The accumulator starts out at 0.
+//
+// This panics if count < 0.
+func NewLazyNat(m *Modulus, count int) *LazyNat {
+	if count < 0 {
+		panic("NewLazyNat: negative count")
+	}
+	// The extra limb can hold at least 2^_W - 1 carries, and count is smaller
+	// than that, since it fits in an int.
+	return &LazyNat{m: m, count: count, remaining: count, acc: make([]Word, len(m.nat.limbs)+1)}
+}
+
+// AddReduced adds x into this accumulator, without reducing the sum, returning l.
+//
+// x is expected to already be reduced modulo m, e.g. because it's the result of
+// one of the modular operations. If it isn't, it gets reduced first, which is slower.
+//
+// This doesn't leak the value of x, or of the sum, only the number of additions.
+//
+// This panics if more additions are made than the count the accumulator was created with.
+func (l *LazyNat) AddReduced(x *Nat) *LazyNat {
+	if l.remaining <= 0 {
+		panic("AddReduced: too many additions")
+	}
+	l.remaining--
+	size := len(l.m.nat.limbs)
+	carry := addVV(l.acc[:size], l.acc[:size], x.reducedLimbs(l.m))
+	l.acc[size] += carry
+	return l
+}
+
+// Reduce returns the sum of the residues added so far, modulo m.
+//
+// The accumulator is left untouched, and can still be added to afterwards.
+//
+// Since the sum is < count * m, this only needs to conditionally subtract
+// m * 2^j for each bit j of the count, rather than doing a full reduction.
+//
+// LEAK: the count
+// OK: this was chosen by the caller
+//
+// The capacity of the resulting number matches the capacity of the modulus.
+func (l *LazyNat) Reduce() *Nat {
+	size := len(l.m.nat.limbs)
+	sum := make([]Word, size+1)
+	copy(sum, l.acc)
+	shifted := make([]Word, size+1)
+	scratch := make([]Word, size+1)
+	// Before each iteration, we have sum < m * 2^(j + 1)
+	for j := bits.Len(uint(l.count)) - 1; j >= 0; j-- {
+		shifted[size] = shlVU(shifted[:size], l.m.nat.limbs, uint(j))
+		borrow := subVV(scratch, sum, shifted)
+		ctCondCopy(Choice(1^borrow), sum, scratch)
+	}
+	z := new(Nat)
+	z.limbs = sum[:size]
+	z.announced = l.m.nat.announced
+	z.reduced = l.m
+	return z
+}
+
+// ModNegCond calculates z <- -x mod m if yes == 1, and z <- x mod m otherwise.
+//
+// This doesn't leak the value of yes, and only reduces x once, unlike
+// calling ModNeg followed by CondAssign.
+//
+// The capacity of the resulting number matches the capacity of the modulus
+func (z *Nat) ModNegCond(yes Choice, x *Nat, m *Modulus) *Nat {
+	// First reduce x mod m
+	z.Mod(x, m)
+
+	size := len(m.nat.limbs)
+	scratch := z.resizedLimbs(_W * 3 * size)
+	z.limbs = scratch[:size]
+	negated := scratch[size : 2*size]
+	withM := scratch[2*size:]
+	for i := 0; i < len(negated); i++ {
+		negated[i] = 0
+	}
+
+	borrow := subVV(negated, negated, z.limbs)
+	underflow := ctEq(Word(borrow), 1)
+	// Add back M if we underflowed
+	addVV(withM, negated, m.nat.limbs)
+	ctCondCopy(underflow, negated, withM)
+	ctCondCopy(yes, z.limbs, negated)
+
+	z.reduced = m
+	z.announced = m.nat.announced
+	return z
+}
+
 // Add calculates z <- x + y, modulo 2^cap
 //
 // The capacity is given in bits, and also controls the size of the result.
@@ -1076,6 +2222,44 @@ func (z *Nat) Add(x *Nat, y *Nat, cap int) *Nat {
 	return z
 }
 
+// AddShifted calculates z <- z + x * 2^(wordOffset * WordBits), modulo 2^cap
+//
+// In other words, x is added into the limbs of z, starting at limb wordOffset,
+// with carries propagating into the higher limbs. This is useful to build
+// multiplication routines out of single limb products, like those of MulAddWord.
+//
+// If cap < 0, the capacity will be max(z.AnnouncedLen(), x.AnnouncedLen() + wordOffset * WordBits) + 1
+//
+// This will leak the value of wordOffset, which should be public.
+//
+// This panics if wordOffset < 0.
+func (z *Nat) AddShifted(x *Nat, wordOffset int, cap int) *Nat {
+	if wordOffset < 0 {
+		panic("AddShifted: negative offset")
+	}
+	if cap < 0 {
+		cap = x.announced + wordOffset*_W
+		if z.announced > cap {
+			cap = z.announced
+		}
+		cap++
+	}
+	// We copy x before resizing z, since the two might be aliased
+	xLimbs := maskedLimbs(x, cap)
+	z.limbs = z.resizedLimbs(cap)
+	// LEAK: wordOffset
+	// OK: this is public
+	if wordOffset < len(z.limbs) {
+		window := z.limbs[wordOffset:]
+		addVV(window, window, xLimbs[:len(window)])
+	}
+	// Mask off the final bits
+	z.limbs = z.resizedLimbs(cap)
+	z.announced = cap
+	z.reduced = nil
+	return z
+}
+
 // Sub calculates z <- x - y, modulo 2^cap
 //
 // The capacity is given in bits, and also controls the size of the result.
@@ -1096,30 +2280,116 @@ func (z *Nat) Sub(x *Nat, y *Nat, cap int) *Nat {
 	return z
 }
 
-// montgomeryRepresentation calculates zR mod m
-func montgomeryRepresentation(z []Word, scratch []Word, m *Modulus) {
-	// Our strategy is to shift by W, n times, each time reducing modulo m
-	size := len(m.nat.limbs)
-	// LEAK: the size of the modulus
-	// OK: this is public
-	for i := 0; i < size; i++ {
-		shiftAddIn(z, scratch, 0, m)
-	}
+// maskedLimbs returns a copy of the limbs of x, truncated to cap bits.
+//
+// Unlike resizedLimbs, this never modifies x.
+func maskedLimbs(x *Nat, cap int) []Word {
+	out := make([]Word, limbCount(cap))
+	copy(out, x.limbs)
+	maskEnd(out, cap)
+	return out
 }
 
-// You might have the urge to replace this with []Word, and use the routines
-// that already exist for doing operations. This would be a mistake.
-// Go doesn't seem to be able to optimize and inline slice operations nearly as
-// well as it can for this little type. Attempts to replace this struct with a
-// slice were an order of magnitude slower (as per the exponentiation operation)
-type triple struct {
-	w0 Word
-	w1 Word
-	w2 Word
+// AddCarry calculates z <- x + y, modulo 2^cap, returning the carry out of the top bit
+//
+// This is like Add, except that the carry, i.e. whether or not x + y >= 2^cap,
+// is returned, instead of being discarded. Both x and y are first truncated to
+// cap bits. Unlike Add, the capacity must be given explicitly.
+//
+// This doesn't leak the value of the carry.
+func (z *Nat) AddCarry(x *Nat, y *Nat, cap int) Choice {
+	xLimbs := maskedLimbs(x, cap)
+	yLimbs := maskedLimbs(y, cap)
+	z.limbs = z.resizedLimbs(cap)
+	carry := Choice(addVV(z.limbs, xLimbs, yLimbs))
+	// LEAK: the value of cap
+	// OK: this is public
+	if shift := uint(cap) % _W; shift != 0 {
+		// Since both inputs are below 2^cap, the carry fits in the last limb
+		carry = Choice((z.limbs[len(z.limbs)-1] >> shift) & 1)
+	}
+	// Mask off the final bits
+	z.limbs = z.resizedLimbs(cap)
+	z.announced = cap
+	z.reduced = nil
+	return carry
 }
 
-func (a *triple) add(b triple) {
-	w0, c0 := bits.Add(uint(a.w0), uint(b.w0), 0)
+// SubBorrow calculates z <- x - y, modulo 2^cap, returning the borrow out of the top bit
+//
+// This is like Sub, except that the borrow, i.e. whether or not x < y, is returned,
+// instead of being discarded. Both x and y are first truncated to cap bits.
+// Unlike Sub, the capacity must be given explicitly.
+//
+// This doesn't leak the value of the borrow.
+func (z *Nat) SubBorrow(x *Nat, y *Nat, cap int) Choice {
+	xLimbs := maskedLimbs(x, cap)
+	yLimbs := maskedLimbs(y, cap)
+	z.limbs = z.resizedLimbs(cap)
+	// Since both inputs are below 2^cap, borrowing from the full limbs is the same
+	// as borrowing from the top bit
+	borrow := Choice(subVV(z.limbs, xLimbs, yLimbs))
+	// Mask off the final bits
+	z.limbs = z.resizedLimbs(cap)
+	z.announced = cap
+	z.reduced = nil
+	return borrow
+}
+
+// montgomeryRepresentation calculates zR mod m
+//
+// z must be reduced already, and m must be odd.
+func montgomeryRepresentation(z []Word, scratch []Word, m *Modulus) {
+	// Since montgomeryMul divides by R, multiplying by R^2 leaves us with zR
+	montgomeryMul(z, m.rSquared(), z, scratch, m)
+}
+
+// rSquared returns R^2 mod m, with R = 2^(_W * size), and size the number of limbs in m.
+//
+// This is calculated once, and then cached. The result has the same number of limbs as m.
+func (m *Modulus) rSquared() []Word {
+	if cached, ok := m.r2.Load().([]Word); ok {
+		return cached
+	}
+	_, r := PowerOfTwoDivMod(2*_W*len(m.nat.limbs), m)
+	m.r2.Store(r.limbs)
+	return r.limbs
+}
+
+// RSquared returns R^2 mod m, where R = 2^(_W * n), with n the number of limbs in m.
+//
+// This is the constant used to convert numbers into Montgomery representation,
+// with a single Montgomery multiplication, as in MontMulBatch. This value is
+// only calculated once, and then cached for subsequent calls.
+//
+// This will panic if m is even, since Montgomery multiplication requires an odd modulus.
+//
+// The capacity of the resulting number matches the capacity of the modulus.
+func (m *Modulus) RSquared() *Nat {
+	if m.even {
+		panic("RSquared: modulus is even")
+	}
+	out := new(Nat)
+	out.limbs = make([]Word, len(m.nat.limbs))
+	copy(out.limbs, m.rSquared())
+	out.announced = m.nat.announced
+	out.reduced = m
+	return out
+}
+
+// You might have the urge to replace this with []Word, and use the routines
+// that already exist for doing operations. This would be a mistake.
+// Go doesn't seem to be able to optimize and inline slice operations nearly as
+// well as it can for this little type. Attempts to replace this struct with a
+// slice were an order of magnitude slower (as per the exponentiation operation)
+type triple struct {
+	w0 Word
+	w1 Word
+	w2 Word
+}
+
+func (a *triple) add(b triple) {
+	w0, c0 := bits.Add(uint(a.w0), uint(b.w0), 0)
 	w1, c1 := bits.Add(uint(a.w1), uint(b.w1), c0)
 	w2, _ := bits.Add(uint(a.w2), uint(b.w2), c1)
 	a.w0 = Word(w0)
@@ -1170,19 +2440,274 @@ func montgomeryMul(x []Word, y []Word, out []Word, scratch []Word, m *Modulus) {
 		scratch[size-1] = z.w0
 		dh = z.w1
 	}
+	// At this point dh:scratch < 2m, so we need to subtract m at most once.
+	// If dh = 1, then the subtraction must borrow, and produce the right result.
+	// If dh = 0, then we keep the subtraction only if it didn't borrow.
+	// dh = 1 with no borrow is impossible, so keeping the subtraction exactly when
+	// dh == c covers every case.
 	c := subVV(out, scratch, m.nat.limbs)
 	ctCondCopy(1^ctEq(dh, c), out, scratch)
 }
 
+// squareLimbs calculates z <- x * x
+//
+// z must have twice the length of x, and not alias it.
+//
+// Each cross term x[i] * x[j] appears twice in the square, so we only compute
+// the products with i < j, double their sum, and then add in the diagonal terms x[i] * x[i].
+// This saves almost half of the multiplications needed by schoolbook multiplication.
+//
+// LEAK: the length of x
+func squareLimbs(z, x []Word) {
+	size := len(x)
+	for i := 0; i < len(z); i++ {
+		z[i] = 0
+	}
+	// First, the upper triangular products, x[i] * x[j] with i < j
+	for i := 0; i < size; i++ {
+		z[i+size] = addMulVVW(z[2*i+1:i+size], x[i+1:], x[i])
+	}
+	// The sum of the cross terms is < R^2 / 2, so this doubling can't overflow
+	shlVU(z, z, 1)
+	// Finally, add in the diagonal products
+	var c Word
+	for i := 0; i < size; i++ {
+		hi, lo := bits.Mul(uint(x[i]), uint(x[i]))
+		var c0, c1 uint
+		lo, c0 = bits.Add(uint(z[2*i]), lo, uint(c))
+		hi, c1 = bits.Add(uint(z[2*i+1]), hi, c0)
+		z[2*i] = Word(lo)
+		z[2*i+1] = Word(hi)
+		c = Word(c1)
+	}
+}
+
+// mulLimbs calculates z <- x * y
+//
+// x and y must have the same length, and z must have twice that length.
+// z must not alias x or y.
+func mulLimbs(z, x, y []Word) {
+	size := len(x)
+	for i := 0; i < len(z); i++ {
+		z[i] = 0
+	}
+	for i := 0; i < size; i++ {
+		z[i+size] = addMulVVW(z[i:i+size], x, y[i])
+	}
+}
+
+// montgomerySquare performs out <- x * x / R mod m
+//
+// This is like montgomeryMul(x, x, out, scratch, m), but faster, since the square
+// is calculated with squareLimbs, before being reduced separately.
+//
+// LEAK: the size of the modulus
+//
+// out and x must have the same length as the modulus, and x must be reduced already.
+// scratch must have twice that length.
+//
+// out can alias x, but not scratch
+func montgomerySquare(x []Word, out []Word, scratch []Word, m *Modulus) {
+	size := len(m.nat.limbs)
+
+	squareLimbs(scratch, x)
+	// Now we divide by R, by adding a multiple of m to clear each low limb in turn.
+	// The carry out of each step gets added into the next limb above the current window.
+	var dh Word
+	for i := 0; i < size; i++ {
+		f := scratch[i] * m.m0inv
+		c := addMulVVW(scratch[i:i+size], m.nat.limbs, f)
+		sum, c0 := bits.Add(uint(scratch[i+size]), uint(c), uint(dh))
+		scratch[i+size] = Word(sum)
+		dh = Word(c0)
+	}
+	// Like in montgomeryMul, dh:scratch[size:] < 2m, so we need to subtract m at most once.
+	c := subVV(out, scratch[size:], m.nat.limbs)
+	ctCondCopy(1^ctEq(dh, c), out, scratch[size:])
+}
+
+// MontMulBatch calculates out[i] <- a[i] * b[i] / R mod m, for each i.
+//
+// Here R = 2^(_W * n), where n is the number of limbs in m. This is the product
+// used for numbers in Montgomery representation, i.e. with x represented as xR mod m.
+// This is useful for code which keeps many values in that representation, and needs
+// to do many independent multiplications at once, e.g. in a number theoretic transform.
+//
+// A single buffer is used for the whole batch, instead of allocating for each
+// multiplication. All of the inputs are read before any output is written, so
+// elements of out may alias any elements of a or b, even at different indices.
+//
+// This will panic if m is even, or if the slices have different lengths.
+//
+// LEAK: the length of the slices
+//
+// The capacity of each output matches the capacity of the modulus.
+func (m *Modulus) MontMulBatch(out, a, b []*Nat) {
+	if m.even {
+		panic("MontMulBatch: modulus is even")
+	}
+	if len(out) != len(a) || len(out) != len(b) {
+		panic("MontMulBatch: mismatched arguments")
+	}
+	size := len(m.nat.limbs)
+	n := len(out)
+	// We hold the reduced inputs, along with the scratch space for multiplication
+	scratch := make([]Word, (2*n+1)*size)
+	inputs := scratch[:2*n*size]
+	mulScratch := scratch[2*n*size:]
+	reduce := func(x *Nat, xModM []Word) {
+		if x.reduced == m {
+			copy(xModM, x.limbs)
+		} else {
+			reduceLimbs(xModM, mulScratch, x.limbs, m)
+		}
+	}
+	// Since the outputs may alias inputs at other indices, we read all of the inputs first
+	for i := 0; i < n; i++ {
+		reduce(a[i], inputs[2*i*size:(2*i+1)*size])
+		reduce(b[i], inputs[(2*i+1)*size:(2*i+2)*size])
+	}
+	for i := 0; i < n; i++ {
+		aModM := inputs[2*i*size : (2*i+1)*size]
+		bModM := inputs[(2*i+1)*size : (2*i+2)*size]
+		montgomeryMul(aModM, bModM, aModM, mulScratch, m)
+		out[i].limbs = out[i].resizedLimbs(m.nat.announced)
+		copy(out[i].limbs, aModM)
+		out[i].announced = m.nat.announced
+		out[i].reduced = m
+	}
+}
+
+// The largest odd modulus, in bits, for which a single modular multiplication
+// uses Montgomery multiplication, rather than Barrett reduction.
+//
+// See the BenchmarkModMulBySize benchmarks for how this was chosen.
+const montgomeryModMulMaxBits = 256
+
+// modMulLimbs calculates out <- x * y mod m
+//
+// x and y must be reduced, and have as many limbs as m, as must out. If square
+// is set, x and y must be the same, and the product is calculated with squareLimbs.
+// out can alias x or y.
+//
+// LEAK: the size of m, whether or not it's even, square
+func modMulLimbs(out, x, y []Word, square bool, m *Modulus) {
+	size := len(m.nat.limbs)
+	// LEAK: the size of m, and whether or not it's even
+	// OK: this is public
+	if !m.even && _W*size <= montgomeryModMulMaxBits {
+		scratch := make([]Word, size)
+		// Since montgomeryMul divides by R, we multiply by R^2 to cancel out both divisions
+		montgomeryMul(x, y, out, scratch, m)
+		montgomeryMul(out, m.rSquared(), out, scratch, m)
+		return
+	}
+	mu := m.barrettMu()
+	scratch := make([]Word, 2*size+barrettScratchSize(size, mu))
+	product := scratch[:2*size]
+	if square {
+		squareLimbs(product, x)
+	} else {
+		mulLimbs(product, x, y)
+	}
+	barrettReduce(out, product, scratch[2*size:], mu, m)
+}
+
 // ModMul calculates z <- x * y mod m
 //
+// If x and y are the same Nat, this uses ModSquare instead, which is faster.
+//
 // The capacity of the resulting number matches the capacity of the modulus
 func (z *Nat) ModMul(x *Nat, y *Nat, m *Modulus) *Nat {
+	// LEAK: whether or not x and y are the same pointer
+	// OK: this doesn't depend on their values
+	if x == y && !m.powerOfTwo {
+		return z.ModSquare(x, m)
+	}
+	if m.powerOfTwo {
+		// Multiplying modulo 2^k only needs the lower k bits of the product
+		k := m.BitLen() - 1
+		z.Mul(truncated(x, k), truncated(y, k), k)
+		return z.modPowerOfTwo(z, m)
+	}
+	xLimbs := x.reducedLimbs(m)
+	yLimbs := y.reducedLimbs(m)
+	z.limbs = z.resizedLimbs(m.nat.announced)
+	modMulLimbs(z.limbs, xLimbs, yLimbs, false, m)
+	z.announced = m.nat.announced
+	z.reduced = m
+	return z
+}
+
+// ModSquare calculates z <- x * x mod m
+//
+// This is faster than ModMul(x, x, m), since the symmetry of the square
+// lets us skip almost half of the limb multiplications.
+//
+// The capacity of the resulting number matches the capacity of the modulus
+func (z *Nat) ModSquare(x *Nat, m *Modulus) *Nat {
+	if m.powerOfTwo {
+		return z.ModMul(x, x, m)
+	}
+	xLimbs := x.reducedLimbs(m)
+	z.limbs = z.resizedLimbs(m.nat.announced)
+	modMulLimbs(z.limbs, xLimbs, xLimbs, true, m)
+	z.announced = m.nat.announced
+	z.reduced = m
+	return z
+}
+
+// ModMulUint64 calculates z <- x * y mod m
+//
+// This is cheaper than calling ModMul with a Nat holding y, since only a single
+// limb multiplication and a short reduction are needed.
+//
+// The value of y is treated as public information.
+//
+// The capacity of the resulting number matches the capacity of the modulus
+func (z *Nat) ModMulUint64(x *Nat, y uint64, m *Modulus) *Nat {
+	size := len(m.nat.limbs)
+	// On 32 bit platforms, y will need two limbs
+	yLimbs := [2]Word{Word(y), Word(y >> 32 >> (_W - 32))}
+	yLen := limbCount(64)
+
+	z.Mod(x, m)
+	scratch := z.resizedLimbs(_W * (3*size + yLen))
+	xModM := scratch[:size]
+	product := scratch[size : 2*size+yLen]
+	for i := 0; i < len(product); i++ {
+		product[i] = 0
+	}
+	for i := 0; i < yLen; i++ {
+		product[size+i] = addMulVVW(product[i:size+i], xModM, yLimbs[i])
+	}
+	reduceLimbs(xModM, scratch[2*size+yLen:], product, m)
+
+	z.limbs = xModM
+	z.limbs = z.resizedLimbs(m.nat.announced)
+	z.announced = m.nat.announced
+	z.reduced = m
+	return z
+}
+
+// ModPolyEval calculates z <- sum(coeffs[i] * x^i) mod m
+//
+// This evaluates the polynomial with these coefficients at x, using Horner's rule,
+// with a single multiplication and addition per coefficient.
+//
+// The number of coefficients is leaked, but not their values, or the value of x.
+//
+// The capacity of the resulting number matches the capacity of the modulus
+func (z *Nat) ModPolyEval(coeffs []*Nat, x *Nat, m *Modulus) *Nat {
 	xModM := new(Nat).Mod(x, m)
-	yModM := new(Nat).Mod(y, m)
-	bitLen := m.BitLen()
-	z.Mul(xModM, yModM, 2*bitLen)
-	return z.Mod(z, m)
+	acc := m.NewZero()
+	// LEAK: the number of coefficients
+	// OK: this is public
+	for i := len(coeffs) - 1; i >= 0; i-- {
+		acc.ModMul(acc, xModM, m)
+		acc.ModAdd(acc, coeffs[i], m)
+	}
+	return z.SetNat(acc)
 }
 
 // Mul calculates z <- x * y, modulo 2^cap
@@ -1212,6 +2737,46 @@ func (z *Nat) Mul(x *Nat, y *Nat, cap int) *Nat {
 	return z
 }
 
+// MulAdd calculates z <- x * y + c, modulo 2^cap
+//
+// This is like calling Mul, and then Add, but the products are accumulated
+// directly on top of c, in a single pass, without an intermediate result.
+//
+// If cap < 0, the capacity will be max(x.AnnouncedLen() + y.AnnouncedLen(), c.AnnouncedLen()) + 1
+func (z *Nat) MulAdd(x *Nat, y *Nat, c *Nat, cap int) *Nat {
+	if cap < 0 {
+		cap = x.announced + y.announced
+		if c.announced > cap {
+			cap = c.announced
+		}
+		cap++
+	}
+	size := limbCount(cap)
+	// Starting from a copy of c, instead of zero, lets z alias any of the arguments
+	zLimbs := maskedLimbs(c, cap)
+	xLimbs := maskedLimbs(x, cap)
+	yLimbs := maskedLimbs(y, cap)
+	// LEAK: limbCount
+	// OK: the capacity is public, or should be
+	for i := 0; i < size; i++ {
+		addMulVVW(zLimbs[i:], xLimbs, yLimbs[i])
+	}
+	z.limbs = zLimbs
+	z.limbs = z.resizedLimbs(cap)
+	z.announced = cap
+	z.reduced = nil
+	return z
+}
+
+// MulWide calculates z <- x * y, without any truncation
+//
+// The announced length of the result is x.AnnouncedLen() + y.AnnouncedLen(),
+// which is always enough to hold the exact product. This is the same as
+// Mul with a negative capacity, but makes the lack of truncation explicit.
+func (z *Nat) MulWide(x *Nat, y *Nat) *Nat {
+	return z.Mul(x, y, x.announced+y.announced)
+}
+
 // Rsh calculates z <- x >> shift, producing a certain number of bits
 //
 // This method will leak the value of shift.
@@ -1248,6 +2813,60 @@ func (z *Nat) Rsh(x *Nat, shift uint, cap int) *Nat {
 	return z
 }
 
+// RshInPlace calculates z <- z >> shift, keeping the announced length of z
+//
+// Unlike Rsh, this never allocates, reusing the existing limbs of z instead.
+//
+// This method will leak the value of shift.
+func (z *Nat) RshInPlace(shift uint) *Nat {
+	limbShifts := int(shift / _W)
+	// LEAK: the value of shift, and the number of limbs
+	// OK: these are public
+	if limbShifts >= len(z.limbs) {
+		for i := 0; i < len(z.limbs); i++ {
+			z.limbs[i] = 0
+		}
+	} else {
+		shrVU(z.limbs, z.limbs, shift%_W)
+		if limbShifts > 0 {
+			copy(z.limbs, z.limbs[limbShifts:])
+			for i := len(z.limbs) - limbShifts; i < len(z.limbs); i++ {
+				z.limbs[i] = 0
+			}
+		}
+	}
+	z.reduced = nil
+	return z
+}
+
+// LshInPlace calculates z <- z << shift, modulo 2^z.AnnouncedLen()
+//
+// Unlike Lsh, this keeps the announced length of z, truncating any bits shifted
+// past it, and never allocates, reusing the existing limbs of z instead.
+//
+// This method will leak the value of shift.
+func (z *Nat) LshInPlace(shift uint) *Nat {
+	limbShifts := int(shift / _W)
+	// LEAK: the value of shift, and the number of limbs
+	// OK: these are public
+	if limbShifts >= len(z.limbs) {
+		for i := 0; i < len(z.limbs); i++ {
+			z.limbs[i] = 0
+		}
+	} else {
+		shlVU(z.limbs, z.limbs, shift%_W)
+		if limbShifts > 0 {
+			copy(z.limbs[limbShifts:], z.limbs)
+			for i := 0; i < limbShifts; i++ {
+				z.limbs[i] = 0
+			}
+		}
+		maskEnd(z.limbs, z.announced)
+	}
+	z.reduced = nil
+	return z
+}
+
 // Lsh calculates z <- x << shift, producing a certain number of bits
 //
 // This method will leak the value of shift.
@@ -1268,102 +2887,504 @@ func (z *Nat) Lsh(x *Nat, shift uint, cap int) *Nat {
 		for ; i-int(limbShifts) >= 0; i-- {
 			zLimbs[i] = zLimbs[i-int(limbShifts)]
 		}
-		for ; i >= 0; i-- {
-			zLimbs[i] = 0
+		for ; i >= 0; i-- {
+			zLimbs[i] = 0
+		}
+	}
+
+	z.limbs = zLimbs
+	z.announced = cap
+	z.reduced = nil
+	return z
+}
+
+func (z *Nat) expOdd(x *Nat, y *Nat, m *Modulus) *Nat {
+	size := len(m.nat.limbs)
+
+	xModM := new(Nat).Mod(x, m)
+	yLimbs := y.unaliasedLimbs(z)
+
+	scratch := z.resizedLimbs(_W * 18 * size)
+	scratch1 := scratch[16*size : 17*size]
+	scratch2 := scratch[17*size:]
+
+	z.limbs = scratch[:size]
+	for i := 0; i < size; i++ {
+		z.limbs[i] = 0
+	}
+	z.limbs[0] = 1
+	montgomeryRepresentation(z.limbs, scratch1, m)
+
+	x1 := scratch[size : 2*size]
+	copy(x1, xModM.limbs)
+	montgomeryRepresentation(scratch[size:2*size], scratch1, m)
+	for i := 2; i < 16; i++ {
+		ximinus1 := scratch[(i-1)*size : i*size]
+		xi := scratch[i*size : (i+1)*size]
+		montgomeryMul(ximinus1, x1, xi, scratch1, m)
+	}
+
+	// LEAK: y's length
+	// OK: this should be public
+	for i := len(yLimbs) - 1; i >= 0; i-- {
+		yi := yLimbs[i]
+		for j := _W - 4; j >= 0; j -= 4 {
+			// scratch1 and scratch2 are contiguous, giving the space needed for squaring
+			montgomerySquare(z.limbs, z.limbs, scratch[16*size:], m)
+			montgomerySquare(z.limbs, z.limbs, scratch[16*size:], m)
+			montgomerySquare(z.limbs, z.limbs, scratch[16*size:], m)
+			montgomerySquare(z.limbs, z.limbs, scratch[16*size:], m)
+
+			window := (yi >> j) & 0b1111
+			for i := 1; i < 16; i++ {
+				xToI := scratch[i*size : (i+1)*size]
+				ctCondCopy(ctEq(window, Word(i)), scratch1, xToI)
+			}
+			montgomeryMul(z.limbs, scratch1, scratch1, scratch2, m)
+			ctCondCopy(1^ctEq(window, 0), z.limbs, scratch1)
+		}
+	}
+	for i := 0; i < size; i++ {
+		scratch2[i] = 0
+	}
+	scratch2[0] = 1
+	montgomeryMul(z.limbs, scratch2, z.limbs, scratch1, m)
+	z.reduced = m
+	z.announced = m.nat.announced
+	return z
+}
+
+// expEven calculates z <- x^y mod m, for an even modulus.
+//
+// Montgomery multiplication needs an odd modulus, so this uses the same fixed
+// window as expOdd, but with Barrett reduction after each product instead.
+func (z *Nat) expEven(x *Nat, y *Nat, m *Modulus) *Nat {
+	size := len(m.nat.limbs)
+	mu := m.barrettMu()
+
+	xModM := new(Nat).Mod(x, m)
+	yLimbs := y.unaliasedLimbs(z)
+
+	// We need a table holding x^0, ..., x^15, along with space for a double
+	// width product, a selected table entry, and the scratch space for reduction.
+	scratch := z.resizedLimbs(_W * (19*size + barrettScratchSize(size, mu)))
+	table := scratch[:16*size]
+	product := scratch[16*size : 18*size]
+	selected := scratch[18*size : 19*size]
+	barrettScratch := scratch[19*size:]
+
+	for i := 0; i < size; i++ {
+		table[i] = 0
+	}
+	// m is even, and thus at least 2, so 1 is already reduced
+	table[0] = 1
+	copy(table[size:2*size], xModM.limbs)
+	for i := 2; i < 16; i++ {
+		mulLimbs(product, table[(i-1)*size:i*size], table[size:2*size])
+		barrettReduce(table[i*size:(i+1)*size], product, barrettScratch, mu, m)
+	}
+
+	// We use a separate buffer for the result, since the table will be overwritten
+	// when we adjust the size of z.
+	out := make([]Word, size)
+	copy(out, table[:size])
+
+	// LEAK: y's length
+	// OK: this should be public
+	for i := len(yLimbs) - 1; i >= 0; i-- {
+		yi := yLimbs[i]
+		for j := _W - 4; j >= 0; j -= 4 {
+			for k := 0; k < 4; k++ {
+				squareLimbs(product, out)
+				barrettReduce(out, product, barrettScratch, mu, m)
+			}
+
+			window := (yi >> j) & 0b1111
+			copy(selected, table[:size])
+			for k := 1; k < 16; k++ {
+				ctCondCopy(ctEq(window, Word(k)), selected, table[k*size:(k+1)*size])
+			}
+			mulLimbs(product, out, selected)
+			barrettReduce(out, product, barrettScratch, mu, m)
+		}
+	}
+	z.limbs = out
+	z.limbs = z.resizedLimbs(m.nat.announced)
+	z.announced = m.nat.announced
+	z.reduced = m
+	return z
+}
+
+// Exp calculates z <- x^y mod m
+//
+// The work done depends on the announced length of y, and not on its true length,
+// so y can be padded with Resize to hide the size of a secret exponent. This leaks
+// the announced length of y, but not the values of x or y.
+//
+// Both the base and the exponent are treated as secret. When only the exponent is
+// secret, e.g. with Diffie-Hellman, ExpSecretExponent can be used instead.
+//
+// The capacity of the resulting number matches the capacity of the modulus
+func (z *Nat) Exp(x *Nat, y *Nat, m *Modulus) *Nat {
+	if m.even {
+		return z.expEven(x, y, m)
+	} else {
+		return z.expOdd(x, y, m)
+	}
+}
+
+// ExpAndInverse calculates z <- x^y mod m, and inv <- x^-y mod m, returning both.
+//
+// The inverse is calculated with a single ModInverse of x^y, which is cheaper
+// than a second exponentiation. x needs to be invertible modulo m, otherwise the
+// value of inv is nonsense.
+//
+// inv can alias x or y, but not z. This leaks the same information as Exp,
+// and ModInverse.
+//
+// The capacity of both results matches the capacity of the modulus
+func (z *Nat) ExpAndInverse(inv *Nat, x *Nat, y *Nat, m *Modulus) (*Nat, *Nat) {
+	if inv == z {
+		panic("ExpAndInverse: z and inv must be different")
+	}
+	z.Exp(x, y, m)
+	inv.ModInverse(z, m)
+	return z, inv
+}
+
+// PowerTable holds the powers of a base, for repeated exponentiations with ExpWithTable.
+//
+// A table is created with Modulus.PrecomputePowers.
+type PowerTable struct {
+	// The modulus the powers have been reduced by
+	m *Modulus
+	// The number of bits of the exponent used at each step
+	windowBits int
+	// x^0, ..., x^(2^windowBits - 1), each of them taking up as many limbs as
+	// the modulus. For odd moduli, these are in Montgomery form.
+	powers []Word
+}
+
+// The largest window size supported by PrecomputePowers.
+const maxPowerTableWindow = 8
+
+// PrecomputePowers calculates a table of powers of x, for use with ExpWithTable.
+//
+// The table holds x^0, ..., x^(2^windowBits - 1), modulo m. Exp builds such a table
+// with a window of 4 bits every time it's called. Precomputing the table is useful
+// when the same base is raised to many different exponents. Larger windows make
+// exponentiation faster, at the cost of a larger table, and a slower precomputation.
+//
+// This leaks windowBits, but not the value of x.
+//
+// This panics if windowBits isn't between 1 and 8.
+func (m *Modulus) PrecomputePowers(x *Nat, windowBits int) *PowerTable {
+	if windowBits < 1 || windowBits > maxPowerTableWindow {
+		panic("PrecomputePowers: invalid window size")
+	}
+	size := len(m.nat.limbs)
+	count := 1 << windowBits
+	powers := make([]Word, count*size)
+	xModM := new(Nat).Mod(x, m)
+	// x^0 = 1, which gets converted to Montgomery form for odd moduli, like the other powers
+	powers[0] = 1
+	copy(powers[size:2*size], xModM.limbs)
+	if m.even {
+		mu := m.barrettMu()
+		scratch := make([]Word, 2*size+barrettScratchSize(size, mu))
+		product := scratch[:2*size]
+		barrettScratch := scratch[2*size:]
+		for i := 2; i < count; i++ {
+			mulLimbs(product, powers[(i-1)*size:i*size], powers[size:2*size])
+			barrettReduce(powers[i*size:(i+1)*size], product, barrettScratch, mu, m)
+		}
+	} else {
+		scratch := make([]Word, size)
+		montgomeryRepresentation(powers[:size], scratch, m)
+		montgomeryRepresentation(powers[size:2*size], scratch, m)
+		for i := 2; i < count; i++ {
+			montgomeryMul(powers[(i-1)*size:i*size], powers[size:2*size], powers[i*size:(i+1)*size], scratch, m)
 		}
 	}
+	return &PowerTable{m: m, windowBits: windowBits, powers: powers}
+}
 
-	z.limbs = zLimbs
-	z.announced = cap
-	z.reduced = nil
-	return z
+// windowAt returns the bits of limbs in [start, start + w)
+//
+// The bits past the end of limbs are treated as zero.
+func windowAt(limbs []Word, start, w int) Word {
+	i, j := start/_W, start%_W
+	v := limbs[i] >> j
+	if j+w > _W && i+1 < len(limbs) {
+		v |= limbs[i+1] << (_W - j)
+	}
+	return v & (Word(1)<<w - 1)
 }
 
-func (z *Nat) expOdd(x *Nat, y *Nat, m *Modulus) *Nat {
+// ExpWithTable calculates z <- x^y mod m, using a table of powers of x.
+//
+// The table is created with m.PrecomputePowers(x, windowBits). This produces the
+// same result as Exp(x, y, m), but the powers of x don't need to be recalculated
+// each time.
+//
+// Each window of y selects an entry in the table by scanning over the entire table,
+// so this doesn't leak the values of x or y, only the announced length of y, and
+// the size of the table.
+//
+// The capacity of the resulting number matches the capacity of the modulus
+func (z *Nat) ExpWithTable(t *PowerTable, y *Nat) *Nat {
+	m := t.m
 	size := len(m.nat.limbs)
-
-	xModM := new(Nat).Mod(x, m)
+	w := t.windowBits
+	count := 1 << w
 	yLimbs := y.unaliasedLimbs(z)
 
-	scratch := z.resizedLimbs(_W * 18 * size)
-	scratch1 := scratch[16*size : 17*size]
-	scratch2 := scratch[17*size:]
+	out := make([]Word, size)
+	selected := make([]Word, size)
+	copy(out, t.powers[:size])
 
-	z.limbs = scratch[:size]
-	for i := 0; i < size; i++ {
-		z.limbs[i] = 0
+	var mu, product, scratch []Word
+	if m.even {
+		mu = m.barrettMu()
+		scratch = make([]Word, 2*size+barrettScratchSize(size, mu))
+		product = scratch[:2*size]
+		scratch = scratch[2*size:]
+	} else {
+		scratch = make([]Word, 2*size)
 	}
-	z.limbs[0] = 1
-	montgomeryRepresentation(z.limbs, scratch1, m)
-
-	x1 := scratch[size : 2*size]
-	copy(x1, xModM.limbs)
-	montgomeryRepresentation(scratch[size:2*size], scratch1, m)
-	for i := 2; i < 16; i++ {
-		ximinus1 := scratch[(i-1)*size : i*size]
-		xi := scratch[i*size : (i+1)*size]
-		montgomeryMul(ximinus1, x1, xi, scratch1, m)
+	square := func() {
+		if m.even {
+			squareLimbs(product, out)
+			barrettReduce(out, product, scratch, mu, m)
+		} else {
+			montgomerySquare(out, out, scratch, m)
+		}
+	}
+	mul := func() {
+		if m.even {
+			mulLimbs(product, out, selected)
+			barrettReduce(out, product, scratch, mu, m)
+		} else {
+			montgomeryMul(out, selected, out, scratch[:size], m)
+		}
 	}
 
 	// LEAK: y's length
 	// OK: this should be public
-	for i := len(yLimbs) - 1; i >= 0; i-- {
-		yi := yLimbs[i]
-		for j := _W - 4; j >= 0; j -= 4 {
-			montgomeryMul(z.limbs, z.limbs, z.limbs, scratch1, m)
-			montgomeryMul(z.limbs, z.limbs, z.limbs, scratch1, m)
-			montgomeryMul(z.limbs, z.limbs, z.limbs, scratch1, m)
-			montgomeryMul(z.limbs, z.limbs, z.limbs, scratch1, m)
+	totalBits := len(yLimbs) * _W
+	for start := ((totalBits+w-1)/w - 1) * w; start >= 0; start -= w {
+		for i := 0; i < w; i++ {
+			square()
+		}
+		window := windowAt(yLimbs, start, w)
+		copy(selected, t.powers[:size])
+		for i := 1; i < count; i++ {
+			ctCondCopy(ctEq(window, Word(i)), selected, t.powers[i*size:(i+1)*size])
+		}
+		mul()
+	}
+	if !m.even {
+		// Multiplying by 1 takes us out of Montgomery form
+		for i := 0; i < size; i++ {
+			selected[i] = 0
+		}
+		selected[0] = 1
+		mul()
+	}
+	z.limbs = out
+	z.limbs = z.resizedLimbs(m.nat.announced)
+	z.announced = m.nat.announced
+	z.reduced = m
+	return z
+}
 
-			window := (yi >> j) & 0b1111
-			for i := 1; i < 16; i++ {
-				xToI := scratch[i*size : (i+1)*size]
-				ctCondCopy(ctEq(window, Word(i)), scratch1, xToI)
+// ExpLadder calculates z <- x^y mod m, using a Montgomery ladder.
+//
+// Unlike Exp, which uses a window of 4 bits, and a table of powers of x, the ladder
+// performs exactly one multiplication and one squaring for each bit of y, with
+// the same memory accesses regardless of its value. This is slower than Exp, but has
+// the simplest possible structure, for applications where that matters.
+//
+// This leaks the announced length of y, like Exp, but not the values of x or y.
+//
+// The capacity of the resulting number matches the capacity of the modulus
+func (z *Nat) ExpLadder(x *Nat, y *Nat, m *Modulus) *Nat {
+	// We maintain r1 = r0 * x, and then for each bit, starting from the top:
+	//   bit = 0 -> r1 <- r0 * r1, r0 <- r0^2
+	//   bit = 1 -> r0 <- r0 * r1, r1 <- r1^2
+	// To avoid branching on the bit, we swap r0 and r1 before and after the step.
+	if m.even {
+		r0 := new(Nat).Mod(new(Nat).SetUint64(1), m)
+		r1 := new(Nat).Mod(x, m)
+		// LEAK: y's length
+		// OK: this should be public
+		for i := len(y.limbs) - 1; i >= 0; i-- {
+			yi := y.limbs[i]
+			for j := _W - 1; j >= 0; j-- {
+				bit := Choice((yi >> j) & 1)
+				ctCondSwap(bit, r0.limbs, r1.limbs)
+				r1.ModMul(r0, r1, m)
+				r0.ModSquare(r0, m)
+				ctCondSwap(bit, r0.limbs, r1.limbs)
 			}
-			montgomeryMul(z.limbs, scratch1, scratch1, scratch2, m)
-			ctCondCopy(1^ctEq(window, 0), z.limbs, scratch1)
+		}
+		return z.SetNat(r0)
+	}
+
+	size := len(m.nat.limbs)
+	xModM := new(Nat).Mod(x, m)
+	scratch := make([]Word, 4*size)
+	r0 := scratch[:size]
+	r1 := scratch[size : 2*size]
+	mulScratch := scratch[2*size:]
+
+	r0[0] = 1
+	montgomeryRepresentation(r0, mulScratch[:size], m)
+	copy(r1, xModM.limbs)
+	montgomeryRepresentation(r1, mulScratch[:size], m)
+	// LEAK: y's length
+	// OK: this should be public
+	for i := len(y.limbs) - 1; i >= 0; i-- {
+		yi := y.limbs[i]
+		for j := _W - 1; j >= 0; j-- {
+			bit := Choice((yi >> j) & 1)
+			ctCondSwap(bit, r0, r1)
+			montgomeryMul(r0, r1, r1, mulScratch[:size], m)
+			montgomerySquare(r0, r0, mulScratch, m)
+			ctCondSwap(bit, r0, r1)
 		}
 	}
+	// Multiplying by 1 takes us out of Montgomery representation
 	for i := 0; i < size; i++ {
-		scratch2[i] = 0
+		r1[i] = 0
 	}
-	scratch2[0] = 1
-	montgomeryMul(z.limbs, scratch2, z.limbs, scratch1, m)
+	r1[0] = 1
+	montgomeryMul(r0, r1, r0, mulScratch[:size], m)
+
+	z.limbs = z.resizedLimbs(m.nat.announced)
+	copy(z.limbs, r0)
 	z.reduced = m
 	z.announced = m.nat.announced
 	return z
 }
 
-func (z *Nat) expEven(x *Nat, y *Nat, m *Modulus) *Nat {
-	xModM := new(Nat).Mod(x, m)
-	yLimbs := y.unaliasedLimbs(z)
+// ExpFixedWidth calculates z <- x^y mod m, treating y as having exactly exponentBits bits.
+//
+// The time taken by Exp depends on the announced length of y. This function instead
+// pads y to exponentBits bits, so that the time taken depends only on exponentBits,
+// and not on the size of the exponent. This is useful when the size of the exponent
+// itself should remain secret, e.g. with ephemeral Diffie-Hellman exponents.
+//
+// This panics if y doesn't fit in exponentBits bits, rather than silently truncating
+// the exponent. Checking this only leaks whether or not y fits, and nothing else about y.
+//
+// The capacity of the resulting number matches the capacity of the modulus
+func (z *Nat) ExpFixedWidth(x *Nat, y *Nat, m *Modulus, exponentBits int) *Nat {
+	// LEAK: whether or not y fits in exponentBits bits
+	// OK: an exponent that doesn't fit is a mistake by the caller
+	if bitsAbove(y.limbs, exponentBits) == 1 {
+		panic("ExpFixedWidth: exponent larger than exponentBits")
+	}
+	yPadded := new(Nat).SetNat(y).Resize(exponentBits)
+	return z.Exp(x, yPadded, m)
+}
 
-	scratch := new(Nat)
+// ExpReduced calculates z <- x^y mod m, by first reducing y modulo order
+//
+// This is correct whenever x^order = 1 mod m, i.e. when the multiplicative order
+// of x divides order. For example, for a prime m, and x not divisible by m, order
+// can be m - 1. When y is much larger than order, reducing it first makes the
+// exponentiation much faster.
+//
+// The order is public, but the value of y won't be leaked, since the reduced exponent
+// is used with Exp, which is constant-time. The time taken depends only on the
+// size of order, and not that of y, beyond the reduction.
+//
+// The capacity of the resulting number matches the capacity of the modulus
+func (z *Nat) ExpReduced(x *Nat, y *Nat, m *Modulus, order *Modulus) *Nat {
+	yModOrder := new(Nat).Mod(y, order)
+	return z.Exp(x, yModOrder, m)
+}
 
-	// LEAK: y's length
-	// OK: this should be public
-	for i := len(yLimbs) - 1; i >= 0; i-- {
-		yi := yLimbs[i]
-		for j := _W; j >= 0; j-- {
-			z.ModMul(z, z, m)
+// ExpSecretExponent calculates z <- x^y mod m, for a public base x, and a secret exponent y
+//
+// Exp protects both the base and the exponent. This function is for the common
+// case, e.g. Diffie-Hellman, where the base is public, and only the exponent is secret.
+// The value of the base, and of the modulus, may be leaked, but the value of the
+// exponent won't be, beyond its announced length.
+//
+// With a public base, the powers of x used by the exponentiation don't need to be
+// calculated in constant-time, but selecting one of these powers for each window
+// of y still does, since the windows are secret. For bases equal to 0 or 1 modulo m,
+// this allows skipping the exponentiation entirely. Otherwise, this does the same
+// work as Exp.
+//
+// The capacity of the resulting number matches the capacity of the modulus
+func (z *Nat) ExpSecretExponent(x *Nat, y *Nat, m *Modulus) *Nat {
+	xModM := new(Nat).Mod(x, m)
+	one := new(Nat).SetUint64(1)
+	one.Mod(one, m)
+	// LEAK: whether or not x is 0 or 1 modulo m
+	// OK: the base is public
+	if xModM.Eq(one) == 1 {
+		return z.SetNat(one)
+	}
+	if xModM.EqZero() == 1 {
+		// 0^y is 0, except when y = 0, which we don't want to leak
+		isZero := y.EqZero()
+		z.SetNat(xModM)
+		ctCondCopy(isZero, z.limbs, one.limbs)
+		return z
+	}
+	return z.Exp(xModM, y, m)
+}
 
-			sel := Choice((yi >> j) & 1)
-			scratch.ModMul(z, xModM, m)
-			ctCondCopy(sel, z.limbs, scratch.limbs)
+// randomUnit samples a uniformly random unit modulo m, using rand as a source of randomness.
+//
+// This will leak the number of samples needed to find a unit, but this only depends
+// on the random values that were rejected, and not on the value returned.
+func randomUnit(rand io.Reader, m *Modulus) (*Nat, error) {
+	// The extra bytes make the bias from reducing modulo m negligible.
+	buf := make([]byte, (m.BitLen()+7)/8+16)
+	r := new(Nat)
+	for {
+		if _, err := io.ReadFull(rand, buf); err != nil {
+			return nil, err
+		}
+		r.SetBytes(buf)
+		r.Mod(r, m)
+		if r.IsUnit(m) == 1 {
+			return r, nil
 		}
 	}
-	return z
 }
 
-// Exp calculates z <- x^y mod m
+// ExpBlinded calculates z <- x^y mod m, blinding the base with a random value.
+//
+// This computes (x * r)^y * (r^y)^-1 mod m, for a random unit r sampled using rand.
+// This means that the exponentiation involving y never operates directly on x,
+// which provides an extra layer of protection against side-channels correlating
+// the base and the exponent. Naturally, this is about twice as slow as Exp.
+//
+// An error is returned if reading from rand fails.
 //
 // The capacity of the resulting number matches the capacity of the modulus
-func (z *Nat) Exp(x *Nat, y *Nat, m *Modulus) *Nat {
-	if m.even {
-		return z.expEven(x, y, m)
-	} else {
-		return z.expOdd(x, y, m)
+func (z *Nat) ExpBlinded(x *Nat, y *Nat, m *Modulus, rand io.Reader) (*Nat, error) {
+	// Modulo 1, every value is 0, and there are no units to sample.
+	if m.BitLen() <= 1 {
+		return z.Exp(x, y, m), nil
+	}
+	r, err := randomUnit(rand, m)
+	if err != nil {
+		return nil, err
 	}
+	// We need to make sure not to overwrite y, if z happens to alias it.
+	y = new(Nat).SetNat(y)
+	blinded := new(Nat).ModMul(x, r, m)
+	blinded.Exp(blinded, y, m)
+	r.Exp(r, y, m)
+	r.ModInverse(r, m)
+	return z.ModMul(blinded, r, m), nil
 }
 
 // cmpEq compares two limbs (same size) returning 1 if x >= y, and 0 otherwise
@@ -1406,9 +3427,14 @@ func (z *Nat) Cmp(x *Nat) (Choice, Choice, Choice) {
 	// Rough Idea: Resize both slices to the maximum length, then compare
 	// using that length
 
-	maxBits := z.maxAnnounced(x)
-	zLimbs := z.resizedLimbs(maxBits)
-	xLimbs := x.resizedLimbs(maxBits)
+	// LEAK: whether or not the announced lengths are equal
+	// OK: the announced lengths are public
+	zLimbs, xLimbs := z.limbs, x.limbs
+	if z.announced != x.announced {
+		maxBits := z.maxAnnounced(x)
+		zLimbs = z.resizedLimbs(maxBits)
+		xLimbs = x.resizedLimbs(maxBits)
+	}
 
 	eq := Choice(1)
 	geq := Choice(1)
@@ -1423,6 +3449,34 @@ func (z *Nat) Cmp(x *Nat) (Choice, Choice, Choice) {
 	return geq & (1 ^ eq), eq, 1 ^ geq
 }
 
+// CmpVartime compares two natural numbers, returning -1, 0, or +1, if z is <, =, or > x.
+//
+// LEAK: This function is *not* constant-time. It leaks the values of z and x,
+// since it stops at the most significant limb where they differ. This should only
+// be used with public values, e.g. for sorting, where this is much faster than Cmp.
+func (z *Nat) CmpVartime(x *Nat) int {
+	size := len(z.limbs)
+	if len(x.limbs) > size {
+		size = len(x.limbs)
+	}
+	for i := size - 1; i >= 0; i-- {
+		var zi, xi Word
+		if i < len(z.limbs) {
+			zi = z.limbs[i]
+		}
+		if i < len(x.limbs) {
+			xi = x.limbs[i]
+		}
+		if zi > xi {
+			return 1
+		}
+		if zi < xi {
+			return -1
+		}
+	}
+	return 0
+}
+
 // CmpMod compares this natural number with a modulus, returning results for (>, =, <)
 //
 // This doesn't leak anything about the values of the numbers, only their lengths.
@@ -1440,6 +3494,37 @@ func (z *Nat) Eq(y *Nat) Choice {
 	return eq
 }
 
+// EqualConstantTime checks if z = x, returning a bool.
+//
+// This is the equivalent of subtle.ConstantTimeCompare for Nats, and is useful
+// for checking authentication tags. Every limb is compared before the result is
+// converted to a bool, so only the final result can leak, and not where the
+// values differ.
+//
+// Unlike subtle.ConstantTimeCompare, values with different announced lengths
+// are compared by value, with the missing limbs treated as zero. This leaks
+// the announced lengths of z and x.
+func (z *Nat) EqualConstantTime(x *Nat) bool {
+	size := len(z.limbs)
+	if len(x.limbs) > size {
+		size = len(x.limbs)
+	}
+	var diff Word
+	// LEAK: the number of limbs of each number
+	// OK: the announced lengths are public
+	for i := 0; i < size; i++ {
+		var zi, xi Word
+		if i < len(z.limbs) {
+			zi = z.limbs[i]
+		}
+		if i < len(x.limbs) {
+			xi = x.limbs[i]
+		}
+		diff |= zi ^ xi
+	}
+	return ctEq(diff, 0) == 1
+}
+
 // EqZero compares z to 0.
 //
 // This is more efficient that calling Eq between this Nat and a zero Nat.
@@ -1447,6 +3532,28 @@ func (z *Nat) EqZero() Choice {
 	return cmpZero(z.limbs)
 }
 
+// EqOne compares z to 1.
+//
+// This is more efficient that calling Eq between this Nat and a Nat holding 1.
+func (z *Nat) EqOne() Choice {
+	// LEAK: the number of limbs
+	// OK: this is public
+	if len(z.limbs) == 0 {
+		return 0
+	}
+	return ctEq(z.limbs[0], 1) & cmpZero(z.limbs[1:])
+}
+
+// InRange checks if lo <= z <= hi, returning 1 if so, and 0 otherwise.
+//
+// This function doesn't leak any information about the values involved, only
+// their announced lengths.
+func (z *Nat) InRange(lo, hi *Nat) Choice {
+	_, _, belowLo := z.Cmp(lo)
+	aboveHi, _, _ := z.Cmp(hi)
+	return (1 ^ belowLo) & (1 ^ aboveHi)
+}
+
 // mixSigned calculates a <- alpha * a + beta * b, returning whether the result is negative.
 //
 // alpha and beta are signed integers, but whose absolute value is < 2^(_W / 2).
@@ -1722,6 +3829,93 @@ func (x *Nat) Coprime(y *Nat) Choice {
 	return (aOdd | bOdd) & invertible
 }
 
+// ctShiftLimbs calculates x <- x >> shift, or x <- x << shift, if left is set.
+//
+// The shift is secret, and can be as large as _W * len(x). scratch must have
+// the same length as x.
+//
+// LEAK: the length of x
+func ctShiftLimbs(x, scratch []Word, shift int, left bool) {
+	size := len(x)
+	// We shift by each power of two in turn, keeping the result only if that
+	// bit is set in the shift.
+	for s := 1; s <= _W*size; s <<= 1 {
+		limbShift := s / _W
+		bitShift := uint(s % _W)
+		for i := 0; i < size; i++ {
+			scratch[i] = 0
+		}
+		if left {
+			copy(scratch[limbShift:], x[:size-limbShift])
+			if bitShift > 0 {
+				shlVU(scratch, scratch, bitShift)
+			}
+		} else {
+			copy(scratch, x[limbShift:])
+			if bitShift > 0 {
+				shrVU(scratch, scratch, bitShift)
+			}
+		}
+		ctCondCopy(ctEq(Word(shift&s), Word(s)), x, scratch)
+	}
+}
+
+// GCDConstantTime calculates z <- gcd(x, y), returning z
+//
+// Unlike Coprime, this returns the actual gcd, and works for any pair of numbers,
+// including even ones. The gcd of 0 and 0 is 0.
+//
+// This runs the binary GCD algorithm for a fixed number of iterations, after
+// removing the common power of two from x and y, so only the announced lengths of
+// x and y are leaked, and not their values.
+//
+// The capacity of the result is the larger of the capacities of x and y.
+func (z *Nat) GCDConstantTime(x, y *Nat) *Nat {
+	maxBits := x.maxAnnounced(y)
+	size := limbCount(maxBits)
+	a := make([]Word, size)
+	copy(a, x.limbs)
+	b := make([]Word, size)
+	copy(b, y.limbs)
+	scratch := make([]Word, size)
+
+	// First, count the common trailing zeros of a and b, which is the power of two
+	// in their gcd. If both are 0, this is maxBits.
+	shift := 0
+	seen := Word(0)
+	for i := 0; i < maxBits; i++ {
+		seen |= ((a[i/_W] | b[i/_W]) >> (i % _W)) & 1
+		shift += int(1 ^ seen)
+	}
+	ctShiftLimbs(a, scratch, shift, false)
+	ctShiftLimbs(b, scratch, shift, false)
+
+	// Now at least one of a and b is odd, unless both are 0, and we make sure
+	// that b is the odd one, which remains true across each iteration.
+	if size > 0 {
+		ctCondSwap(Choice(a[0]&1), a, b)
+	}
+	// Each iteration clears one bit of a, and both a and b start out with at most
+	// maxBits bits. After reducing a to 0, b holds the odd part of the gcd.
+	for i := 0; i < 2*maxBits; i++ {
+		// If a is odd: swap a and b if a < b, and then set a <- a - b
+		aOdd := Choice(a[0] & 1)
+		aSmaller := Choice(subVV(scratch, a, b))
+		ctCondSwap(aOdd&aSmaller, a, b)
+		subVV(scratch, a, b)
+		ctCondCopy(aOdd, a, scratch)
+		// a is now even, so we can remove a factor of two
+		shrVU(a, a, 1)
+	}
+	ctShiftLimbs(b, scratch, shift, true)
+
+	z.limbs = z.resizedLimbs(maxBits)
+	copy(z.limbs, b)
+	z.announced = maxBits
+	z.reduced = nil
+	return z
+}
+
 // IsUnit checks if x is a unit, i.e. invertible, mod m.
 //
 // This so happens to be when gcd(x, m) == 1.
@@ -1746,13 +3940,25 @@ func (z *Nat) modInverse(x *Nat, m *Nat, m0inv Word) *Nat {
 
 // ModInverse calculates z <- x^-1 mod m
 //
-// This will produce nonsense if the modulus is even.
+// This works for both odd and even moduli, but will produce nonsense if x isn't
+// invertible modulo m. ModInverseEven can be used to check this at the same time.
+//
+// If m is 1, every number is congruent to 0, and the result is 0.
 //
 // The capacity of the resulting number matches the capacity of the modulus
 func (z *Nat) ModInverse(x *Nat, m *Modulus) *Nat {
 	z.Mod(x, m)
+	// Every number is congruent to 0 modulo 1, which is thus its own inverse.
+	// The inversion routines don't handle this case, but z is already 0.
+	//
+	// LEAK: whether or not m is 1
+	// OK: the size of m is public
+	if m.nat.announced == 1 {
+		return z
+	}
 	if m.even {
-		z.modInverseEven(x, m)
+		// modInverseEven expects a reduced input, like modInverse
+		z.modInverseEven(z, m)
 	} else {
 		z.modInverse(z, &m.nat, m.m0inv)
 	}
@@ -1760,6 +3966,96 @@ func (z *Nat) ModInverse(x *Nat, m *Modulus) *Nat {
 	return z
 }
 
+// ModInversePrime calculates z <- x^-1 mod p, with p a prime number.
+//
+// This uses Fermat's little theorem, calculating x^(p - 2) mod p, which makes
+// this routine a simple exponentiation, and naturally constant-time. Note that
+// ModInverse is still faster: about twice as fast for a 256 bit prime, with the
+// gap only growing for larger primes.
+//
+// The caller is responsible for ensuring that p is prime: no check is done,
+// and the result will be nonsense otherwise. If x is 0 mod p, the result is 0.
+//
+// The capacity of the resulting number matches the capacity of the modulus
+func (z *Nat) ModInversePrime(x *Nat, p *Modulus) *Nat {
+	pMinusTwo := new(Nat).Sub(&p.nat, new(Nat).SetUint64(2), p.nat.announced)
+	return z.Exp(x, pMinusTwo, p)
+}
+
+// ModInverseBatchPrime calculates out[i] <- in[i]^-1 mod p, for each i, with p a prime number.
+//
+// This uses Montgomery's trick: the product of all the inputs is inverted once,
+// with ModInversePrime, and then the individual inverses are recovered with 3(n - 1)
+// multiplications. This is much faster than inverting each element separately,
+// e.g. when normalizing many elliptic curve points at once.
+//
+// Like ModInversePrime, the caller is responsible for ensuring that p is prime.
+// Elements which are 0 mod p have no inverse, and produce 0, without affecting
+// the other elements. Elements of out may alias elements of in.
+//
+// This will panic if the slices have different lengths.
+//
+// LEAK: the length of the slices
+//
+// The capacity of each output matches the capacity of the modulus.
+func ModInverseBatchPrime(out, in []*Nat, p *Modulus) {
+	if len(out) != len(in) {
+		panic("ModInverseBatchPrime: mismatched arguments")
+	}
+	n := len(in)
+	if n == 0 {
+		return
+	}
+	one := new(Nat).Mod(new(Nat).SetUint64(1), p)
+	// Zero elements get replaced with 1, so that they don't zero out the whole product
+	xs := make([]*Nat, n)
+	zero := make([]Choice, n)
+	for i := 0; i < n; i++ {
+		xs[i] = new(Nat).Mod(in[i], p)
+		zero[i] = xs[i].EqZero()
+		xs[i].CondAssign(zero[i], one)
+	}
+	// prefix[i] holds xs[0] * ... * xs[i]
+	prefix := make([]*Nat, n)
+	prefix[0] = xs[0]
+	for i := 1; i < n; i++ {
+		prefix[i] = new(Nat).ModMul(prefix[i-1], xs[i], p)
+	}
+	// inv holds (xs[0] * ... * xs[i])^-1 at the start of each iteration
+	inv := new(Nat).ModInversePrime(prefix[n-1], p)
+	for i := n - 1; i > 0; i-- {
+		out[i].ModMul(inv, prefix[i-1], p)
+		inv.ModMul(inv, xs[i], p)
+	}
+	out[0].SetNat(inv)
+	for i := 0; i < n; i++ {
+		out[i].CondAssign(zero[i], p.NewZero())
+	}
+}
+
+// ModInverseScratch calculates z <- x^-1 mod m, like ModInverse, but uses scratch as a workspace.
+//
+// Reusing the same z and scratch across many calls with the same modulus avoids
+// allocating a fresh workspace each time. scratch must not alias z, x, or m, and its
+// value after this call is unspecified.
+//
+// For even moduli, this falls back to ModInverse, which will allocate.
+//
+// The capacity of the resulting number matches the capacity of the modulus
+func (z *Nat) ModInverseScratch(x *Nat, m *Modulus, scratch *Nat) *Nat {
+	if scratch == z || scratch == x || scratch == &m.nat {
+		panic("ModInverseScratch: scratch aliases an argument")
+	}
+	if m.even {
+		return z.ModInverse(x, m)
+	}
+	z.Mod(x, m)
+	scratch.reduced = nil
+	scratch.invert(m.nat.announced, z.limbs, m.nat.limbs, m.m0inv)
+	copy(z.limbs, scratch.limbs)
+	return z
+}
+
 // divDouble divides x by d, outputtting the quotient in out, and a remainder
 //
 // This routine assumes nothing about the padding of either of its inputs, and
@@ -1800,9 +4096,9 @@ func divDouble(x []Word, d []Word, out []Word) []Word {
 	return r
 }
 
-// ModInverseEven calculates the modular inverse of x, mod m
+// modInverseEven calculates the modular inverse of x, mod m
 //
-// This routine will work even if m is an even number, unlike ModInverse.
+// This routine will work even if m is an even number, unlike modInverse.
 // Furthermore, it doesn't require the modulus to be truncated to its true size, and
 // will only leak information about the public sizes of its inputs. It is slower
 // than the standard routine though.
@@ -1828,7 +4124,8 @@ func (z *Nat) modInverseEven(x *Nat, m *Modulus) *Nat {
 	newZ.limbs = divDouble(m.nat.limbs, x.limbs, nil)
 	newZ.modInverse(&newZ, x, -invertModW(x.limbs[0]))
 	inverseZero := cmpZero(newZ.limbs)
-	newZ.Mul(&newZ, &m.nat, 2*size*_W)
+	// Mul resizes its arguments in place, so we use a copy of m, which needs to stay untouched
+	newZ.Mul(&newZ, m.Nat(), 2*size*_W)
 	newZ.limbs = newZ.resizedLimbs(_W * 2 * size)
 	subVW(newZ.limbs, newZ.limbs, 1)
 	divDouble(newZ.limbs, x.limbs, newZ.limbs)
@@ -1849,6 +4146,43 @@ func (z *Nat) modInverseEven(x *Nat, m *Modulus) *Nat {
 	return z
 }
 
+// ModInverseEven calculates z <- x^-1 mod m, returning whether or not x was invertible
+//
+// This works for any modulus, and is meant in particular for even moduli, such as 2^k
+// times an odd number. For x to be invertible, it needs to be coprime to m, which also
+// means that x must be odd, when m is even. Unlike ModInverse, this checks that
+// this condition holds: if x isn't invertible, then z is set to 0, and 0 is returned.
+//
+// This doesn't leak whether or not x was invertible, apart from through the result.
+//
+// The capacity of the resulting number matches the capacity of the modulus
+func (z *Nat) ModInverseEven(x *Nat, m *Modulus) (*Nat, Choice) {
+	invertible := x.IsUnit(m)
+	// We replace x with 1 if it isn't invertible, to avoid running the inversion
+	// routines on inputs they don't support.
+	xSafe := new(Nat).Mod(x, m)
+	one := make([]Word, len(xSafe.limbs))
+	one[0] = 1
+	ctCondCopy(1^invertible, xSafe.limbs, one)
+	z.ModInverse(xSafe, m)
+	zero := make([]Word, len(z.limbs))
+	ctCondCopy(1^invertible, z.limbs, zero)
+	return z, invertible
+}
+
+// ModDiv calculates z <- a * b^-1 mod m, returning whether or not b was invertible
+//
+// If b isn't invertible modulo m, then z is set to 0, and 0 is returned.
+// This doesn't leak whether or not b was invertible, apart from through the result.
+//
+// The capacity of the resulting number matches the capacity of the modulus
+func (z *Nat) ModDiv(a *Nat, b *Nat, m *Modulus) (*Nat, Choice) {
+	// ModInverseEven handles every modulus, and checks invertibility for us
+	bInv, ok := new(Nat).ModInverseEven(b, m)
+	z.ModMul(a, bInv, m)
+	return z, ok
+}
+
 // modSqrt3Mod4 sets z <- sqrt(x) mod p, when p is a prime with p = 3 mod 4
 func (z *Nat) modSqrt3Mod4(x *Nat, p *Modulus) *Nat {
 	// In this case, we can do x^(p + 1) / 4
@@ -1859,6 +4193,26 @@ func (z *Nat) modSqrt3Mod4(x *Nat, p *Modulus) *Nat {
 	return z.Exp(x, e, p)
 }
 
+// modSqrt5Mod8 sets z <- sqrt(x) mod p, when p is a prime with p = 5 mod 8
+func (z *Nat) modSqrt5Mod8(x *Nat, p *Modulus) *Nat {
+	// This is Atkin's algorithm, c.f. Handbook of Applied Cryptography, Algorithm 3.37:
+	//
+	//   t = (2x)^((p - 5) / 8), i = 2x t^2, z = x t (i - 1)
+	//
+	// Since p = 5 mod 8, p - 5 is divisible by 8, and p >= 5, so this can't borrow.
+	e := new(Nat).SetNat(&p.nat)
+	subVW(e.limbs, e.limbs, 5)
+	shrVU(e.limbs, e.limbs, 3)
+
+	twoX := new(Nat).ModAdd(x, x, p)
+	t := new(Nat).Exp(twoX, e, p)
+	i := new(Nat).ModMul(t, t, p)
+	i.ModMul(i, twoX, p)
+	i.ModSub(i, new(Nat).SetUint64(1), p)
+	z.ModMul(x, t, p)
+	return z.ModMul(z, i, p)
+}
+
 // tonelliShanks sets z <- sqrt(x) mod p, for any prime modulus
 func (z *Nat) tonelliShanks(x *Nat, p *Modulus) *Nat {
 	// c.f. https://datatracker.ietf.org/doc/html/draft-irtf-cfrg-hash-to-curve-09#appendix-G.4
@@ -1877,9 +4231,9 @@ func (z *Nat) tonelliShanks(x *Nat, p *Modulus) *Nat {
 	}
 	shrVU(reducedPminusOne.limbs, reducedPminusOne.limbs, 1)
 
-	nonSquare := new(Nat).SetUint64(2)
-	for scratch.Exp(nonSquare, reducedPminusOne, p).Eq(one) == 1 {
-		nonSquare.Add(nonSquare, one, p.BitLen())
+	nonSquare := p.QuadraticNonResidue()
+	if nonSquare == nil {
+		panic("ModSqrt: modulus has no quadratic non-residue")
 	}
 
 	for reducedPminusOne.limbs[0]&1 == 0 {
@@ -1897,12 +4251,11 @@ func (z *Nat) tonelliShanks(x *Nat, p *Modulus) *Nat {
 	t.ModMul(t, x, p)
 	z.ModMul(z, x, p)
 	b := new(Nat).SetNat(t)
-	one.limbs = one.resizedLimbs(len(b.limbs))
 	for i := trailingZeros; i > 1; i-- {
 		for j := 1; j < i-1; j++ {
 			b.ModMul(b, b, p)
 		}
-		sel := 1 ^ cmpEq(b.limbs, one.limbs)
+		sel := 1 ^ b.EqOne()
 		scratch.ModMul(z, c, p)
 		ctCondCopy(sel, z.limbs, scratch.limbs)
 		c.ModMul(c, c, p)
@@ -1919,6 +4272,9 @@ func (z *Nat) tonelliShanks(x *Nat, p *Modulus) *Nat {
 // p must be an odd prime number, and x must actually have a square root
 // modulo p. The result is undefined if these conditions aren't satisfied
 //
+// This panics if p is 0, or even. This also panics if p = 1 mod 8, and
+// QuadraticNonResidue returns nil, which can happen when p isn't prime.
+//
 // This function will leak information about the value of p. This isn't intended
 // to be used in situations where the modulus isn't publicly known.
 func (z *Nat) ModSqrt(x *Nat, p *Modulus) *Nat {
@@ -1931,5 +4287,85 @@ func (z *Nat) ModSqrt(x *Nat, p *Modulus) *Nat {
 	if p.nat.limbs[0]&0b11 == 0b11 {
 		return z.modSqrt3Mod4(x, p)
 	}
+	if p.nat.limbs[0]&0b111 == 0b101 {
+		return z.modSqrt5Mod8(x, p)
+	}
 	return z.tonelliShanks(x, p)
 }
+
+// ModSqrtBoth calculates both square roots of x modulo p
+//
+// The first root is stored in z, and the second root is p minus the first,
+// modulo p. When x is 0 mod p, both roots are 0. The boolean indicates whether or
+// not x actually has a square root modulo p. If it doesn't, the values of the
+// roots are undefined.
+//
+// p must be an odd prime number, like with ModSqrt, and this panics in the same cases.
+//
+// This function will leak information about the value of p, and whether or not
+// x has a square root, but not the value of x, or of its roots.
+func (z *Nat) ModSqrtBoth(x *Nat, p *Modulus) (*Nat, *Nat, bool) {
+	xModP := new(Nat).Mod(x, p)
+	z.ModSqrt(xModP, p)
+	r2 := new(Nat).ModNeg(z, p)
+	squared := new(Nat).ModMul(z, z, p)
+	return z, r2, squared.Eq(xModP) == 1
+}
+
+// ModSqrtCRT calculates the square roots of x modulo the product of some primes.
+//
+// The factors must be distinct odd primes. The square roots modulo each prime are
+// calculated with ModSqrtBoth, which panics in the same cases as ModSqrt, and then
+// combined using the Chinese Remainder Theorem.
+// This returns 2^k roots, for k factors. The root at index i uses the negated root
+// modulo the factor j if bit j of i is set. In particular, the first root combines
+// the results of ModSqrt for each factor. Roots will be repeated if x is 0 modulo
+// some of the factors.
+//
+// The boolean indicates whether or not x has a square root modulo every factor.
+// If it doesn't, the values of the roots are undefined.
+//
+// The capacity of each root matches the bit length of the product of the factors.
+//
+// The square roots modulo each factor leak information about that factor, like
+// with ModSqrt, but the combination of these roots doesn't leak the factors,
+// or the roots, beyond their sizes. This also leaks whether or not x has a square
+// root, and the number of factors.
+//
+// This panics if no factors are given.
+func ModSqrtCRT(x *Nat, factors []*Modulus) ([]*Nat, bool) {
+	if len(factors) == 0 {
+		panic("ModSqrtCRT: no factors")
+	}
+	k := len(factors)
+	ok := true
+	roots := make([][2]*Nat, k)
+	for i, p := range factors {
+		r, rNeg, rOk := new(Nat).ModSqrtBoth(x, p)
+		roots[i] = [2]*Nat{r, rNeg}
+		ok = ok && rOk
+	}
+	// We use Garner's algorithm to combine the roots. We need the product of the
+	// factors before each one, along with the inverse of that product modulo that factor.
+	prefixes := make([]*Nat, k)
+	inverses := make([]*Nat, k)
+	prefixes[0] = new(Nat).SetUint64(1)
+	for i := 1; i < k; i++ {
+		prefixes[i] = new(Nat).Mul(prefixes[i-1], factors[i-1].Nat(), -1)
+		inverses[i] = new(Nat).ModInverse(prefixes[i], factors[i])
+	}
+	n := ModulusFromNat(new(Nat).Mul(prefixes[k-1], factors[k-1].Nat(), -1))
+	out := make([]*Nat, 1<<k)
+	t := new(Nat)
+	for i := range out {
+		acc := new(Nat).SetNat(roots[0][i&1])
+		for j := 1; j < k; j++ {
+			// acc <- acc + prefix * ((r - acc) / prefix mod p)
+			t.ModSub(roots[j][(i>>j)&1], acc, factors[j])
+			t.ModMul(t, inverses[j], factors[j])
+			acc.Add(acc, t.Mul(prefixes[j], t, -1), -1)
+		}
+		out[i] = acc.Mod(acc, n)
+	}
+	return out, ok
+}
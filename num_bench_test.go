@@ -1,12 +1,15 @@
 package saferith
 
 import (
+	"encoding/hex"
 	"math/big"
 	"testing"
 )
 
 var resultBig big.Int
 var resultNat Nat
+var resultChoice Choice
+var resultInt int
 
 const _SIZE = 256
 
@@ -52,11 +55,20 @@ func prime3Mod4() []byte {
 	return bytes
 }
 
-// A 256 bit prime that's 1 mod 4
+// A 256 bit prime that's 1 mod 4, namely the order of secp256k1
 func prime1Mod4() []byte {
+	bytes, _ := hex.DecodeString("FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFEBAAEDCE6AF48A03BBFD25E8CD0364141")
+	return bytes
+}
+
+// A 256 bit prime that's 5 mod 8, namely 2^255 - 19
+func prime5Mod8() []byte {
 	bytes := make([]byte, 32)
-	bytes[0] = 4
-	bytes[31] = 0x99
+	for i := 0; i < len(bytes); i++ {
+		bytes[i] = 0xFF
+	}
+	bytes[0] = 0x7F
+	bytes[31] = 0xED
 	return bytes
 }
 
@@ -311,6 +323,68 @@ func BenchmarkLargeModAddNat(b *testing.B) {
 	_benchmarkModAddNat(m, b)
 }
 
+func _benchmarkModSubNat(m *Modulus, b *testing.B) {
+	b.StopTimer()
+
+	x := new(Nat).SetBytes(ones())
+	x.Mod(x, m)
+	y := new(Nat).SetBytes(doubleOnes())
+	y.Mod(y, m)
+
+	b.StartTimer()
+	for n := 0; n < b.N; n++ {
+		var z Nat
+		z.ModSub(x, y, m)
+		resultNat = z
+	}
+}
+
+func BenchmarkModSubNat(b *testing.B) {
+	b.StopTimer()
+
+	m := ModulusFromUint64(13)
+	_benchmarkModSubNat(m, b)
+}
+
+func BenchmarkLargeModAddSumNat(b *testing.B) {
+	b.StopTimer()
+
+	m := ModulusFromBytes(modulus2048())
+	terms := polyCoeffs(m)
+
+	b.StartTimer()
+	for n := 0; n < b.N; n++ {
+		sum := m.NewZero()
+		for _, x := range terms {
+			sum.ModAdd(sum, x, m)
+		}
+		resultNat = *sum
+	}
+}
+
+func BenchmarkLargeLazyNatSumNat(b *testing.B) {
+	b.StopTimer()
+
+	m := ModulusFromBytes(modulus2048())
+	terms := polyCoeffs(m)
+
+	b.StartTimer()
+	for n := 0; n < b.N; n++ {
+		lazy := NewLazyNat(m, len(terms))
+		for _, x := range terms {
+			lazy.AddReduced(x)
+		}
+		resultNat = *lazy.Reduce()
+	}
+}
+
+func BenchmarkLargeModSubNat(b *testing.B) {
+	b.StopTimer()
+
+	m := ModulusFromBytes(modulus2048())
+	_benchmarkModSubNat(m, b)
+}
+
 func _benchmarkModNegNat(m *Modulus, b *testing.B) {
 	b.StopTimer()
 
@@ -388,6 +462,96 @@ func BenchmarkLargeModMulNatEven(b *testing.B) {
 	_benchmarkModMulNat(m, b)
 }
 
+// Single modular multiplications, with odd moduli of increasing size.
+//
+// These were used to choose montgomeryModMulMaxBits. Measured on amd64,
+// in ns/op, calculating x * y mod m with both reduction methods:
+//
+//	bits   Montgomery   Barrett
+//	  64       40           96
+//	 128       69          130
+//	 256      150          182
+//	 384      289          260
+//	 512      465          344
+//	1024     1674          828
+//	2048     6310         2442
+//
+// Montgomery multiplication needs to be done twice, to get out of Montgomery form,
+// which only pays off for small moduli.
+func _benchmarkModMulBySize(bits int, b *testing.B) {
+	b.StopTimer()
+
+	bytes := make([]byte, bits/8)
+	for i := 0; i < len(bytes); i++ {
+		bytes[i] = 0xFD
+	}
+	m := ModulusFromBytes(bytes)
+	x := new(Nat).ModNeg(new(Nat).SetUint64(3), m)
+	y := new(Nat).ModNeg(new(Nat).SetUint64(5), m)
+
+	b.StartTimer()
+	for n := 0; n < b.N; n++ {
+		var z Nat
+		z.ModMul(x, y, m)
+		resultNat = z
+	}
+}
+
+func BenchmarkModMulBySize128Nat(b *testing.B) {
+	_benchmarkModMulBySize(128, b)
+}
+
+func BenchmarkModMulBySize256Nat(b *testing.B) {
+	_benchmarkModMulBySize(256, b)
+}
+
+func BenchmarkModMulBySize384Nat(b *testing.B) {
+	_benchmarkModMulBySize(384, b)
+}
+
+func BenchmarkModMulBySize512Nat(b *testing.B) {
+	_benchmarkModMulBySize(512, b)
+}
+
+func BenchmarkModMulBySize1024Nat(b *testing.B) {
+	_benchmarkModMulBySize(1024, b)
+}
+
+func BenchmarkModMulNatPowerOfTwo(b *testing.B) {
+	b.StopTimer()
+
+	m := ModulusFromNat(new(Nat).Lsh(new(Nat).SetUint64(1), 256, -1))
+	_benchmarkModMulNat(m, b)
+}
+
+func _benchmarkModMulUint64Nat(m *Modulus, b *testing.B) {
+	b.StopTimer()
+
+	x := new(Nat).SetBytes(ones())
+	x.Mod(x, m)
+
+	b.StartTimer()
+	for n := 0; n < b.N; n++ {
+		var z Nat
+		z.ModMulUint64(x, 0xDEADBEEFCAFEBABE, m)
+		resultNat = z
+	}
+}
+
+func BenchmarkModMulUint64Nat(b *testing.B) {
+	b.StopTimer()
+
+	m := ModulusFromUint64(13)
+	_benchmarkModMulUint64Nat(m, b)
+}
+
+func BenchmarkLargeModMulUint64Nat(b *testing.B) {
+	b.StopTimer()
+
+	m := ModulusFromBytes(modulus2048())
+	_benchmarkModMulUint64Nat(m, b)
+}
+
 func _benchmarkModNat(m *Modulus, b *testing.B) {
 	b.StopTimer()
 
@@ -415,6 +579,28 @@ func BenchmarkLargeModNat(b *testing.B) {
 	_benchmarkModNat(m, b)
 }
 
+func _benchmarkModWideNat(m *Modulus, b *testing.B) {
+	b.StopTimer()
+
+	x := new(Nat).SetBytes(doubleOnes())
+	// Make sure the constant for Barrett reduction is already cached
+	new(Nat).ModWide(x, m)
+
+	b.StartTimer()
+	for n := 0; n < b.N; n++ {
+		var z Nat
+		z.ModWide(x, m)
+		resultNat = z
+	}
+}
+
+func BenchmarkLargeModWideNat(b *testing.B) {
+	b.StopTimer()
+
+	m := ModulusFromBytes(modulus2048())
+	_benchmarkModWideNat(m, b)
+}
+
 func _benchmarkModInverseNat(m *Modulus, b *testing.B) {
 	b.StopTimer()
 
@@ -443,6 +629,152 @@ func BenchmarkLargeModInverseNat(b *testing.B) {
 	_benchmarkModInverseNat(m, b)
 }
 
+func BenchmarkLargeGCDConstantTimeNat(b *testing.B) {
+	b.StopTimer()
+
+	x := new(Nat).SetBytes(ones())
+	y := new(Nat).SetBytes(modulus2048())
+
+	b.StartTimer()
+	for n := 0; n < b.N; n++ {
+		var z Nat
+		z.GCDConstantTime(x, y)
+		resultNat = z
+	}
+}
+
+// modulus256 returns the prime used by the P-256 curve
+func modulus256() *Modulus {
+	m, _ := ModulusFromHex("FFFFFFFF00000001000000000000000000000000FFFFFFFFFFFFFFFFFFFFFFFF")
+	return m
+}
+
+// Reducing the product of two 256 bit numbers, with SolinasModulus.Reduce, and
+// with Mod. Measured on amd64, in ns/op, reusing the output across iterations:
+//
+//	prime       Reduce    Mod
+//	secp256k1      218   1923
+//	P-256           94   1918
+func _benchmarkSolinasReduceNat(s *SolinasModulus, b *testing.B) {
+	b.StopTimer()
+
+	// The product of two reduced numbers
+	x := new(Nat).SetBytes(ones()[:32])
+	x.Mul(x, x, 512)
+
+	// Reusing z lets Reduce use its limbs as scratch space, without allocating
+	var z Nat
+	b.StartTimer()
+	for n := 0; n < b.N; n++ {
+		s.Reduce(&z, x)
+	}
+	resultNat = z
+}
+
+func _benchmarkSolinasModNat(s *SolinasModulus, b *testing.B) {
+	b.StopTimer()
+
+	x := new(Nat).SetBytes(ones()[:32])
+	x.Mul(x, x, 512)
+	m := s.Modulus()
+
+	var z Nat
+	b.StartTimer()
+	for n := 0; n < b.N; n++ {
+		z.Mod(x, m)
+	}
+	resultNat = z
+}
+
+func BenchmarkSecp256k1SolinasReduceNat(b *testing.B) {
+	_benchmarkSolinasReduceNat(solinasSecp256k1(), b)
+}
+
+func BenchmarkSecp256k1ModNat(b *testing.B) {
+	_benchmarkSolinasModNat(solinasSecp256k1(), b)
+}
+
+func Benchmark256SolinasReduceNat(b *testing.B) {
+	_benchmarkSolinasReduceNat(solinasP256(), b)
+}
+
+func Benchmark256ModNat(b *testing.B) {
+	_benchmarkSolinasModNat(solinasP256(), b)
+}
+
+func Benchmark256ModInverseNat(b *testing.B) {
+	b.StopTimer()
+
+	_benchmarkModInverseNat(modulus256(), b)
+}
+
+func _benchmarkModInversePrimeNat(m *Modulus, b *testing.B) {
+	b.StopTimer()
+
+	x := new(Nat).SetBytes(ones())
+	x.Mod(x, m)
+
+	b.StartTimer()
+	for n := 0; n < b.N; n++ {
+		var z Nat
+		z.ModInversePrime(x, m)
+		resultNat = z
+	}
+}
+
+func Benchmark256ModInversePrimeNat(b *testing.B) {
+	b.StopTimer()
+
+	_benchmarkModInversePrimeNat(modulus256(), b)
+}
+
+func Benchmark256ModInverseBatchPrimeNat(b *testing.B) {
+	b.StopTimer()
+
+	m := modulus256()
+	in := make([]*Nat, 64)
+	out := make([]*Nat, 64)
+	for i := range in {
+		in[i] = new(Nat).SetUint64(uint64(i + 1))
+		out[i] = new(Nat)
+	}
+
+	b.StartTimer()
+	for n := 0; n < b.N; n++ {
+		ModInverseBatchPrime(out, in, m)
+	}
+}
+
+func _benchmarkModInverseScratchNat(m *Modulus, b *testing.B) {
+	b.StopTimer()
+
+	x := new(Nat).SetBytes(ones())
+	x.Mod(x, m)
+	var z, scratch Nat
+	z.ModInverseScratch(x, m, &scratch)
+
+	b.ReportAllocs()
+	b.StartTimer()
+	for n := 0; n < b.N; n++ {
+		z.ModInverseScratch(x, m, &scratch)
+	}
+	resultNat = z
+}
+
+func BenchmarkModInverseScratchNat(b *testing.B) {
+	b.StopTimer()
+
+	m := ModulusFromUint64(13)
+	_benchmarkModInverseScratchNat(m, b)
+}
+
+func BenchmarkLargeModInverseScratchNat(b *testing.B) {
+	b.StopTimer()
+
+	m := ModulusFromBytes(modulus2048())
+	_benchmarkModInverseScratchNat(m, b)
+}
+
 func _benchmarkModInverseEvenNat(m *Modulus, b *testing.B) {
 	b.StopTimer()
 
@@ -472,6 +804,34 @@ func BenchmarkLargeModInverseEvenNat(b *testing.B) {
 	_benchmarkModInverseEvenNat(ModulusFromNat(&m), b)
 }
 
+func _benchmarkModSquareNat(m *Modulus, b *testing.B) {
+	b.StopTimer()
+
+	x := new(Nat).SetBytes(ones())
+	x.Mod(x, m)
+
+	b.StartTimer()
+	for n := 0; n < b.N; n++ {
+		var z Nat
+		z.ModSquare(x, m)
+		resultNat = z
+	}
+}
+
+func BenchmarkModSquareNat(b *testing.B) {
+	b.StopTimer()
+
+	m := ModulusFromUint64(13)
+	_benchmarkModSquareNat(m, b)
+}
+
+func BenchmarkLargeModSquareNat(b *testing.B) {
+	b.StopTimer()
+
+	m := ModulusFromBytes(modulus2048())
+	_benchmarkModSquareNat(m, b)
+}
+
 func _benchmarkExpNat(m *Modulus, b *testing.B) {
 	b.StopTimer()
 
@@ -499,12 +859,153 @@ func BenchmarkLargeExpNat(b *testing.B) {
 	_benchmarkExpNat(m, b)
 }
 
+func _benchmarkExpLadderNat(m *Modulus, b *testing.B) {
+	b.StopTimer()
+
+	x := new(Nat).SetBytes(ones())
+	y := new(Nat).SetBytes(ones())
+	x.Mod(x, m)
+
+	b.StartTimer()
+	for n := 0; n < b.N; n++ {
+		var z Nat
+		z.ExpLadder(x, y, m)
+		resultNat = z
+	}
+}
+
+func BenchmarkLargeExpLadderNat(b *testing.B) {
+	b.StopTimer()
+
+	m := ModulusFromBytes(modulus2048())
+	_benchmarkExpLadderNat(m, b)
+}
+
+// The timing of this should match BenchmarkLargeExpNat, since only the announced
+// length of the exponent matters.
+func BenchmarkLargeExpPaddedExponentNat(b *testing.B) {
+	b.StopTimer()
+
+	m := ModulusFromBytes(modulus2048())
+	x := new(Nat).SetBytes(ones())
+	x.Mod(x, m)
+	y := new(Nat).SetUint64(65537).Resize(new(Nat).SetBytes(ones()).AnnouncedLen())
+
+	b.StartTimer()
+	for n := 0; n < b.N; n++ {
+		var z Nat
+		z.Exp(x, y, m)
+		resultNat = z
+	}
+}
+
+// exponents256 returns 100 different 256 bit exponents.
+func exponents256() []*Nat {
+	exponents := make([]*Nat, 100)
+	for i := 0; i < len(exponents); i++ {
+		exponents[i] = new(Nat).SetBytes(ones()[:32])
+		exponents[i].Add(exponents[i], new(Nat).SetUint64(uint64(i)), 256)
+	}
+	return exponents
+}
+
+func BenchmarkLargeExpRepeatedBaseNat(b *testing.B) {
+	b.StopTimer()
+
+	m := ModulusFromBytes(modulus2048())
+	x := new(Nat).SetBytes(ones())
+	x.Mod(x, m)
+	exponents := exponents256()
+
+	b.StartTimer()
+	for n := 0; n < b.N; n++ {
+		for _, y := range exponents {
+			var z Nat
+			z.Exp(x, y, m)
+			resultNat = z
+		}
+	}
+}
+
+func BenchmarkLargeExpWithTableNat(b *testing.B) {
+	b.StopTimer()
+
+	m := ModulusFromBytes(modulus2048())
+	x := new(Nat).SetBytes(ones())
+	x.Mod(x, m)
+	exponents := exponents256()
+
+	b.StartTimer()
+	for n := 0; n < b.N; n++ {
+		table := m.PrecomputePowers(x, 4)
+		for _, y := range exponents {
+			var z Nat
+			z.ExpWithTable(table, y)
+			resultNat = z
+		}
+	}
+}
+
 func BenchmarkLargeExpNatEven(b *testing.B) {
 	b.StopTimer()
 	m := ModulusFromBytes(modulus2048Even())
 	_benchmarkExpNat(m, b)
 }
 
+// Exponentiation with even moduli of increasing size, and an exponent as large
+// as the modulus.
+//
+// These were used to compare the fixed window with Barrett reduction, in expEven,
+// with the previous bit by bit method. Measured on amd64, in µs/op:
+//
+//	bits   bit by bit   fixed window
+//	 256        1343             50
+//	1024       17592            956
+//	2048       79673           5392
+func _benchmarkExpEvenBySize(bits int, b *testing.B) {
+	b.StopTimer()
+
+	bytes := make([]byte, bits/8)
+	for i := 0; i < len(bytes); i++ {
+		bytes[i] = 0xFE
+	}
+	m := ModulusFromBytes(bytes)
+	x := new(Nat).SetBytes(ones()[:bits/8])
+	y := new(Nat).SetBytes(ones()[:bits/8])
+	x.Mod(x, m)
+
+	b.StartTimer()
+	for n := 0; n < b.N; n++ {
+		var z Nat
+		z.Exp(x, y, m)
+		resultNat = z
+	}
+}
+
+func BenchmarkExpEvenBySize256Nat(b *testing.B) {
+	_benchmarkExpEvenBySize(256, b)
+}
+
+func BenchmarkExpEvenBySize1024Nat(b *testing.B) {
+	_benchmarkExpEvenBySize(1024, b)
+}
+
+func BenchmarkExpEvenBySize2048Nat(b *testing.B) {
+	_benchmarkExpEvenBySize(2048, b)
+}
+
+func BenchmarkFillBytesNat(b *testing.B) {
+	b.StopTimer()
+
+	x := new(Nat).SetBytes(ones())
+	buf := make([]byte, len(ones()))
+
+	b.StartTimer()
+	for n := 0; n < b.N; n++ {
+		x.FillBytes(buf)
+	}
+}
+
 func BenchmarkSetBytesNat(b *testing.B) {
 	b.StopTimer()
 
@@ -563,6 +1064,38 @@ func BenchmarkModSqrt1Mod4Nat(b *testing.B) {
 	}
 }
 
+func BenchmarkModSqrt5Mod8Nat(b *testing.B) {
+	b.StopTimer()
+
+	pMod := ModulusFromBytes(prime5Mod8())
+	// This is a large square modulo p
+	x := new(Nat).SetBytes(ones()[:32])
+	x.ModMul(x, x, pMod)
+
+	b.StartTimer()
+	for i := 0; i < b.N; i++ {
+		var z Nat
+		z.ModSqrt(x, pMod)
+		resultNat = z
+	}
+}
+
+func BenchmarkModSqrt5Mod8TonelliShanksNat(b *testing.B) {
+	b.StopTimer()
+
+	pMod := ModulusFromBytes(prime5Mod8())
+	// This is a large square modulo p
+	x := new(Nat).SetBytes(ones()[:32])
+	x.ModMul(x, x, pMod)
+
+	b.StartTimer()
+	for i := 0; i < b.N; i++ {
+		var z Nat
+		z.tonelliShanks(x, pMod)
+		resultNat = z
+	}
+}
+
 func _benchmarkDivNat(m *Modulus, b *testing.B) {
 	b.StopTimer()
 
@@ -589,3 +1122,198 @@ func BenchmarkLargeDivNat(b *testing.B) {
 	m := ModulusFromBytes(modulus2048())
 	_benchmarkDivNat(m, b)
 }
+
+// two distinct 4096 bit numbers, differing in their top byte
+func cmp4096() (*Nat, *Nat) {
+	bytes := make([]byte, 512)
+	for i := 0; i < len(bytes); i++ {
+		bytes[i] = 0xAB
+	}
+	x := new(Nat).SetBytes(bytes)
+	bytes[0] = 0xAC
+	y := new(Nat).SetBytes(bytes)
+	return x, y
+}
+
+func BenchmarkCmpNat(b *testing.B) {
+	b.StopTimer()
+
+	x, y := cmp4096()
+
+	b.StartTimer()
+	for n := 0; n < b.N; n++ {
+		_, eq, _ := x.Cmp(y)
+		resultChoice = eq
+	}
+}
+
+func BenchmarkEqNat(b *testing.B) {
+	b.StopTimer()
+
+	m := ModulusFromBytes(modulus2048())
+	x := new(Nat).Mod(new(Nat).SetBytes(ones()), m)
+	y := new(Nat).Mod(new(Nat).SetUint64(1), m)
+
+	b.StartTimer()
+	for n := 0; n < b.N; n++ {
+		resultChoice = x.Eq(y)
+	}
+}
+
+func BenchmarkCmpVartimeNat(b *testing.B) {
+	b.StopTimer()
+
+	x, y := cmp4096()
+
+	b.StartTimer()
+	for n := 0; n < b.N; n++ {
+		resultInt = x.CmpVartime(y)
+	}
+}
+
+func polyCoeffs(m *Modulus) []*Nat {
+	coeffs := make([]*Nat, 16)
+	for i := 0; i < len(coeffs); i++ {
+		coeffs[i] = new(Nat).SetBytes(ones())
+		coeffs[i].ModAdd(coeffs[i], new(Nat).SetUint64(uint64(i)), m)
+	}
+	return coeffs
+}
+
+func BenchmarkLargeModPolyEvalNat(b *testing.B) {
+	b.StopTimer()
+
+	m := ModulusFromBytes(modulus2048())
+	coeffs := polyCoeffs(m)
+	x := new(Nat).SetBytes(ones())
+	x.Mod(x, m)
+
+	b.StartTimer()
+	for n := 0; n < b.N; n++ {
+		var z Nat
+		z.ModPolyEval(coeffs, x, m)
+		resultNat = z
+	}
+}
+
+func BenchmarkLargeModPolyEvalNaiveNat(b *testing.B) {
+	b.StopTimer()
+
+	m := ModulusFromBytes(modulus2048())
+	coeffs := polyCoeffs(m)
+	x := new(Nat).SetBytes(ones())
+	x.Mod(x, m)
+
+	b.StartTimer()
+	for n := 0; n < b.N; n++ {
+		var z Nat
+		z.Mod(new(Nat), m)
+		xi := new(Nat).Mod(new(Nat).SetUint64(1), m)
+		for _, c := range coeffs {
+			z.ModAdd(&z, new(Nat).ModMul(c, xi, m), m)
+			xi.ModMul(xi, x, m)
+		}
+		resultNat = z
+	}
+}
+
+func batch2048(m *Modulus) []*Nat {
+	xs := make([]*Nat, 64)
+	for i := 0; i < len(xs); i++ {
+		xs[i] = new(Nat).SetBytes(ones())
+		xs[i].ModAdd(xs[i], new(Nat).SetUint64(uint64(i)), m)
+	}
+	return xs
+}
+
+func BenchmarkLargeMontMulBatchNat(b *testing.B) {
+	b.StopTimer()
+
+	m := ModulusFromBytes(modulus2048())
+	xs := batch2048(m)
+	out := make([]*Nat, len(xs))
+	for i := 0; i < len(out); i++ {
+		out[i] = new(Nat)
+	}
+
+	b.StartTimer()
+	for n := 0; n < b.N; n++ {
+		m.MontMulBatch(out, xs, xs)
+	}
+}
+
+func BenchmarkLargeModMulLoopNat(b *testing.B) {
+	b.StopTimer()
+
+	m := ModulusFromBytes(modulus2048())
+	xs := batch2048(m)
+	out := make([]*Nat, len(xs))
+	for i := 0; i < len(out); i++ {
+		out[i] = new(Nat)
+	}
+
+	b.StartTimer()
+	for n := 0; n < b.N; n++ {
+		for i := 0; i < len(xs); i++ {
+			out[i].ModMul(xs[i], xs[i], m)
+		}
+	}
+}
+
+func BenchmarkShift1000Nat(b *testing.B) {
+	b.StopTimer()
+
+	x := new(Nat).SetBytes(modulus2048())
+
+	b.StartTimer()
+	for n := 0; n < b.N; n++ {
+		z := new(Nat).SetNat(x)
+		for i := 0; i < 1000; i++ {
+			z.Lsh(z, 3, 2048)
+			z.Rsh(z, 3, 2048)
+		}
+		resultNat = *z
+	}
+}
+
+func BenchmarkShiftInPlace1000Nat(b *testing.B) {
+	b.StopTimer()
+
+	x := new(Nat).SetBytes(modulus2048())
+
+	b.StartTimer()
+	for n := 0; n < b.N; n++ {
+		z := new(Nat).SetNat(x)
+		for i := 0; i < 1000; i++ {
+			z.LshInPlace(3)
+			z.RshInPlace(3)
+		}
+		resultNat = *z
+	}
+}
+
+func BenchmarkAppendBytesNat(b *testing.B) {
+	b.StopTimer()
+
+	x := new(Nat).SetBytes(ones())
+	dst := make([]byte, 0, 2*_SIZE)
+
+	b.ReportAllocs()
+	b.StartTimer()
+	for n := 0; n < b.N; n++ {
+		dst = x.AppendBytes(dst[:0])
+	}
+}
+
+func BenchmarkAppendBytesViaBytesNat(b *testing.B) {
+	b.StopTimer()
+
+	x := new(Nat).SetBytes(ones())
+	dst := make([]byte, 0, 2*_SIZE)
+
+	b.ReportAllocs()
+	b.StartTimer()
+	for n := 0; n < b.N; n++ {
+		dst = append(dst[:0], x.Bytes()...)
+	}
+}
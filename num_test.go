@@ -2,9 +2,11 @@ package saferith
 
 import (
 	"bytes"
+	"io"
 	"math/big"
 	"math/rand"
 	"reflect"
+	"strings"
 	"testing"
 	"testing/quick"
 )
@@ -45,6 +47,51 @@ func TestBigConversion(t *testing.T) {
 	}
 }
 
+func TestSecureZeroExamples(t *testing.T) {
+	x := new(Nat).SetBytes(ones())
+	data := x.Bytes()
+	SecureZero(data)
+	if len(data) != (x.AnnouncedLen()+7)/8 {
+		t.Errorf("%+v != %+v", (x.AnnouncedLen()+7)/8, len(data))
+	}
+	for i, b := range data {
+		if b != 0 {
+			t.Errorf("byte %d wasn't cleared", i)
+		}
+	}
+	// The Nat itself should be left untouched
+	if !bytes.Equal(x.Bytes(), new(Nat).SetBytes(ones()).Bytes()) {
+		t.Errorf("expected SecureZero to only clear the slice")
+	}
+	SecureZero(nil)
+	SecureZero([]byte{})
+}
+
+func TestNatZeroExamples(t *testing.T) {
+	x := new(Nat).SetBytes(ones())
+	limbs := x.limbs
+	x.Zero()
+	if x.EqZero() != 1 || !x.checkInvariants() {
+		t.Errorf("expected %+v to be zero", x)
+	}
+	if x.AnnouncedLen() != 8*len(ones()) {
+		t.Errorf("%+v != %+v", 8*len(ones()), x.AnnouncedLen())
+	}
+	// The original limbs should have been scrubbed in place, including the spare capacity
+	for i, limb := range limbs[:cap(limbs)] {
+		if limb != 0 {
+			t.Errorf("limb %d wasn't cleared", i)
+		}
+	}
+	// A reduced value stays reduced
+	m := ModulusFromUint64(13)
+	y := new(Nat).ModAdd(new(Nat).SetUint64(5), new(Nat).SetUint64(6), m).Zero()
+	if y.EqZero() != 1 || !y.checkInvariants() {
+		t.Errorf("expected %+v to be zero", y)
+	}
+	new(Nat).Zero()
+}
+
 func testByteVsBytes(x Nat) bool {
 	if !x.checkInvariants() {
 		return false
@@ -78,6 +125,318 @@ func TestSetBytesRoundTrip(t *testing.T) {
 	}
 }
 
+// setBytesByteAtATime is the original implementation of SetBytes, reading a byte at a time
+func setBytesByteAtATime(buf []byte) *Nat {
+	z := new(Nat)
+	z.announced = 8 * len(buf)
+	z.limbs = make([]Word, limbCount(z.announced))
+	bufI := len(buf) - 1
+	for i := 0; i < len(z.limbs) && bufI >= 0; i++ {
+		for shift := 0; shift < _W && bufI >= 0; shift += 8 {
+			z.limbs[i] |= Word(buf[bufI]) << shift
+			bufI--
+		}
+	}
+	return z
+}
+
+// fillBytesByteAtATime is the original implementation of FillBytes, writing a byte at a time
+func fillBytesByteAtATime(z *Nat, buf []byte) []byte {
+	for i := 0; i < len(buf); i++ {
+		buf[i] = 0
+	}
+	i := len(buf)
+Outer:
+	for _, x := range z.limbs {
+		y := x
+		for j := 0; j < _S; j++ {
+			i--
+			if i < 0 {
+				break Outer
+			}
+			buf[i] = byte(y)
+			y >>= 8
+		}
+	}
+	return buf
+}
+
+func testSetBytesMatchesByteAtATime(data []byte, length uint8) bool {
+	// Check every length up to the full slice, to cover each partial limb
+	for cut := 0; cut <= len(data) && cut < 3*_S; cut++ {
+		input := data[len(data)-cut:]
+		expected := setBytesByteAtATime(input)
+		// Reuse a Nat with existing limbs, which need to be overwritten
+		actual := new(Nat).SetBytes(ones()).SetBytes(input)
+		if !actual.checkInvariants() || actual.AnnouncedLen() != expected.AnnouncedLen() {
+			return false
+		}
+		if !reflect.DeepEqual(expected.limbs, actual.limbs) {
+			return false
+		}
+	}
+	x := setBytesByteAtATime(data)
+	// Both shorter and longer buffers than the number
+	for _, size := range []int{int(length), len(data) + int(length)} {
+		expected := fillBytesByteAtATime(x, make([]byte, size))
+		actual := make([]byte, size)
+		for i := 0; i < len(actual); i++ {
+			actual[i] = 0xFF
+		}
+		x.FillBytes(actual)
+		if !bytes.Equal(expected, actual) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestSetBytesMatchesByteAtATime(t *testing.T) {
+	err := quick.Check(testSetBytesMatchesByteAtATime, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func reverseBytes(b []byte) []byte {
+	out := make([]byte, len(b))
+	for i := 0; i < len(b); i++ {
+		out[len(b)-1-i] = b[i]
+	}
+	return out
+}
+
+func TestIsAliasExamples(t *testing.T) {
+	x := new(Nat).SetUint64(1).Resize(256)
+	if !x.IsAlias(x) {
+		t.Errorf("expected x to alias itself")
+	}
+	y := x.Clone()
+	if x.IsAlias(y) || y.IsAlias(x) {
+		t.Errorf("expected a clone not to alias the original")
+	}
+	y.limbs = x.limbs[1:2]
+	if !x.IsAlias(y) || !y.IsAlias(x) {
+		t.Errorf("expected sub slices to alias each other")
+	}
+	if new(Nat).IsAlias(new(Nat)) {
+		t.Errorf("expected distinct empty values not to alias")
+	}
+}
+
+func testSetBytesLERoundTrip(expected []byte) bool {
+	x := new(Nat).SetBytesLE(expected)
+	if !x.checkInvariants() {
+		return false
+	}
+	if x.AnnouncedLen() != 8*len(expected) {
+		return false
+	}
+	if !bytes.Equal(expected, x.BytesLE()) {
+		return false
+	}
+	y := new(Nat).SetBytes(reverseBytes(expected))
+	return x.Eq(y) == 1 && bytes.Equal(reverseBytes(expected), x.Bytes())
+}
+
+func TestSetBytesLERoundTrip(t *testing.T) {
+	err := quick.Check(testSetBytesLERoundTrip, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func testAppendBytesMatchesBytes(x Nat, prefix []byte) bool {
+	expected := append(append([]byte{}, prefix...), x.Bytes()...)
+	actual := x.AppendBytes(append([]byte{}, prefix...))
+	return bytes.Equal(expected, actual)
+}
+
+func TestAppendBytesMatchesBytes(t *testing.T) {
+	err := quick.Check(testAppendBytesMatchesBytes, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func testAppendLengthPrefixedRoundtrip(x Nat, y Nat) bool {
+	data := x.AppendLengthPrefixed(nil)
+	data = y.AppendLengthPrefixed(data)
+	for _, expected := range []*Nat{&x, &y} {
+		if len(data) < 4 {
+			return false
+		}
+		length := int(data[0])<<24 | int(data[1])<<16 | int(data[2])<<8 | int(data[3])
+		data = data[4:]
+		if len(data) < length {
+			return false
+		}
+		actual := new(Nat)
+		if err := actual.UnmarshalBinary(data[:length]); err != nil {
+			return false
+		}
+		if actual.Eq(expected) != 1 {
+			return false
+		}
+		data = data[length:]
+	}
+	return len(data) == 0
+}
+
+func TestAppendLengthPrefixedRoundtrip(t *testing.T) {
+	err := quick.Check(testAppendLengthPrefixedRoundtrip, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestAppendBytesExamples(t *testing.T) {
+	x := new(Nat).SetUint64(0xAABB).Resize(24)
+	expected := []byte{0x01, 0x00, 0xAA, 0xBB}
+	actual := x.AppendBytes([]byte{0x01})
+	if !bytes.Equal(expected, actual) {
+		t.Errorf("%+v != %+v", expected, actual)
+	}
+	expected = []byte{0x00, 0x00, 0x00, 0x03, 0x00, 0xAA, 0xBB}
+	actual = x.AppendLengthPrefixed(nil)
+	if !bytes.Equal(expected, actual) {
+		t.Errorf("%+v != %+v", expected, actual)
+	}
+	// Enough capacity means no new allocation
+	buf := make([]byte, 0, 16)
+	actual = x.AppendBytes(buf)
+	if &actual[0] != &buf[:1][0] {
+		t.Errorf("expected AppendBytes to reuse the buffer")
+	}
+}
+
+func testSetBitMatchesBig(x Nat, i uint16, b bool) bool {
+	bit := int(i % 1024)
+	var choice Choice
+	var bigBit uint
+	if b {
+		choice, bigBit = 1, 1
+	}
+	expected := new(big.Int).SetBit(x.Big(), bit, bigBit)
+	expectedLen := x.AnnouncedLen()
+	if bit >= expectedLen {
+		expectedLen = bit + 1
+	}
+	x.SetBit(bit, choice)
+	if !x.checkInvariants() || x.AnnouncedLen() != expectedLen {
+		return false
+	}
+	return x.Big().Cmp(expected) == 0 && x.Bit(bit) == choice
+}
+
+func TestSetBitMatchesBig(t *testing.T) {
+	err := quick.Check(testSetBitMatchesBig, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSetBitExamples(t *testing.T) {
+	for _, i := range []int{0, 31, 32, 33, 63, 64, 65, 127, 128, 1000} {
+		x := new(Nat).SetUint64(1)
+		x.SetBit(i, 1)
+		expected := new(big.Int).SetBit(big.NewInt(1), i, 1)
+		if !x.checkInvariants() {
+			t.Errorf("invariants don't hold after setting bit %d", i)
+		}
+		if !bytes.Equal(x.Bytes(), expected.FillBytes(make([]byte, (x.AnnouncedLen()+7)/8))) {
+			t.Errorf("%+v != %+v", expected.Bytes(), x.Bytes())
+		}
+		if x.TrueLen() != expected.BitLen() {
+			t.Errorf("%+v != %+v", expected.BitLen(), x.TrueLen())
+		}
+		if x.Bit(i) != 1 || x.Bit(i+1) != 0 {
+			t.Errorf("unexpected bits around %d in %+v", i, x)
+		}
+		// Clearing the bit should only leave the original 1
+		x.SetBit(i, 0)
+		if i > 0 && x.TrueLen() != 1 {
+			t.Errorf("%+v != %+v", 1, x.TrueLen())
+		}
+	}
+	// Setting a bit inside the announced length shouldn't change it
+	x := new(Nat).SetUint64(0)
+	x.SetBit(10, 1)
+	if x.AnnouncedLen() != 64 {
+		t.Errorf("%+v != %+v", 64, x.AnnouncedLen())
+	}
+	// Reduced values forget their reduction
+	m := ModulusFromUint64(13)
+	x = new(Nat).Mod(new(Nat).SetUint64(1), m)
+	x.SetBit(3, 1)
+	if !x.checkInvariants() || x.Big().Cmp(big.NewInt(9)) != 0 {
+		t.Errorf("unexpected result %+v", x)
+	}
+	y := new(Nat).Mod(x, m)
+	if y.Big().Cmp(big.NewInt(9)) != 0 {
+		t.Errorf("%+v != %+v", 9, y)
+	}
+}
+
+func testBytesPaddedMatchesBig(x Nat, extra uint8) bool {
+	length := (x.TrueLen()+7)/8 + int(extra&7)
+	out, err := x.BytesPadded(length)
+	if err != nil || len(out) != length {
+		return false
+	}
+	expected := x.Big().FillBytes(make([]byte, length))
+	return bytes.Equal(expected, out)
+}
+
+func TestBytesPaddedMatchesBig(t *testing.T) {
+	err := quick.Check(testBytesPaddedMatchesBig, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestBytesPaddedExamples(t *testing.T) {
+	x := new(Nat).SetUint64(0x1234).Resize(256)
+	actual, err := x.BytesPadded(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []byte{0x12, 0x34}
+	if !bytes.Equal(expected, actual) {
+		t.Errorf("%+v != %+v", expected, actual)
+	}
+	actual, err = x.BytesPadded(4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = []byte{0, 0, 0x12, 0x34}
+	if !bytes.Equal(expected, actual) {
+		t.Errorf("%+v != %+v", expected, actual)
+	}
+	if _, err = x.BytesPadded(1); err == nil {
+		t.Errorf("expected error when value doesn't fit in 1 byte")
+	}
+	if _, err = new(Nat).SetUint64(1).Resize(8).BytesPadded(0); err == nil {
+		t.Errorf("expected error when value doesn't fit in 0 bytes")
+	}
+	if _, err = x.BytesPadded(-1); err == nil {
+		t.Errorf("expected error for negative length")
+	}
+}
+
+func testFillBytesLEMatchesFillBytes(x Nat, length uint8) bool {
+	expected := reverseBytes(x.FillBytes(make([]byte, length)))
+	actual := x.FillBytesLE(make([]byte, length))
+	return bytes.Equal(expected, actual)
+}
+
+func TestFillBytesLEMatchesFillBytes(t *testing.T) {
+	err := quick.Check(testFillBytesLEMatchesFillBytes, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
 func testNatMarshalBinaryRoundTrip(x Nat) bool {
 	out, err := x.MarshalBinary()
 	if err != nil {
@@ -119,6 +478,142 @@ func TestModulusMarshalBinaryRoundTrip(t *testing.T) {
 	}
 }
 
+func testModulusUnmarshalBinaryUsable(x Nat, y Nat, m Modulus) bool {
+	out, err := m.MarshalBinary()
+	if err != nil {
+		return false
+	}
+	// Reuse a modulus which has already been used, to check that nothing stale remains
+	restored := ModulusFromUint64(13)
+	new(Nat).ModWide(&x, restored)
+	err = restored.UnmarshalBinary(out)
+	if err != nil {
+		return false
+	}
+	if restored.m0inv != m.m0inv || restored.leading != m.leading || restored.even != m.even {
+		return false
+	}
+	if new(Nat).ModMul(&x, &y, restored).Eq(new(Nat).ModMul(&x, &y, &m)) != 1 {
+		return false
+	}
+	if new(Nat).ModWide(&x, restored).Eq(new(Nat).ModWide(&x, &m)) != 1 {
+		return false
+	}
+	return new(Nat).Exp(&x, &y, restored).Eq(new(Nat).Exp(&x, &y, &m)) == 1
+}
+
+func TestModulusUnmarshalBinaryUsable(t *testing.T) {
+	err := quick.Check(testModulusUnmarshalBinaryUsable, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestModulusUnmarshalBinaryUsableExamples(t *testing.T) {
+	x := new(Nat).SetBytes(ones())
+	y := new(Nat).SetUint64(0x10001)
+	moduli := []*Modulus{
+		ModulusFromUint64(20),
+		ModulusFromUint64(1 << 10),
+		ModulusFromBytes(modulus2048Even()),
+		ModulusFromBytes(modulus2048()),
+	}
+	for _, m := range moduli {
+		out, err := m.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := new(big.Int).Exp(x.Big(), y.Big(), m.Big())
+		// Restore into both an odd and an even modulus, which have already been used
+		for _, restored := range []*Modulus{ModulusFromUint64(13), ModulusFromUint64(14)} {
+			new(Nat).Exp(x, y, restored)
+			if err := restored.UnmarshalBinary(out); err != nil {
+				t.Fatal(err)
+			}
+			if restored.even != m.even {
+				t.Errorf("%v: expected even to be %v", m, m.even)
+			}
+			actual := new(Nat).Exp(x, y, restored)
+			if actual.Big().Cmp(expected) != 0 {
+				t.Errorf("%v: %+v != %+v", m, expected, actual)
+			}
+		}
+	}
+}
+
+func TestModulusCheckedExamples(t *testing.T) {
+	if _, err := ModulusFromBytesChecked([]byte{0, 0, 0}); err == nil {
+		t.Errorf("expected error for zero modulus")
+	}
+	if _, err := ModulusFromBytesChecked(nil); err == nil {
+		t.Errorf("expected error for empty modulus")
+	}
+	if _, err := ModulusFromUint64Checked(0); err == nil {
+		t.Errorf("expected error for zero modulus")
+	}
+	if _, err := ModulusFromNatChecked(new(Nat).SetUint64(0).Resize(256)); err == nil {
+		t.Errorf("expected error for zero modulus")
+	}
+	if _, err := ModulusFromHex("0000"); err == nil {
+		t.Errorf("expected error for zero modulus")
+	}
+	if err := new(Modulus).UnmarshalBinary([]byte{0}); err == nil {
+		t.Errorf("expected error for zero modulus")
+	}
+	m, err := ModulusFromBytesChecked([]byte{0, 13})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := ModulusFromUint64(13)
+	if _, eq, _ := m.Cmp(expected); eq != 1 {
+		t.Errorf("%+v != %+v", m, expected)
+	}
+	m, err = ModulusFromUint64Checked(13)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, eq, _ := m.Cmp(expected); eq != 1 {
+		t.Errorf("%+v != %+v", m, expected)
+	}
+	m, err = ModulusFromNatChecked(new(Nat).SetUint64(13))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, eq, _ := m.Cmp(expected); eq != 1 {
+		t.Errorf("%+v != %+v", m, expected)
+	}
+}
+
+func testModulusEqualMatchesCmp(m Modulus, n Modulus) bool {
+	_, eq, _ := m.Cmp(&n)
+	if m.Equal(&n) != eq || m.EqualVartime(&n) != (eq == 1) {
+		return false
+	}
+	// A modulus should always be equal to a copy of itself
+	mCopy := ModulusFromNat(m.Nat())
+	return m.Equal(mCopy) == 1 && m.EqualVartime(mCopy)
+}
+
+func TestModulusEqualMatchesCmp(t *testing.T) {
+	err := quick.Check(testModulusEqualMatchesCmp, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestModulusEqualExamples(t *testing.T) {
+	m := ModulusFromUint64(13)
+	// The same value, with more leading zero bytes
+	n := ModulusFromBytes([]byte{0, 0, 0, 13})
+	if m.Equal(n) != 1 || !m.EqualVartime(n) {
+		t.Errorf("expected %+v and %+v to be equal", m, n)
+	}
+	n = ModulusFromUint64(17)
+	if m.Equal(n) != 0 || m.EqualVartime(n) {
+		t.Errorf("expected %+v and %+v to be different", m, n)
+	}
+}
+
 func testAddZeroIdentity(n Nat) bool {
 	if !n.checkInvariants() {
 		return false
@@ -192,75 +687,336 @@ func TestCondAssign(t *testing.T) {
 	}
 }
 
-func testAddAssociative(a Nat, b Nat, c Nat) bool {
-	if !(a.checkInvariants() && b.checkInvariants() && c.checkInvariants()) {
+func testCondAssignUint64MatchesCondAssign(a Nat, x uint64, yes bool) bool {
+	var choice Choice
+	if yes {
+		choice = 1
+	}
+	expected := new(Nat).SetNat(&a).CondAssign(choice, new(Nat).SetUint64(x))
+	actual := new(Nat).SetNat(&a).CondAssignUint64(choice, x)
+	if !actual.checkInvariants() || actual.AnnouncedLen() != expected.AnnouncedLen() {
 		return false
 	}
-	var order1, order2 Nat
-	for _, x := range []int{256, 128, 64, 32, 8} {
-		order1 = *order1.Add(&a, &b, x)
-		order1.Add(&order1, &c, x)
-		order2 = *order2.Add(&b, &c, x)
-		order2.Add(&a, &order2, x)
-		if !(order1.checkInvariants() && order2.checkInvariants()) {
-			return false
-		}
-		if order1.Eq(&order2) != 1 {
-			return false
-		}
-	}
-	return true
+	return expected.Eq(actual) == 1
 }
 
-func TestAddAssociative(t *testing.T) {
-	err := quick.Check(testAddAssociative, &quick.Config{})
+func TestCondAssignUint64MatchesCondAssign(t *testing.T) {
+	err := quick.Check(testCondAssignUint64MatchesCondAssign, &quick.Config{})
 	if err != nil {
 		t.Error(err)
 	}
 }
 
-func testLshCompositionIsAdditionOfShifts(x Nat, s1 uint8, s2 uint8) bool {
-	way1 := new(Nat).Lsh(&x, uint(s1), -1)
-	way1.Lsh(way1, uint(s2), -1)
-	way2 := new(Nat).Lsh(&x, uint(s1)+uint(s2), -1)
-	return way1.Eq(way2) == 1
+func TestCondAssignUint64Examples(t *testing.T) {
+	m := ModulusFromUint64(13)
+	x := new(Nat).SetUint64(7)
+	x.Mod(x, m)
+	x.CondAssignUint64(1, 0xFFFF_FFFF_FFFF_FFFF)
+	expected := new(Nat).SetUint64(0xFFFF_FFFF_FFFF_FFFF)
+	if expected.Eq(x) != 1 || x.reduced != nil {
+		t.Errorf("%+v != %+v", expected, x)
+	}
+	x.CondAssignUint64(0, 1)
+	if expected.Eq(x) != 1 {
+		t.Errorf("%+v != %+v", expected, x)
+	}
 }
 
-func TestLshCompositionIsAdditionOfShifts(t *testing.T) {
-	err := quick.Check(testLshCompositionIsAdditionOfShifts, &quick.Config{})
-	if err != nil {
-		t.Error(err)
+func TestSelectExamples(t *testing.T) {
+	if actual := Select(1, 0xAA, 0xBB); actual != 0xAA {
+		t.Errorf("%+v != %+v", Word(0xAA), actual)
+	}
+	if actual := Select(0, 0xAA, 0xBB); actual != 0xBB {
+		t.Errorf("%+v != %+v", Word(0xBB), actual)
+	}
+	allOnes := ^Word(0)
+	if actual := Select(1, allOnes, 0); actual != allOnes {
+		t.Errorf("%+v != %+v", allOnes, actual)
+	}
+	if actual := Select(0, allOnes, 0); actual != 0 {
+		t.Errorf("%+v != %+v", Word(0), actual)
 	}
 }
 
-func testRshCompositionIsAdditionOfShifts(x Nat, s1 uint8, s2 uint8) bool {
-	way1 := new(Nat).Rsh(&x, uint(s1), -1)
-	way1.Rsh(way1, uint(s2), -1)
-	way2 := new(Nat).Rsh(&x, uint(s1)+uint(s2), -1)
-	return way1.Eq(way2) == 1
+func testEqOneMatchesEq(x Nat) bool {
+	one := new(Nat).SetUint64(1)
+	if x.EqOne() != x.Eq(one) {
+		return false
+	}
+	// Small values are rarely generated, so we also check a value of one with the same size
+	one.Resize(x.AnnouncedLen())
+	return one.EqOne() == one.Eq(new(Nat).SetUint64(1))
 }
 
-func TestRshCompositionIsAdditionOfShifts(t *testing.T) {
-	err := quick.Check(testRshCompositionIsAdditionOfShifts, &quick.Config{})
+func TestEqOneMatchesEq(t *testing.T) {
+	err := quick.Check(testEqOneMatchesEq, &quick.Config{})
 	if err != nil {
 		t.Error(err)
 	}
 }
 
-func testLshRshRoundTrip(x Nat, s uint8) bool {
-	z := new(Nat).Lsh(&x, uint(s), -1)
-	z.Rsh(z, uint(s), -1)
-	return x.Eq(z) == 1
-}
-
-func TestRshLshRoundTrip(t *testing.T) {
-	err := quick.Check(testRshLshRoundTrip, &quick.Config{})
-	if err != nil {
-		t.Error(err)
+func TestEqOneExamples(t *testing.T) {
+	if new(Nat).EqOne() != 0 {
+		t.Errorf("expected empty Nat to not be one")
+	}
+	if new(Nat).SetUint64(1).Resize(256).EqOne() != 1 {
+		t.Errorf("expected padded one to be one")
+	}
+	x := new(Nat).SetUint64(1).Resize(256)
+	x.limbs[len(x.limbs)-1] = 1
+	if x.EqOne() != 0 {
+		t.Errorf("expected 2^k + 1 to not be one")
 	}
 }
 
-func testRshLshRoundTrip(x Nat, s uint8) bool {
+func testInRange(lo Nat, z Nat, hi Nat) bool {
+	if !(lo.checkInvariants() && z.checkInvariants() && hi.checkInvariants()) {
+		return false
+	}
+	zBig := z.Big()
+	expected := zBig.Cmp(lo.Big()) >= 0 && zBig.Cmp(hi.Big()) <= 0
+	actual := z.InRange(&lo, &hi) == 1
+	if expected != actual {
+		return false
+	}
+	// The bounds themselves should always be included, whenever the range is non empty
+	if lo.Big().Cmp(hi.Big()) <= 0 {
+		return lo.InRange(&lo, &hi) == 1 && hi.InRange(&lo, &hi) == 1
+	}
+	return true
+}
+
+func TestInRange(t *testing.T) {
+	err := quick.Check(testInRange, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func testCmpSameLengthMatchesBig(a Nat, b Nat) bool {
+	// Give both values the same announced length, which takes a different path
+	bits := a.AnnouncedLen()
+	b.Resize(bits)
+	expected := a.Big().Cmp(b.Big())
+	gt, eq, lt := a.Cmp(&b)
+	switch expected {
+	case 1:
+		return gt == 1 && eq == 0 && lt == 0
+	case 0:
+		return gt == 0 && eq == 1 && lt == 0
+	default:
+		return gt == 0 && eq == 0 && lt == 1
+	}
+}
+
+func TestCmpSameLengthMatchesBig(t *testing.T) {
+	err := quick.Check(testCmpSameLengthMatchesBig, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func testCmpEmptyNat(a Nat) bool {
+	empty := new(Nat)
+	gt, eq, lt := a.Cmp(empty)
+	if lt != 0 || eq != a.EqZero() || gt != 1^a.EqZero() {
+		return false
+	}
+	gt, eq, lt = empty.Cmp(&a)
+	if gt != 0 || eq != a.EqZero() || lt != 1^a.EqZero() {
+		return false
+	}
+	// Comparing shouldn't break the invariants of either number
+	return a.checkInvariants() && empty.checkInvariants() && empty.AnnouncedLen() == 0
+}
+
+func TestCmpEmptyNat(t *testing.T) {
+	err := quick.Check(testCmpEmptyNat, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestZeroNatEqExamples(t *testing.T) {
+	zeros := []*Nat{
+		new(Nat),
+		new(Nat).SetUint64(0),
+		new(Nat).Resize(128),
+		new(Nat).SetBytes(nil),
+		new(Nat).SetUint64(0).Resize(0),
+	}
+	for i, x := range zeros {
+		for j, y := range zeros {
+			gt, eq, lt := x.Cmp(y)
+			if gt != 0 || eq != 1 || lt != 0 {
+				t.Errorf("zeros[%d].Cmp(zeros[%d]) = %v, %v, %v", i, j, gt, eq, lt)
+			}
+			if x.Eq(y) != 1 || !x.EqualConstantTime(y) || x.CmpVartime(y) != 0 {
+				t.Errorf("zeros[%d] != zeros[%d]", i, j)
+			}
+		}
+		if !x.checkInvariants() {
+			t.Errorf("zeros[%d] doesn't satisfy invariants", i)
+		}
+		if x.EqZero() != 1 {
+			t.Errorf("zeros[%d] isn't zero", i)
+		}
+	}
+}
+
+func testEqualConstantTimeMatchesEq(a Nat, b Nat) bool {
+	if a.EqualConstantTime(&b) != (a.Eq(&b) == 1) {
+		return false
+	}
+	// Padding a number shouldn't change the result
+	padded := a.Clone().Resize(a.AnnouncedLen() + 3*_W)
+	return a.EqualConstantTime(padded) && padded.EqualConstantTime(&a)
+}
+
+func TestEqualConstantTimeMatchesEq(t *testing.T) {
+	err := quick.Check(testEqualConstantTimeMatchesEq, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestEqualConstantTimeExamples(t *testing.T) {
+	x := new(Nat).SetBytes([]byte{0xAB, 0xCD})
+	y := new(Nat).SetBytes([]byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0xAB, 0xCD})
+	if !x.EqualConstantTime(y) {
+		t.Errorf("expected %+v and %+v to be equal", x, y)
+	}
+	y.SetBytes([]byte{1, 0, 0, 0, 0, 0, 0, 0, 0, 0xAB, 0xCD})
+	if x.EqualConstantTime(y) || y.EqualConstantTime(x) {
+		t.Errorf("expected %+v and %+v to be different", x, y)
+	}
+	if !new(Nat).EqualConstantTime(new(Nat).SetUint64(0)) {
+		t.Errorf("expected zeros to be equal")
+	}
+}
+
+func testCmpVartimeMatchesCmp(a Nat, b Nat) bool {
+	if !(a.checkInvariants() && b.checkInvariants()) {
+		return false
+	}
+	gt, eq, lt := a.Cmp(&b)
+	switch a.CmpVartime(&b) {
+	case 1:
+		return gt == 1 && eq == 0 && lt == 0
+	case 0:
+		return gt == 0 && eq == 1 && lt == 0 && a.CmpVartime(&a) == 0
+	case -1:
+		return gt == 0 && eq == 0 && lt == 1
+	}
+	return false
+}
+
+func TestCmpVartimeMatchesCmp(t *testing.T) {
+	err := quick.Check(testCmpVartimeMatchesCmp, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func testAddAssociative(a Nat, b Nat, c Nat) bool {
+	if !(a.checkInvariants() && b.checkInvariants() && c.checkInvariants()) {
+		return false
+	}
+	var order1, order2 Nat
+	for _, x := range []int{256, 128, 64, 32, 8} {
+		order1 = *order1.Add(&a, &b, x)
+		order1.Add(&order1, &c, x)
+		order2 = *order2.Add(&b, &c, x)
+		order2.Add(&a, &order2, x)
+		if !(order1.checkInvariants() && order2.checkInvariants()) {
+			return false
+		}
+		if order1.Eq(&order2) != 1 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestAddAssociative(t *testing.T) {
+	err := quick.Check(testAddAssociative, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func testLshCompositionIsAdditionOfShifts(x Nat, s1 uint8, s2 uint8) bool {
+	way1 := new(Nat).Lsh(&x, uint(s1), -1)
+	way1.Lsh(way1, uint(s2), -1)
+	way2 := new(Nat).Lsh(&x, uint(s1)+uint(s2), -1)
+	return way1.Eq(way2) == 1
+}
+
+func TestLshCompositionIsAdditionOfShifts(t *testing.T) {
+	err := quick.Check(testLshCompositionIsAdditionOfShifts, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func testRshCompositionIsAdditionOfShifts(x Nat, s1 uint8, s2 uint8) bool {
+	way1 := new(Nat).Rsh(&x, uint(s1), -1)
+	way1.Rsh(way1, uint(s2), -1)
+	way2 := new(Nat).Rsh(&x, uint(s1)+uint(s2), -1)
+	return way1.Eq(way2) == 1
+}
+
+func TestRshCompositionIsAdditionOfShifts(t *testing.T) {
+	err := quick.Check(testRshCompositionIsAdditionOfShifts, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func testShiftInPlaceMatchesBig(x Nat, s uint16) bool {
+	shift := uint(s & 1023)
+	mask := new(big.Int).Lsh(big.NewInt(1), uint(x.AnnouncedLen()))
+	mask.Sub(mask, big.NewInt(1))
+
+	lsh := x.Clone().LshInPlace(shift)
+	if !lsh.checkInvariants() || lsh.AnnouncedLen() != x.AnnouncedLen() {
+		return false
+	}
+	expected := new(big.Int).Lsh(x.Big(), shift)
+	expected.And(expected, mask)
+	if lsh.Big().Cmp(expected) != 0 {
+		return false
+	}
+
+	rsh := x.Clone().RshInPlace(shift)
+	if !rsh.checkInvariants() || rsh.AnnouncedLen() != x.AnnouncedLen() {
+		return false
+	}
+	expected.Rsh(x.Big(), shift)
+	return rsh.Big().Cmp(expected) == 0
+}
+
+func TestShiftInPlaceMatchesBig(t *testing.T) {
+	err := quick.Check(testShiftInPlaceMatchesBig, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func testLshRshRoundTrip(x Nat, s uint8) bool {
+	z := new(Nat).Lsh(&x, uint(s), -1)
+	z.Rsh(z, uint(s), -1)
+	return x.Eq(z) == 1
+}
+
+func TestRshLshRoundTrip(t *testing.T) {
+	err := quick.Check(testRshLshRoundTrip, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func testRshLshRoundTrip(x Nat, s uint8) bool {
 	if t := x.TrueLen(); int(s) > t {
 		s = uint8(t)
 	}
@@ -405,6 +1161,32 @@ func TestModIdempotent(t *testing.T) {
 	}
 }
 
+func testModPair(a Nat, p Modulus, q Modulus) bool {
+	if !a.checkInvariants() {
+		return false
+	}
+	expectedP := new(Nat).Mod(&a, &p)
+	expectedQ := new(Nat).Mod(&a, &q)
+	actualP, actualQ := new(Nat).ModPair(&a, &p, &q)
+	if !(actualP.checkInvariants() && actualQ.checkInvariants()) {
+		return false
+	}
+	if actualP.Eq(expectedP) != 1 || actualQ.Eq(expectedQ) != 1 {
+		return false
+	}
+	// Aliasing the input should produce the same result
+	aliased := new(Nat).SetNat(&a)
+	aliasedP, aliasedQ := aliased.ModPair(aliased, &p, &q)
+	return aliasedP.Eq(expectedP) == 1 && aliasedQ.Eq(expectedQ) == 1
+}
+
+func TestModPair(t *testing.T) {
+	err := quick.Check(testModPair, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
 func testModAddCommutative(a Nat, b Nat, m Modulus) bool {
 	if !(a.checkInvariants() && b.checkInvariants()) {
 		return false
@@ -469,6 +1251,100 @@ func TestModAddModSubInverse(t *testing.T) {
 	}
 }
 
+func testCanonicalizeMatchesMod(a Nat, m Modulus, addM bool) bool {
+	expected := new(Nat).Mod(&a, &m)
+	x := new(Nat).SetNat(expected)
+	if addM {
+		x.Add(x, &m.nat, m.BitLen()+1)
+	}
+	actual := x.Canonicalize(&m)
+	if !actual.checkInvariants() || actual.reduced != &m {
+		return false
+	}
+	return expected.Eq(actual) == 1
+}
+
+func TestCanonicalizeMatchesMod(t *testing.T) {
+	err := quick.Check(testCanonicalizeMatchesMod, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCanonicalizeExamples(t *testing.T) {
+	one := new(Nat).SetUint64(1)
+	for _, m := range []*Modulus{ModulusFromUint64(13), ModulusFromNat(new(Nat).Sub(new(Nat).Lsh(one, _W, -1), one, _W)), ModulusFromBytes(modulus2048())} {
+		mMinusOne := new(Nat).Sub(&m.nat, one, m.BitLen())
+		twoMMinusOne := new(Nat).Add(&m.nat, mMinusOne, m.BitLen()+1)
+		for _, x := range []*Nat{new(Nat), mMinusOne, &m.nat, twoMMinusOne} {
+			expected := new(Nat).Mod(x, m)
+			actual := new(Nat).SetNat(x).Canonicalize(m)
+			if expected.Eq(actual) != 1 {
+				t.Errorf("%+v != %+v", expected, actual)
+			}
+		}
+	}
+}
+
+func testModOutputsAreCanonical(a Nat, b Nat, m Modulus) bool {
+	outputs := []*Nat{
+		new(Nat).Mod(&a, &m),
+		new(Nat).ModAdd(&a, &b, &m),
+		new(Nat).ModSub(&a, &b, &m),
+		new(Nat).ModNeg(&a, &m),
+		new(Nat).ModMul(&a, &b, &m),
+		new(Nat).ModSquare(&a, &m),
+		new(Nat).Exp(&a, &b, &m),
+	}
+	if !m.even {
+		outputs = append(outputs, new(Nat).ModInverse(&a, &m))
+	}
+	for _, out := range outputs {
+		if !out.checkInvariants() {
+			return false
+		}
+		if _, _, lt := out.CmpMod(&m); lt != 1 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestModOutputsAreCanonical(t *testing.T) {
+	err := quick.Check(testModOutputsAreCanonical, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func testModAddModSubReducedMatchesUnreduced(a Nat, b Nat, m Modulus) bool {
+	aModM := new(Nat).Mod(&a, &m)
+	bModM := new(Nat).Mod(&b, &m)
+	expectedAdd := new(Nat).ModAdd(&a, &b, &m)
+	expectedSub := new(Nat).ModSub(&a, &b, &m)
+	actualAdd := new(Nat).ModAdd(aModM, bModM, &m)
+	actualSub := new(Nat).ModSub(aModM, bModM, &m)
+	if actualAdd.Eq(expectedAdd) != 1 || actualSub.Eq(expectedSub) != 1 {
+		return false
+	}
+	// Aliasing the output with an already reduced input should also work
+	aliasedAdd := new(Nat).SetNat(aModM)
+	aliasedAdd.ModAdd(aliasedAdd, bModM, &m)
+	aliasedSub := new(Nat).SetNat(bModM)
+	aliasedSub.ModSub(aModM, aliasedSub, &m)
+	if !(aliasedAdd.checkInvariants() && aliasedSub.checkInvariants()) {
+		return false
+	}
+	return aliasedAdd.Eq(expectedAdd) == 1 && aliasedSub.Eq(expectedSub) == 1 && aModM.Eq(new(Nat).Mod(&a, &m)) == 1
+}
+
+func TestModAddModSubReducedMatchesUnreduced(t *testing.T) {
+	err := quick.Check(testModAddModSubReducedMatchesUnreduced, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
 func testModMulCommutative(a Nat, b Nat, m Modulus) bool {
 	if !(a.checkInvariants() && b.checkInvariants()) {
 		return false
@@ -511,83 +1387,298 @@ func TestModMulAssociative(t *testing.T) {
 	}
 }
 
-func testModInverseMultiplication(a Nat) bool {
+func testModMulUint64(a Nat, y uint64, m Modulus) bool {
 	if !a.checkInvariants() {
 		return false
 	}
-	var scratch, one, zero Nat
-	zero.SetUint64(0)
-	one.SetUint64(1)
-	for _, x := range []uint64{3, 5, 7, 13, 19, 47, 97} {
-		m := ModulusFromUint64(x)
-		scratch.Mod(&a, m)
-		if scratch.Eq(&zero) == 1 {
-			continue
-		}
-		scratch.ModInverse(&a, m)
-		scratch.ModMul(&scratch, &a, m)
-		if !scratch.checkInvariants() {
-			return false
-		}
-		if scratch.Eq(&one) != 1 {
-			return false
-		}
+	expected := new(Nat).ModMul(&a, new(Nat).SetUint64(y), &m)
+	actual := new(Nat).ModMulUint64(&a, y, &m)
+	if !actual.checkInvariants() {
+		return false
 	}
-	return true
+	if actual.Eq(expected) != 1 {
+		return false
+	}
+	// Aliasing the input should produce the same result
+	actual.SetNat(&a)
+	actual.ModMulUint64(actual, y, &m)
+	return actual.Eq(expected) == 1
 }
 
-func TestModInverseMultiplication(t *testing.T) {
-	err := quick.Check(testModInverseMultiplication, &quick.Config{})
+func TestModMulUint64(t *testing.T) {
+	err := quick.Check(testModMulUint64, &quick.Config{})
 	if err != nil {
 		t.Error(err)
 	}
 }
 
-func testModInverseMinusOne(a Nat) bool {
-	if !a.checkInvariants() {
+func testModPolyEval(c0 Nat, c1 Nat, c2 Nat, x Nat, m Modulus) bool {
+	coeffs := []*Nat{&c0, &c1, &c2}
+	actual := new(Nat).ModPolyEval(coeffs, &x, &m)
+	if !actual.checkInvariants() {
 		return false
 	}
-	// Clear out the lowest bit
-	if len(a.limbs) > 0 {
-		a.limbs[0] &= ^Word(1)
-	}
-	if a.EqZero() == 1 {
-		return true
+	expected := new(big.Int)
+	xi := big.NewInt(1)
+	for _, c := range coeffs {
+		expected.Add(expected, new(big.Int).Mul(c.Big(), xi))
+		xi.Mul(xi, x.Big())
 	}
-	var one Nat
-	one.SetUint64(1)
-	z := new(Nat).Add(&a, &one, -1)
-	m := ModulusFromNat(z)
-	z.ModInverse(&a, m)
-	if !z.checkInvariants() {
+	expected.Mod(expected, m.Big())
+	return actual.Big().Cmp(expected) == 0
+}
+
+func testModulusNewZeroIsAccumulator(a Nat, b Nat, m Modulus) bool {
+	acc := m.NewZero()
+	if !acc.checkInvariants() || acc.EqZero() != 1 || acc.AnnouncedLen() != m.BitLen() {
 		return false
 	}
-	return z.Eq(&a) == 1
+	acc.ModAdd(acc, &a, &m)
+	acc.ModAdd(acc, &b, &m)
+	expected := new(Nat).ModAdd(&a, &b, &m)
+	return expected.Eq(acc) == 1
 }
 
-func TestModInverseMinusOne(t *testing.T) {
-	err := quick.Check(testModInverseMinusOne, &quick.Config{})
+func TestModulusNewZeroIsAccumulator(t *testing.T) {
+	err := quick.Check(testModulusNewZeroIsAccumulator, &quick.Config{})
 	if err != nil {
 		t.Error(err)
 	}
 }
 
-func testModInverseEvenMinusOne(a Nat) bool {
-	if !a.checkInvariants() {
-		return false
+func TestNewZeroExamples(t *testing.T) {
+	z := NewZero(100)
+	if z.AnnouncedLen() != 100 || len(z.limbs) != limbCount(100) || z.EqZero() != 1 {
+		t.Errorf("unexpected zero: %+v", z)
 	}
-	// Set the lowest bit
-	if len(a.limbs) != 0 {
-		a.limbs[0] |= 1
+	if z.reduced != nil {
+		t.Errorf("expected zero not to be reduced")
 	}
-	var zero Nat
-	zero.SetUint64(0)
-	if a.Eq(&zero) == 1 {
-		return true
+	m := ModulusFromUint64(13)
+	z = m.NewZero()
+	if z.reduced != m || z.AnnouncedLen() != m.BitLen() || z.EqZero() != 1 {
+		t.Errorf("unexpected zero: %+v", z)
 	}
-	var one Nat
-	one.SetUint64(1)
-	var z Nat
+}
+
+func TestModPolyEval(t *testing.T) {
+	err := quick.Check(testModPolyEval, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestModPolyEvalExamples(t *testing.T) {
+	m := ModulusFromUint64(13)
+	x := new(Nat).SetUint64(2)
+	// 1 + 2x + 3x^2 = 1 + 4 + 12 = 17 = 4 mod 13
+	coeffs := []*Nat{new(Nat).SetUint64(1), new(Nat).SetUint64(2), new(Nat).SetUint64(3)}
+	actual := new(Nat).ModPolyEval(coeffs, x, m)
+	expected := new(Nat).SetUint64(4)
+	if expected.Eq(actual) != 1 {
+		t.Errorf("%+v != %+v", expected, actual)
+	}
+	actual.ModPolyEval(nil, x, m)
+	if actual.EqZero() != 1 || actual.AnnouncedLen() != m.BitLen() {
+		t.Errorf("expected the empty polynomial to evaluate to 0")
+	}
+}
+
+func testMontMulBatch(a0 Nat, a1 Nat, b0 Nat, b1 Nat, m Modulus) bool {
+	if m.even {
+		return true
+	}
+	a := []*Nat{&a0, &a1}
+	b := []*Nat{&b0, &b1}
+	// R^-1 mod m
+	rInv := new(Nat).Lsh(new(Nat).SetUint64(1), uint(_W*len(m.nat.limbs)), -1)
+	rInv.ModInverse(rInv, &m)
+	expected := make([]*Nat, len(a))
+	for i := 0; i < len(a); i++ {
+		expected[i] = new(Nat).ModMul(a[i], b[i], &m)
+		expected[i].ModMul(expected[i], rInv, &m)
+	}
+	out := []*Nat{new(Nat), new(Nat)}
+	m.MontMulBatch(out, a, b)
+	for i := 0; i < len(out); i++ {
+		if !out[i].checkInvariants() {
+			return false
+		}
+		if out[i].Eq(expected[i]) != 1 {
+			return false
+		}
+	}
+	// The outputs should be allowed to alias the inputs, even at other indices
+	aCopy := []*Nat{new(Nat).SetNat(a[0]), new(Nat).SetNat(a[1])}
+	bCopy := []*Nat{new(Nat).SetNat(b[0]), new(Nat).SetNat(b[1])}
+	m.MontMulBatch([]*Nat{aCopy[1], bCopy[0]}, aCopy, bCopy)
+	if aCopy[1].Eq(expected[0]) != 1 || bCopy[0].Eq(expected[1]) != 1 {
+		return false
+	}
+	m.MontMulBatch(a, a, b)
+	for i := 0; i < len(a); i++ {
+		if a[i].Eq(expected[i]) != 1 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestMontMulBatch(t *testing.T) {
+	err := quick.Check(testMontMulBatch, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func testModInverseMultiplication(a Nat) bool {
+	if !a.checkInvariants() {
+		return false
+	}
+	var scratch, one, zero Nat
+	zero.SetUint64(0)
+	one.SetUint64(1)
+	for _, x := range []uint64{3, 5, 7, 13, 19, 47, 97} {
+		m := ModulusFromUint64(x)
+		scratch.Mod(&a, m)
+		if scratch.Eq(&zero) == 1 {
+			continue
+		}
+		scratch.ModInverse(&a, m)
+		scratch.ModMul(&scratch, &a, m)
+		if !scratch.checkInvariants() {
+			return false
+		}
+		if scratch.Eq(&one) != 1 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestModInverseMultiplication(t *testing.T) {
+	err := quick.Check(testModInverseMultiplication, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func testModInverseScratch(a Nat, m Modulus) bool {
+	if !a.checkInvariants() {
+		return false
+	}
+	if a.IsUnit(&m) != 1 {
+		return true
+	}
+	var scratch, z Nat
+	expected := new(Nat).ModInverse(&a, &m)
+	// Running twice makes sure that reusing the workspace doesn't corrupt results
+	for i := 0; i < 2; i++ {
+		z.ModInverseScratch(&a, &m, &scratch)
+		if !z.checkInvariants() {
+			return false
+		}
+		if z.Eq(expected) != 1 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestModInverseScratch(t *testing.T) {
+	err := quick.Check(testModInverseScratch, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func testModInverseModulusOne(a Nat) bool {
+	m := ModulusFromUint64(1)
+	// Start with a non-zero output, to make sure it gets cleared
+	for _, z := range []*Nat{new(Nat), new(Nat).SetUint64(1)} {
+		z.ModInverse(&a, m)
+		if !z.checkInvariants() || z.AnnouncedLen() != 1 || z.EqZero() != 1 {
+			return false
+		}
+	}
+	inverse, ok := new(Nat).ModInverseEven(&a, m)
+	return ok == 1 && inverse.EqZero() == 1
+}
+
+func TestModInverseModulusOne(t *testing.T) {
+	err := quick.Check(testModInverseModulusOne, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func testModInverseDoesNotMutateModulus(x Nat, m Modulus) bool {
+	expected := m.Nat()
+	limbs := m.nat.limbs
+	for i := 0; i < 10; i++ {
+		new(Nat).ModInverse(&x, &m)
+		new(Nat).ModInverseEven(&x, &m)
+		x.Add(&x, new(Nat).SetUint64(1), -1)
+	}
+	// The limbs shouldn't have been touched, or even reallocated
+	if len(m.nat.limbs) != len(limbs) || cap(m.nat.limbs) != cap(limbs) || &m.nat.limbs[0] != &limbs[0] {
+		return false
+	}
+	return m.nat.checkInvariants() && m.nat.Eq(expected) == 1
+}
+
+func TestModInverseDoesNotMutateModulus(t *testing.T) {
+	err := quick.Check(testModInverseDoesNotMutateModulus, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func testModInverseMinusOne(a Nat) bool {
+	if !a.checkInvariants() {
+		return false
+	}
+	// Clear out the lowest bit
+	if len(a.limbs) > 0 {
+		a.limbs[0] &= ^Word(1)
+	}
+	if a.EqZero() == 1 {
+		return true
+	}
+	var one Nat
+	one.SetUint64(1)
+	z := new(Nat).Add(&a, &one, -1)
+	m := ModulusFromNat(z)
+	z.ModInverse(&a, m)
+	if !z.checkInvariants() {
+		return false
+	}
+	return z.Eq(&a) == 1
+}
+
+func TestModInverseMinusOne(t *testing.T) {
+	err := quick.Check(testModInverseMinusOne, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func testModInverseEvenMinusOne(a Nat) bool {
+	if !a.checkInvariants() {
+		return false
+	}
+	// Set the lowest bit
+	if len(a.limbs) != 0 {
+		a.limbs[0] |= 1
+	}
+	var zero Nat
+	zero.SetUint64(0)
+	if a.Eq(&zero) == 1 {
+		return true
+	}
+	var one Nat
+	one.SetUint64(1)
+	var z Nat
 	z.Add(&a, &one, a.AnnouncedLen()+1)
 	if !z.checkInvariants() {
 		return false
@@ -637,417 +1728,2426 @@ func TestModInverseEvenOne(t *testing.T) {
 	}
 }
 
-func testExpAddition(x Nat, a Nat, b Nat, m Modulus) bool {
-	if !(x.checkInvariants() && a.checkInvariants() && b.checkInvariants()) {
+func testModInverseEvenChecked(a Nat, m Modulus) bool {
+	inverse, ok := new(Nat).ModInverseEven(&a, &m)
+	if !inverse.checkInvariants() {
 		return false
 	}
-	var expA, expB, aPlusB, way1, way2 Nat
-	expA.Exp(&x, &a, &m)
-	expB.Exp(&x, &b, &m)
-	// Enough bits to hold the full amount
-	cap := len(a.limbs)
-	if l := len(b.limbs); l > cap {
-		cap = l
-	}
-	aPlusB.Add(&a, &b, cap*_W+1)
-	way1.ModMul(&expA, &expB, &m)
-	way2.Exp(&x, &aPlusB, &m)
-	if !(way1.checkInvariants() && way2.checkInvariants() && aPlusB.checkInvariants()) {
+	expectedOk := a.IsUnit(&m)
+	if ok != expectedOk {
 		return false
 	}
-	return way1.Eq(&way2) == 1
+	if ok == 0 {
+		return inverse.EqZero() == 1
+	}
+	one := new(Nat).SetUint64(1)
+	one.Mod(one, &m)
+	return new(Nat).ModMul(&a, inverse, &m).Eq(one) == 1
 }
 
-func TestExpAddition(t *testing.T) {
-	err := quick.Check(testExpAddition, &quick.Config{})
+func TestModInverseEvenChecked(t *testing.T) {
+	err := quick.Check(testModInverseEvenChecked, &quick.Config{})
 	if err != nil {
 		t.Error(err)
 	}
 }
 
-func testSqrtRoundTrip(x *Nat, p *Modulus) bool {
-	xSquared := x.ModMul(x, x, p)
-	xRoot := new(Nat).ModSqrt(xSquared, p)
-	if !(xRoot.checkInvariants() && xSquared.checkInvariants()) {
-		return false
+func TestModInverseEvenCheckedExamples(t *testing.T) {
+	m := ModulusFromUint64(3 << 10)
+	for _, x := range []uint64{0, 2, 3, 6, 1024, 3 << 10} {
+		actual, ok := new(Nat).ModInverseEven(new(Nat).SetUint64(x), m)
+		if ok != 0 {
+			t.Errorf("expected %d to not be invertible", x)
+		}
+		if actual.EqZero() != 1 {
+			t.Errorf("expected %d to have a zero result, got %+v", x, actual)
+		}
 	}
-	xRoot.ModMul(xRoot, xRoot, p)
-	if !xRoot.checkInvariants() {
-		return false
+	actual, ok := new(Nat).ModInverseEven(new(Nat).SetUint64(5), m)
+	expected := new(Nat).SetUint64(1229)
+	if ok != 1 || expected.Eq(actual) != 1 {
+		t.Errorf("%+v != %+v", expected, actual)
 	}
-	return xRoot.Eq(xSquared) == 1
 }
 
-func testModSqrt(x Nat) bool {
-	if !x.checkInvariants() {
-		return false
-	}
-	p := ModulusFromBytes([]byte{
-		13,
-	})
-	if !testSqrtRoundTrip(&x, p) {
-		return false
+// montgomeryMulMatchesBig checks that montgomeryMul calculates x * y * R^-1 mod m, using big.Int
+//
+// Here R = 2^(_W * len(m.nat.limbs)), and x and y are first reduced modulo m.
+func montgomeryMulMatchesBig(x *Nat, y *Nat, m *Modulus) bool {
+	size := len(m.nat.limbs)
+	xLimbs := new(Nat).Mod(x, m).limbs
+	yLimbs := new(Nat).Mod(y, m).limbs
+	out := make([]Word, size)
+	scratch := make([]Word, size)
+	montgomeryMul(xLimbs, yLimbs, out, scratch, m)
+	actual := new(Nat)
+	actual.limbs = out
+	actual.announced = _W * size
+
+	mBig := m.Big()
+	rInv := new(big.Int).Lsh(big.NewInt(1), uint(_W*size))
+	rInv.ModInverse(rInv, mBig)
+	expected := new(big.Int).Mul(x.Big(), y.Big())
+	expected.Mul(expected, rInv)
+	expected.Mod(expected, mBig)
+	return actual.Big().Cmp(expected) == 0
+}
+
+func testMontgomeryMulMatchesBig(x Nat, y Nat, m Modulus) bool {
+	if m.even {
+		return true
 	}
-	p = ModulusFromUint64((1 << 61) - 1)
-	if !testSqrtRoundTrip(&x, p) {
-		return false
+	return montgomeryMulMatchesBig(&x, &y, &m)
+}
+
+func TestMontgomeryMulMatchesBig(t *testing.T) {
+	err := quick.Check(testMontgomeryMulMatchesBig, &quick.Config{})
+	if err != nil {
+		t.Error(err)
 	}
-	p = ModulusFromBytes([]byte{
-		0x1, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
-	})
-	if !testSqrtRoundTrip(&x, p) {
-		return false
+}
+
+func TestMontgomeryMulBoundaryExamples(t *testing.T) {
+	one := new(Nat).SetUint64(1)
+	// These moduli are chosen to be close to limb boundaries, which makes the
+	// intermediate result overflow a limb, or land just above or below m.
+	moduli := []*Modulus{
+		ModulusFromUint64(3),
+		ModulusFromNat(new(Nat).Sub(new(Nat).Lsh(one, _W, -1), one, _W)),
+		ModulusFromNat(new(Nat).Add(new(Nat).Lsh(one, _W, -1), one, -1)),
+		ModulusFromNat(new(Nat).Sub(new(Nat).Lsh(one, 2*_W, -1), one, 2*_W)),
+		ModulusFromNat(new(Nat).Sub(new(Nat).Lsh(one, 2*_W, -1), new(Nat).SetUint64(59), 2*_W)),
+		ModulusFromNat(new(Nat).Add(new(Nat).Lsh(one, 2*_W-1, -1), one, -1)),
+		ModulusFromBytes(modulus2048()),
+	}
+	for _, m := range moduli {
+		mMinusOne := new(Nat).Sub(&m.nat, one, m.BitLen())
+		mMinusTwo := new(Nat).Sub(mMinusOne, one, m.BitLen())
+		half := new(Nat).Rsh(mMinusOne, 1, -1)
+		halfPlusOne := new(Nat).Add(half, one, m.BitLen())
+		// R mod m, i.e. 1 in Montgomery form
+		rModM := new(Nat).Lsh(one, uint(_W*len(m.nat.limbs)), -1)
+		values := []*Nat{new(Nat), one, mMinusOne, mMinusTwo, half, halfPlusOne, rModM}
+		for _, x := range values {
+			for _, y := range values {
+				if !montgomeryMulMatchesBig(x, y, m) {
+					t.Errorf("mismatch for %v * %v mod %v", x, y, m)
+				}
+			}
+			if !montgomerySquareMatchesMul(x, m) {
+				t.Errorf("mismatch for %v^2 mod %v", x, m)
+			}
+		}
 	}
-	p = ModulusFromBytes([]byte{
-		0x3,
-		0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
-		0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xfb,
-	})
-	if !testSqrtRoundTrip(&x, p) {
-		return false
+}
+
+// montgomerySquareMatchesMul checks that montgomerySquare agrees with montgomeryMul
+func montgomerySquareMatchesMul(x *Nat, m *Modulus) bool {
+	size := len(m.nat.limbs)
+	xLimbs := new(Nat).Mod(x, m).limbs
+	expected := make([]Word, size)
+	montgomeryMul(xLimbs, xLimbs, expected, make([]Word, size), m)
+	actual := make([]Word, size)
+	montgomerySquare(xLimbs, actual, make([]Word, 2*size), m)
+	return cmpEq(expected, actual) == 1
+}
+
+func testMontgomerySquareMatchesMul(x Nat, m Modulus) bool {
+	if m.even {
+		return true
 	}
-	// 2^224 - 2^96 + 1
-	p = ModulusFromBytes([]byte{
-		0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
-		00, 00, 00, 00, 00, 00, 00, 00, 00, 00, 00, 1,
-	})
-	return testSqrtRoundTrip(&x, p)
+	return montgomerySquareMatchesMul(&x, &m)
 }
 
-func TestModSqrt(t *testing.T) {
-	err := quick.Check(testModSqrt, &quick.Config{})
+func TestMontgomerySquareMatchesMul(t *testing.T) {
+	err := quick.Check(testMontgomerySquareMatchesMul, &quick.Config{})
 	if err != nil {
 		t.Error(err)
 	}
 }
 
-func testMultiplyThenDivide(x Nat, m Modulus) bool {
-
-	if !x.checkInvariants() {
+func testModSquareMatchesModMul(x Nat, m Modulus) bool {
+	expected := new(Nat).ModMul(&x, &x, &m)
+	actual := new(Nat).ModSquare(&x, &m)
+	if !actual.checkInvariants() || actual.Eq(expected) != 1 {
 		return false
 	}
-	mNat := &m.nat
+	// Squaring in place, with an already reduced input
+	actual.ModSquare(actual.Mod(&x, &m), &m)
+	return actual.Eq(expected) == 1
+}
 
-	xm := new(Nat).Mul(&x, mNat, x.AnnouncedLen()+mNat.AnnouncedLen())
-	divided := new(Nat).Div(xm, &m, x.AnnouncedLen())
-	if divided.Eq(&x) != 1 {
-		return false
+func TestModSquareMatchesModMul(t *testing.T) {
+	err := quick.Check(testModSquareMatchesModMul, &quick.Config{})
+	if err != nil {
+		t.Error(err)
 	}
-	// Adding m - 1 shouldn't change the result either
-	xm.Add(xm, new(Nat).Sub(mNat, new(Nat).SetUint64(1), xm.AnnouncedLen()), xm.AnnouncedLen())
-	divided = new(Nat).Div(xm, &m, x.AnnouncedLen())
-	if !(divided.checkInvariants() && xm.checkInvariants()) {
-		return false
+}
+
+func testModInversePrimeMatchesModInverse(x Nat) bool {
+	m, _ := ModulusFromHex("FFFFFFFF00000001000000000000000000000000FFFFFFFFFFFFFFFFFFFFFFFF")
+	for _, p := range []*Modulus{m, ModulusFromUint64(13)} {
+		expected := new(Nat).ModInverse(&x, p)
+		actual := new(Nat).ModInversePrime(&x, p)
+		if !actual.checkInvariants() || expected.Eq(actual) != 1 {
+			return false
+		}
 	}
-	return divided.Eq(&x) == 1
+	return true
 }
 
-func TestMultiplyThenDivide(t *testing.T) {
-	err := quick.Check(testMultiplyThenDivide, &quick.Config{})
+func TestModInversePrimeMatchesModInverse(t *testing.T) {
+	err := quick.Check(testModInversePrimeMatchesModInverse, &quick.Config{})
 	if err != nil {
 		t.Error(err)
 	}
 }
 
-func TestUint64Creation(t *testing.T) {
-	var x, y Nat
+func TestModInversePrimeExamples(t *testing.T) {
+	p := ModulusFromUint64(13)
+	x := new(Nat).SetUint64(5)
+	expected := new(Nat).SetUint64(8)
+	actual := new(Nat).ModInversePrime(x, p)
+	if expected.Eq(actual) != 1 {
+		t.Errorf("%+v != %+v", expected, actual)
+	}
 	x.SetUint64(0)
-	y.SetUint64(0)
-	if x.Eq(&y) != 1 {
-		t.Errorf("%+v != %+v", x, y)
+	expected.SetUint64(0)
+	actual.ModInversePrime(x, p)
+	if expected.Eq(actual) != 1 {
+		t.Errorf("%+v != %+v", expected, actual)
 	}
-	x.SetUint64(1)
-	if x.Eq(&y) == 1 {
-		t.Errorf("%+v == %+v", x, y)
+}
+
+func testModInverseBatchPrimeMatchesModInverse(xs []Nat) bool {
+	p, _ := ModulusFromHex("FFFFFFFF00000001000000000000000000000000FFFFFFFFFFFFFFFFFFFFFFFF")
+	in := make([]*Nat, len(xs))
+	out := make([]*Nat, len(xs))
+	for i := range xs {
+		in[i] = &xs[i]
+		out[i] = new(Nat)
+	}
+	ModInverseBatchPrime(out, in, p)
+	for i := range xs {
+		if !out[i].checkInvariants() {
+			return false
+		}
+		if out[i].Eq(new(Nat).ModInversePrime(&xs[i], p)) != 1 {
+			return false
+		}
 	}
-	x.SetUint64(0x1111)
-	y.SetUint64(0x1111)
-	if x.Eq(&y) != 1 {
-		t.Errorf("%+v != %+v", x, y)
+	return true
+}
+
+func TestModInverseBatchPrimeMatchesModInverse(t *testing.T) {
+	err := quick.Check(testModInverseBatchPrimeMatchesModInverse, &quick.Config{})
+	if err != nil {
+		t.Error(err)
 	}
 }
 
-func TestAddExamples(t *testing.T) {
-	var x, y, z Nat
-	x.SetUint64(100)
-	y.SetUint64(100)
-	z.SetUint64(200)
-	x = *x.Add(&x, &y, 8)
-	if x.Eq(&z) != 1 {
-		t.Errorf("%+v != %+v", x, z)
+func TestModInverseBatchPrimeExamples(t *testing.T) {
+	p := ModulusFromUint64(13)
+	in := []*Nat{new(Nat).SetUint64(2), new(Nat).SetUint64(0), new(Nat).SetUint64(5), new(Nat).SetUint64(26), new(Nat).SetUint64(12)}
+	// 2 * 7 = 14, 5 * 8 = 40, 12 * 12 = 144, are all 1 mod 13
+	expected := []*Nat{new(Nat).SetUint64(7), new(Nat).SetUint64(0), new(Nat).SetUint64(8), new(Nat).SetUint64(0), new(Nat).SetUint64(12)}
+	// Using the inputs as outputs should also work
+	ModInverseBatchPrime(in, in, p)
+	for i := range in {
+		if expected[i].Eq(in[i]) != 1 {
+			t.Errorf("%+v != %+v", expected[i], in[i])
+		}
 	}
-	z.SetUint64(300 - 256)
-	x = *x.Add(&x, &y, 8)
-	if x.Eq(&z) != 1 {
-		t.Errorf("%+v != %+v", x, z)
+	// Empty batches are fine
+	ModInverseBatchPrime(nil, nil, p)
+}
+
+func TestModSquareExamples(t *testing.T) {
+	m := ModulusFromBytes(modulus2048())
+	x := new(Nat).SetBytes(ones())
+	expected := new(Nat).ModMul(x, x, m)
+	actual := new(Nat).ModSquare(x, m)
+	if expected.Eq(actual) != 1 {
+		t.Errorf("%+v != %+v", expected, actual)
 	}
-	x.SetUint64(0xf3e5487232169930)
-	y.SetUint64(0)
-	z.SetUint64(0xf3e5487232169930)
-	var x2 Nat
-	x2.Add(&x, &y, 128)
-	if x2.Eq(&z) != 1 {
-		t.Errorf("%+v != %+v", x, z)
+	m = ModulusFromUint64(13)
+	x.SetUint64(12)
+	expected.SetUint64(1)
+	actual.ModSquare(x, m)
+	if expected.Eq(actual) != 1 {
+		t.Errorf("%+v != %+v", expected, actual)
 	}
 }
 
-func TestSubExamples(t *testing.T) {
-	x := new(Nat).SetUint64(100)
-	y := new(Nat).SetUint64(200)
-	y.Sub(y, x, 8)
-	if y.Eq(x) != 1 {
-		t.Errorf("%+v != %+v", y, x)
+func testAddShiftedMatchesAdd(z Nat, x Nat, offset uint8, cap uint16) bool {
+	wordOffset := int(offset % 8)
+	shifted := new(Nat).Lsh(&x, uint(wordOffset*_W), -1)
+	for _, c := range []int{-1, int(cap % 1024)} {
+		expected := new(Nat).Add(&z, shifted, c)
+		actual := z.Clone().AddShifted(&x, wordOffset, c)
+		if !actual.checkInvariants() || actual.AnnouncedLen() != expected.AnnouncedLen() {
+			return false
+		}
+		if actual.Eq(expected) != 1 {
+			return false
+		}
 	}
+	// Aliasing should also work
+	expected := new(Nat).Add(&x, new(Nat).Lsh(&x, uint(wordOffset*_W), -1), -1)
+	aliased := x.Clone()
+	aliased.AddShifted(aliased, wordOffset, -1)
+	return aliased.Eq(expected) == 1
 }
 
-func TestMulExamples(t *testing.T) {
-	var x, y, z Nat
-	x.SetUint64(10)
-	y.SetUint64(10)
-	z.SetUint64(100)
-	x = *x.Mul(&x, &y, 8)
-	if x.Eq(&z) != 1 {
-		t.Errorf("%+v != %+v", x, z)
-	}
-	z.SetUint64(232)
-	x = *x.Mul(&x, &y, 8)
-	if x.Eq(&z) != 1 {
-		t.Errorf("%+v != %+v", x, z)
+func TestAddShiftedMatchesAdd(t *testing.T) {
+	err := quick.Check(testAddShiftedMatchesAdd, &quick.Config{})
+	if err != nil {
+		t.Error(err)
 	}
 }
 
-func TestModAddExamples(t *testing.T) {
-	m := ModulusFromUint64(13)
-	var x, y, z Nat
-	x.SetUint64(40)
-	y.SetUint64(40)
-	x = *x.ModAdd(&x, &y, m)
-	z.SetUint64(2)
-	if x.Eq(&z) != 1 {
-		t.Errorf("%+v != %+v", x, z)
+func TestAddShiftedExamples(t *testing.T) {
+	// The carry should propagate past the end of x
+	z := new(Nat).SetBig(new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 3*_W), big.NewInt(1)), 3*_W)
+	x := new(Nat).SetUint64(1)
+	expected := new(Nat).SetBig(new(big.Int).Add(z.Big(), new(big.Int).Lsh(big.NewInt(1), _W)), 3*_W+1)
+	actual := z.Clone().AddShifted(x, 1, -1)
+	if expected.Eq(actual) != 1 {
+		t.Errorf("%+v != %+v", expected, actual)
+	}
+	// An offset past the capacity leaves z unchanged
+	actual = z.Clone().AddShifted(x, 5, 3*_W)
+	if z.Eq(actual) != 1 {
+		t.Errorf("%+v != %+v", z, actual)
 	}
 }
 
-func TestModMulExamples(t *testing.T) {
-	var x, y, z Nat
-	m := ModulusFromUint64(13)
-	x.SetUint64(40)
-	y.SetUint64(40)
-	x = *x.ModMul(&x, &y, m)
-	z.SetUint64(1)
-	if x.Eq(&z) != 1 {
-		t.Errorf("%+v != %+v", x, z)
+func testAddCarryMatchesBig(x Nat, y Nat, cap uint16) bool {
+	c := int(cap & 1023)
+	xBig := new(big.Int).Set(x.Big())
+	yBig := new(big.Int).Set(y.Big())
+	mask := new(big.Int).Lsh(big.NewInt(1), uint(c))
+	mask.Sub(mask, big.NewInt(1))
+	xBig.And(xBig, mask)
+	yBig.And(yBig, mask)
+	sum := new(big.Int).Add(xBig, yBig)
+	expectedCarry := Choice(sum.Bit(c))
+	z := new(Nat)
+	carry := z.AddCarry(&x, &y, c)
+	if !z.checkInvariants() || z.AnnouncedLen() != c {
+		return false
 	}
-	m = ModulusFromBytes([]byte{1, 0, 0, 0, 0, 0, 0, 0, 1})
-	x.SetUint64(1)
-	x = *x.ModMul(&x, &x, m)
-	z.SetUint64(1)
-	if x.Eq(&z) != 1 {
-		t.Errorf("%+v != %+v", x, z)
+	if carry != expectedCarry || z.Big().Cmp(sum.And(sum, mask)) != 0 {
+		return false
 	}
-	m = ModulusFromBytes([]byte{1, 0, 0, 0, 0, 0, 0, 0, 1})
-	x.SetUint64(16390320477281102916)
-	y.SetUint64(13641051446569424315)
-	x = *x.ModMul(&x, &y, m)
-	z.SetUint64(12559215458690093993)
-	if x.Eq(&z) != 1 {
-		t.Errorf("%+v != %+v", x, z)
+	expectedBorrow := Choice(0)
+	if xBig.Cmp(yBig) < 0 {
+		expectedBorrow = 1
+	}
+	diff := new(big.Int).Sub(xBig, yBig)
+	diff.And(diff, mask)
+	borrow := z.SubBorrow(&x, &y, c)
+	if !z.checkInvariants() || z.AnnouncedLen() != c {
+		return false
 	}
+	return borrow == expectedBorrow && z.Big().Cmp(diff) == 0
 }
 
-func TestModExamples(t *testing.T) {
-	var x, test Nat
-	x.SetUint64(40)
-	m := ModulusFromUint64(13)
-	x.Mod(&x, m)
-	test.SetUint64(1)
-	if x.Eq(&test) != 1 {
-		t.Errorf("%+v != %+v", x, test)
-	}
-	m = ModulusFromBytes([]byte{13, 0, 0, 0, 0, 0, 0, 0, 1})
-	x.SetBytes([]byte{41, 0, 0, 0, 0, 0, 0, 0, 0})
-	x.Mod(&x, m)
-	test.SetBytes([]byte{1, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFD})
-	if x.Eq(&test) != 1 {
-		t.Errorf("%+v != %+v", x, test)
+func TestAddCarryMatchesBig(t *testing.T) {
+	err := quick.Check(testAddCarryMatchesBig, &quick.Config{})
+	if err != nil {
+		t.Error(err)
 	}
 }
 
-func TestModInverseExamples(t *testing.T) {
-	x, z := new(Nat), new(Nat)
-	x.SetUint64(2)
-	m := ModulusFromUint64(13)
-	x = x.ModInverse(x, m)
-	z.SetUint64(7)
-	if x.Eq(z) != 1 {
-		t.Errorf("%+v != %+v", x, z)
+func TestAddCarryExamples(t *testing.T) {
+	x := new(Nat).SetUint64(200)
+	y := new(Nat).SetUint64(100)
+	z := new(Nat)
+	if z.AddCarry(x, y, 8) != 1 {
+		t.Errorf("expected 200 + 100 to carry out of 8 bits")
 	}
-	x.SetUint64(16359684999990746055)
-	m = ModulusFromUint64(7)
-	x = x.ModInverse(x, m)
-	z.SetUint64(3)
-	if x.Eq(z) != 1 {
-		t.Errorf("%+v != %+v", x, z)
+	expected := new(Nat).SetUint64(44)
+	if expected.Eq(z) != 1 {
+		t.Errorf("%+v != %+v", expected, z)
 	}
-	x.SetUint64(461423694560)
-	m = ModulusFromUint64(461423694561)
-	z.ModInverse(x, m)
-	if x.Eq(z) != 1 {
-		t.Errorf("%+v != %+v", x, z)
+	if z.AddCarry(x, y, 9) != 0 {
+		t.Errorf("expected 200 + 100 to not carry out of 9 bits")
 	}
-	x.SetHex("2AFAE74A613B0764098D86")
-	m, _ = ModulusFromHex("2AFAE74A613B0764098D87")
-	z.ModInverse(x, m)
-	if x.Eq(z) != 1 {
-		t.Errorf("%+v != %+v", x, z)
+	max := new(Nat).SetUint64(^uint64(0))
+	if z.AddCarry(max, new(Nat).SetUint64(1), 64) != 1 || z.EqZero() != 1 {
+		t.Errorf("expected 2^64 - 1 + 1 to carry out of 64 bits")
 	}
-	x.SetHex("930330931B69B44B8E")
-	m, _ = ModulusFromHex("930330931B69B44B8F")
-	z.ModInverse(x, m)
-	if x.Eq(z) != 1 {
-		t.Errorf("%+v != %+v", x, z)
+	if z.SubBorrow(y, x, 8) != 1 || z.SubBorrow(x, y, 8) != 0 {
+		t.Errorf("unexpected borrow when subtracting 100 and 200")
 	}
-	x.SetHex("DDAB4CDD41300C5F9511FE68")
-	m, _ = ModulusFromHex("DDAB4CDD41300C5F9511FE69")
-	z.ModInverse(x, m)
-	if x.Eq(z) != 1 {
-		t.Errorf("%+v != %+v", x, z)
+	// The inputs shouldn't be modified by truncation
+	z.AddCarry(x, y, 4)
+	if x.Eq(new(Nat).SetUint64(200)) != 1 {
+		t.Errorf("expected x to be unchanged, got %+v", x)
 	}
-	x.SetHex("A200F1C011C86FFF9A")
-	m, _ = ModulusFromHex("A200F1C011C86FFF9B")
-	z.ModInverse(x, m)
-	if x.Eq(z) != 1 {
-		t.Errorf("%+v != %+v", x, z)
+}
+
+func testModDivMatchesInverse(a Nat, b Nat, m Modulus) bool {
+	actual, ok := new(Nat).ModDiv(&a, &b, &m)
+	if !actual.checkInvariants() {
+		return false
 	}
-	x.SetHex("E7B6E7C1CCB2CEDE797F87937E")
-	m, _ = ModulusFromHex("E7B6E7C1CCB2CEDE797F87937F")
-	z.ModInverse(x, m)
-	if x.Eq(z) != 1 {
-		t.Errorf("%+v != %+v", x, z)
+	if ok != b.IsUnit(&m) {
+		return false
 	}
+	if ok == 0 {
+		return actual.EqZero() == 1
+	}
+	// Multiplying back by b should give us a
+	expected := new(Nat).Mod(&a, &m)
+	return new(Nat).ModMul(actual, &b, &m).Eq(expected) == 1
 }
 
-func TestExpExamples(t *testing.T) {
-	var x, y, z Nat
-	x.SetUint64(3)
-	y.SetUint64(345)
-	m := ModulusFromUint64(13)
-	x = *x.Exp(&x, &y, m)
-	z.SetUint64(1)
-	if x.Eq(&z) != 1 {
-		t.Errorf("%+v != %+v", x, z)
-	}
-	m = ModulusFromBytes([]byte{1, 0, 0, 0, 0, 0, 0, 0, 1})
-	x.SetUint64(1)
-	y.SetUint64(2)
-	x = *x.Exp(&x, &y, m)
-	z.SetUint64(1)
-	if x.Eq(&z) != 1 {
-		t.Errorf("%+v != %+v", x, z)
+func TestModDivMatchesInverse(t *testing.T) {
+	err := quick.Check(testModDivMatchesInverse, &quick.Config{})
+	if err != nil {
+		t.Error(err)
 	}
 }
 
-func TestSetBytesExamples(t *testing.T) {
-	var x, z Nat
-	x.SetBytes([]byte{0x12, 0x34, 0x56})
-	z.SetUint64(0x123456)
-	if x.Eq(&z) != 1 {
-		t.Errorf("%+v != %+v", x, z)
+func TestModDivExamples(t *testing.T) {
+	p := ModulusFromUint64(13)
+	actual, ok := new(Nat).ModDiv(new(Nat).SetUint64(3), new(Nat).SetUint64(2), p)
+	expected := new(Nat).SetUint64(8)
+	if ok != 1 || expected.Eq(actual) != 1 {
+		t.Errorf("%+v != %+v", expected, actual)
 	}
-	x.SetBytes([]byte{0x00, 0x00, 0xAA, 0xBB, 0xCC, 0xDD, 0xEE, 0xFF})
-	z.SetUint64(0xAABBCCDDEEFF)
-	if x.Eq(&z) != 1 {
-		t.Errorf("%+v != %+v", x, z)
+	_, ok = new(Nat).ModDiv(new(Nat).SetUint64(3), new(Nat).SetUint64(26), p)
+	if ok != 0 {
+		t.Errorf("expected division by 0 mod 13 to fail")
 	}
 }
 
-func TestFillBytesExamples(t *testing.T) {
-	var x Nat
-	expected := []byte{0x00, 0x00, 0x00, 0x00, 0xAA, 0xBB, 0xCC, 0xDD}
-	x.SetBytes(expected)
-	buf := make([]byte, 8)
-	x.FillBytes(buf)
-	if !bytes.Equal(expected, buf) {
-		t.Errorf("%+v != %+v", expected, buf)
+func testPowerOfTwoModulusMatchesBig(x Nat, y Nat, k uint16) bool {
+	m := ModulusFromNat(new(Nat).Lsh(new(Nat).SetUint64(1), uint(k&511), -1))
+	if !m.powerOfTwo {
+		return false
+	}
+	xBefore := x.Clone()
+	yBefore := y.Clone()
+	mBig := m.Big()
+	xBig := x.Big()
+	yBig := y.Big()
+	check := func(actual *Nat, expected *big.Int) bool {
+		expected.Mod(expected, mBig)
+		return actual.checkInvariants() && actual.AnnouncedLen() == m.BitLen() && actual.Big().Cmp(expected) == 0
+	}
+	if !check(new(Nat).Mod(&x, m), new(big.Int).Set(xBig)) {
+		return false
+	}
+	if !check(new(Nat).ModMul(&x, &y, m), new(big.Int).Mul(xBig, yBig)) {
+		return false
+	}
+	if !check(new(Nat).ModAdd(&x, &y, m), new(big.Int).Add(xBig, yBig)) {
+		return false
 	}
+	if !check(new(Nat).ModSub(&x, &y, m), new(big.Int).Sub(xBig, yBig)) {
+		return false
+	}
+	// None of these operations should modify their inputs
+	return x.Eq(xBefore) == 1 && y.Eq(yBefore) == 1
 }
 
-func TestBytesExamples(t *testing.T) {
-	var x Nat
-	expected := []byte{0x11, 0x22, 0x33, 0x44, 0xAA, 0xBB, 0xCC, 0xDD}
-	x.SetBytes(expected)
-	out := x.Bytes()
-	if !bytes.Equal(expected, out) {
-		t.Errorf("%+v != %+v", expected, out)
+func TestPowerOfTwoModulusMatchesBig(t *testing.T) {
+	err := quick.Check(testPowerOfTwoModulusMatchesBig, &quick.Config{})
+	if err != nil {
+		t.Error(err)
 	}
 }
 
-func TestByteExample(t *testing.T) {
-	x := new(Nat).SetBytes([]byte{8, 7, 6, 5, 4, 3, 2, 1, 0})
-	for i := 0; i <= 8; i++ {
-		expected := byte(i)
-		actual := x.Byte(i)
-		if expected != actual {
-			t.Errorf("%+v != %+v", expected, actual)
+func TestPowerOfTwoModulusExamples(t *testing.T) {
+	for _, m := range []*Modulus{ModulusFromUint64(3), ModulusFromUint64(6), ModulusFromUint64(1<<32 + 1)} {
+		if m.powerOfTwo {
+			t.Errorf("expected %v to not be a power of two", m)
 		}
 	}
+	m := ModulusFromUint64(1)
+	if !m.powerOfTwo {
+		t.Errorf("expected 1 to be a power of two")
+	}
+	x := new(Nat).SetUint64(12345)
+	if new(Nat).ModMul(x, x, m).EqZero() != 1 {
+		t.Errorf("expected x * x mod 1 to be 0")
+	}
 }
 
-func TestModInverseEvenExamples(t *testing.T) {
-	var z, x Nat
-	x.SetUint64(9)
-	m := ModulusFromUint64(10)
-	x.ModInverse(&x, m)
-	z.SetUint64(9)
-	if x.Eq(&z) != 1 {
-		t.Errorf("%+v != %+v", x, z)
+func testMulWideMatchesBig(x Nat, y Nat) bool {
+	z := new(Nat).MulWide(&x, &y)
+	if !z.checkInvariants() {
+		return false
 	}
-	x.SetUint64(1)
-	m = ModulusFromUint64(10)
-	x.ModInverse(&x, m)
-	z.SetUint64(1)
-	if x.Eq(&z) != 1 {
-		t.Errorf("%+v != %+v", x, z)
+	if z.AnnouncedLen() != x.AnnouncedLen()+y.AnnouncedLen() {
+		return false
 	}
-	x.SetUint64(19)
-	x.ModInverse(&x, m)
-	z.SetUint64(9)
-	if x.Eq(&z) != 1 {
-		t.Errorf("%+v != %+v", x, z)
+	expected := new(big.Int).Mul(x.Big(), y.Big())
+	return z.Big().Cmp(expected) == 0
+}
+
+func TestMulWideMatchesBig(t *testing.T) {
+	err := quick.Check(testMulWideMatchesBig, &quick.Config{})
+	if err != nil {
+		t.Error(err)
 	}
-	x.SetUint64(99)
-	x.ModInverse(&x, m)
-	z.SetUint64(9)
-	if x.Eq(&z) != 1 {
-		t.Errorf("%+v != %+v", x, z)
+}
+
+func TestMulWideExamples(t *testing.T) {
+	// The product of these needs more bits than either of them
+	x := new(Nat).SetUint64(^uint64(0))
+	y := new(Nat).SetUint64(^uint64(0))
+	z := new(Nat).MulWide(x, y)
+	expected := new(big.Int).Mul(x.Big(), y.Big())
+	if z.Big().Cmp(expected) != 0 {
+		t.Errorf("%+v != %+v", expected, z.Big())
+	}
+	if z.AnnouncedLen() != 128 {
+		t.Errorf("%+v != %+v", z.AnnouncedLen(), 128)
+	}
+	// Aliasing should also work
+	x.MulWide(x, x)
+	if x.Eq(z) != 1 {
+		t.Errorf("%+v != %+v", z, x)
+	}
+}
+
+func testMulAddMatchesMulThenAdd(x Nat, y Nat, c Nat, cap uint16) bool {
+	cp := int(cap % 1024)
+	expected := new(Nat).Mul(new(Nat).SetNat(&x), new(Nat).SetNat(&y), cp)
+	expected.Add(expected, new(Nat).SetNat(&c), cp)
+	actual := new(Nat).MulAdd(&x, &y, &c, cp)
+	if !actual.checkInvariants() || actual.AnnouncedLen() != cp {
+		return false
+	}
+	if expected.Eq(actual) != 1 {
+		return false
+	}
+	// Aliasing any of the arguments should also work
+	for i := 0; i < 3; i++ {
+		args := []*Nat{new(Nat).SetNat(&x), new(Nat).SetNat(&y), new(Nat).SetNat(&c)}
+		args[i].MulAdd(args[0], args[1], args[2], cp)
+		if expected.Eq(args[i]) != 1 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestMulAddMatchesMulThenAdd(t *testing.T) {
+	err := quick.Check(testMulAddMatchesMulThenAdd, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMulAddExamples(t *testing.T) {
+	x := new(Nat).SetUint64(^uint64(0))
+	c := new(Nat).SetUint64(^uint64(0))
+	// The default capacity leaves an extra bit for the carry out of the addition
+	actual := new(Nat).MulAdd(x, x, c, -1)
+	expected := new(big.Int).Mul(x.Big(), x.Big())
+	expected.Add(expected, c.Big())
+	if actual.Big().Cmp(expected) != 0 {
+		t.Errorf("%+v != %+v", expected, actual.Big())
+	}
+	if actual.AnnouncedLen() != 129 {
+		t.Errorf("%+v != %+v", actual.AnnouncedLen(), 129)
+	}
+}
+
+func testExpAddition(x Nat, a Nat, b Nat, m Modulus) bool {
+	if !(x.checkInvariants() && a.checkInvariants() && b.checkInvariants()) {
+		return false
+	}
+	var expA, expB, aPlusB, way1, way2 Nat
+	expA.Exp(&x, &a, &m)
+	expB.Exp(&x, &b, &m)
+	// Enough bits to hold the full amount
+	cap := len(a.limbs)
+	if l := len(b.limbs); l > cap {
+		cap = l
+	}
+	aPlusB.Add(&a, &b, cap*_W+1)
+	way1.ModMul(&expA, &expB, &m)
+	way2.Exp(&x, &aPlusB, &m)
+	if !(way1.checkInvariants() && way2.checkInvariants() && aPlusB.checkInvariants()) {
+		return false
+	}
+	return way1.Eq(&way2) == 1
+}
+
+func TestExpAddition(t *testing.T) {
+	err := quick.Check(testExpAddition, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func testExpBlinded(x Nat, y Nat, m Modulus) bool {
+	if !(x.checkInvariants() && y.checkInvariants()) {
+		return false
+	}
+	expected := new(Nat).Exp(&x, &y, &m)
+	actual, err := new(Nat).ExpBlinded(&x, &y, &m, rand.New(rand.NewSource(0)))
+	if err != nil {
+		return false
+	}
+	if !actual.checkInvariants() {
+		return false
+	}
+	return actual.Eq(expected) == 1
+}
+
+func TestExpBlinded(t *testing.T) {
+	err := quick.Check(testExpBlinded, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func testRandomUnitIsUnit(m Modulus, seed int64) bool {
+	if m.BitLen() <= 1 {
+		return true
+	}
+	r, err := randomUnit(rand.New(rand.NewSource(seed)), &m)
+	if err != nil {
+		return false
+	}
+	if !r.checkInvariants() {
+		return false
+	}
+	_, _, lt := r.CmpMod(&m)
+	return r.IsUnit(&m) == 1 && lt == 1
+}
+
+func TestRandomUnitIsUnit(t *testing.T) {
+	err := quick.Check(testRandomUnitIsUnit, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestExpBlindedExamples(t *testing.T) {
+	x := new(Nat).SetUint64(3)
+	y := new(Nat).SetUint64(345)
+	for _, m := range []*Modulus{ModulusFromUint64(13), ModulusFromUint64(20), ModulusFromBytes(modulus2048Even())} {
+		expected := new(Nat).SetBig(new(big.Int).Exp(big.NewInt(3), big.NewInt(345), m.Big()), m.BitLen())
+		actual, err := new(Nat).ExpBlinded(x, y, m, rand.New(rand.NewSource(0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected.Eq(actual) != 1 {
+			t.Errorf("%+v != %+v", expected, actual)
+		}
+		// Different randomness should produce the same result
+		actual, err = new(Nat).ExpBlinded(x, y, m, rand.New(rand.NewSource(1)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected.Eq(actual) != 1 {
+			t.Errorf("%+v != %+v", expected, actual)
+		}
+	}
+	// A failing source of randomness should produce an error
+	_, err := new(Nat).ExpBlinded(x, y, ModulusFromUint64(13), bytes.NewReader(nil))
+	if err == nil {
+		t.Errorf("expected error from empty source of randomness")
+	}
+}
+
+func testExpLadderMatchesExp(x Nat, y Nat, m Modulus) bool {
+	expected := new(Nat).Exp(&x, &y, &m)
+	actual := new(Nat).ExpLadder(&x, &y, &m)
+	if !actual.checkInvariants() {
+		return false
+	}
+	return expected.Eq(actual) == 1
+}
+
+func TestExpLadderMatchesExp(t *testing.T) {
+	err := quick.Check(testExpLadderMatchesExp, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func testExpEvenMatchesBig(x Nat, y Nat, m Modulus) bool {
+	// Force the modulus to be even, so that we don't just test the odd case half the time
+	mBig := new(big.Int).Lsh(m.Big(), 1)
+	mEven := ModulusFromNat(new(Nat).SetBig(mBig, mBig.BitLen()))
+	expected := new(big.Int).Exp(x.Big(), y.Big(), mBig)
+	// Reusing an output with a previous value should also work
+	actual := new(Nat).SetNat(&x)
+	actual.Exp(&x, &y, mEven)
+	if !actual.checkInvariants() {
+		return false
+	}
+	return actual.Big().Cmp(expected) == 0
+}
+
+func TestExpEvenMatchesBig(t *testing.T) {
+	err := quick.Check(testExpEvenMatchesBig, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestExpEvenExamples(t *testing.T) {
+	m := ModulusFromUint64(1 << 10)
+	x := new(Nat).SetUint64(3)
+	y := new(Nat).SetUint64(0)
+	expected := new(Nat).SetUint64(1)
+	actual := new(Nat).Exp(x, y, m)
+	if expected.Eq(actual) != 1 {
+		t.Errorf("%+v != %+v", expected, actual)
 	}
-	x.SetUint64(999)
 	m = ModulusFromUint64(1000)
-	x.ModInverse(&x, m)
-	z.SetUint64(999)
-	if x.Eq(&z) != 1 {
-		t.Errorf("%+v != %+v", x, z)
+	y = new(Nat).SetUint64(7)
+	expected = new(Nat).SetUint64(187)
+	actual = new(Nat).Exp(x, y, m)
+	if expected.Eq(actual) != 1 {
+		t.Errorf("%+v != %+v", expected, actual)
+	}
+	// The exponent aliasing the output
+	actual = new(Nat).SetUint64(7)
+	actual.Exp(x, actual, m)
+	if expected.Eq(actual) != 1 {
+		t.Errorf("%+v != %+v", expected, actual)
+	}
+}
+
+func testExpWithTableMatchesExp(x Nat, y Nat, z Nat, m Modulus) bool {
+	expected := new(Nat).Exp(&x, &y, &m)
+	expectedZ := new(Nat).Exp(&x, &z, &m)
+	for _, windowBits := range []int{1, 3, 4, 5, 8} {
+		table := m.PrecomputePowers(&x, windowBits)
+		// The same table should work for several exponents
+		actual := new(Nat).ExpWithTable(table, &y)
+		actualZ := new(Nat).ExpWithTable(table, &z)
+		if !(actual.checkInvariants() && actualZ.checkInvariants()) {
+			return false
+		}
+		if actual.Eq(expected) != 1 || actualZ.Eq(expectedZ) != 1 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestExpWithTableMatchesExp(t *testing.T) {
+	err := quick.Check(testExpWithTableMatchesExp, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestExpWithTableExamples(t *testing.T) {
+	for _, m := range []*Modulus{ModulusFromUint64(1000), ModulusFromUint64(1009)} {
+		table := m.PrecomputePowers(new(Nat).SetUint64(3), 3)
+		for _, y := range []uint64{0, 1, 7, 1 << 40} {
+			expected := new(Nat).SetBig(new(big.Int).Exp(big.NewInt(3), new(big.Int).SetUint64(y), m.Big()), m.BitLen())
+			// The exponent aliasing the output
+			actual := new(Nat).SetUint64(y)
+			actual.ExpWithTable(table, actual)
+			if expected.Eq(actual) != 1 {
+				t.Errorf("%+v != %+v", expected, actual)
+			}
+		}
+	}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("expected a window of 9 bits to panic")
+			}
+		}()
+		ModulusFromUint64(13).PrecomputePowers(new(Nat).SetUint64(2), 9)
+	}()
+}
+
+func testExpAndInverse(x Nat, y Nat, m Modulus) bool {
+	if x.IsUnit(&m) != 1 {
+		return true
+	}
+	expected := new(Nat).Exp(&x, &y, &m)
+	z, inv := new(Nat).ExpAndInverse(new(Nat), &x, &y, &m)
+	if !z.checkInvariants() || !inv.checkInvariants() || z.Eq(expected) != 1 {
+		return false
+	}
+	product := new(Nat).ModMul(z, inv, &m)
+	// Modulo 1, everything is 0
+	return product.Eq(new(Nat).Mod(new(Nat).SetUint64(1), &m)) == 1
+}
+
+func TestExpAndInverse(t *testing.T) {
+	err := quick.Check(testExpAndInverse, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestExpAndInverseExamples(t *testing.T) {
+	for _, mU64 := range []uint64{13, 16, 35} {
+		m := ModulusFromUint64(mU64)
+		// 3^5 = 243, and its inverse, modulo each of these moduli
+		x := new(Nat).SetUint64(3)
+		y := new(Nat).SetUint64(5)
+		expected := new(Nat).SetUint64(243 % mU64)
+		expectedInv := new(Nat).ModInverse(expected, m)
+		// inv can alias x
+		z, inv := new(Nat).ExpAndInverse(x, x, y, m)
+		if z.Eq(expected) != 1 {
+			t.Errorf("%+v != %+v", expected, z)
+		}
+		if inv.Eq(expectedInv) != 1 {
+			t.Errorf("%+v != %+v", expectedInv, inv)
+		}
+	}
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected z aliasing inv to panic")
+		}
+	}()
+	z := new(Nat)
+	z.ExpAndInverse(z, new(Nat).SetUint64(3), new(Nat).SetUint64(5), ModulusFromUint64(13))
+}
+
+func testExpZeroExponent(x Nat, m Modulus) bool {
+	expected := new(Nat).Mod(new(Nat).SetUint64(1), &m)
+	for _, y := range []*Nat{new(Nat), new(Nat).Resize(300)} {
+		actual := new(Nat).Exp(&x, y, &m)
+		if !actual.checkInvariants() || actual.Eq(expected) != 1 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestExpZeroExponent(t *testing.T) {
+	err := quick.Check(testExpZeroExponent, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestExpEdgeCaseExamples(t *testing.T) {
+	huge := new(Nat).SetBytes(ones())
+	for _, m := range []*Modulus{
+		ModulusFromUint64(1),
+		ModulusFromUint64(2),
+		ModulusFromUint64(13),
+		ModulusFromUint64(1 << 10),
+		ModulusFromBytes(modulus2048()),
+	} {
+		mMinusOne := new(Nat).Sub(&m.nat, new(Nat).SetUint64(1), m.BitLen())
+		for _, x := range []*Nat{new(Nat), new(Nat).SetUint64(1), mMinusOne} {
+			for _, y := range []*Nat{new(Nat), new(Nat).SetUint64(0), new(Nat).SetUint64(1), huge} {
+				expected := new(big.Int).Exp(x.Big(), y.Big(), m.Big())
+				results := []*Nat{
+					new(Nat).Exp(x, y, m),
+					new(Nat).ExpLadder(x, y, m),
+					new(Nat).ExpSecretExponent(x, y, m),
+					new(Nat).ExpWithTable(m.PrecomputePowers(x, 4), y),
+				}
+				for _, actual := range results {
+					if actual.Big().Cmp(expected) != 0 {
+						t.Errorf("%v^%v mod %v: %+v != %+v", x, y, m, expected, actual)
+					}
+				}
+			}
+		}
+	}
+}
+
+func testExpPaddedExponentMatchesBig(x Nat, y uint16, m Modulus, padding uint16) bool {
+	yNat := new(Nat).SetUint64(uint64(y)).Resize(64 + int(padding%4096))
+	expected := new(big.Int).Exp(x.Big(), big.NewInt(int64(y)), m.Big())
+	actual := new(Nat).Exp(&x, yNat, &m)
+	if !actual.checkInvariants() {
+		return false
+	}
+	return actual.Big().Cmp(expected) == 0
+}
+
+func TestExpPaddedExponentMatchesBig(t *testing.T) {
+	err := quick.Check(testExpPaddedExponentMatchesBig, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestExpPaddedExponentExamples(t *testing.T) {
+	for _, m := range []*Modulus{ModulusFromBytes(modulus2048()), ModulusFromBytes(modulus2048Even())} {
+		x := new(Nat).SetUint64(3)
+		expected := new(Nat).Exp(x, new(Nat).SetUint64(65537), m)
+		// Exponents with many leading zero limbs, and widths which aren't a multiple of the limb size
+		for _, bits := range []int{17, 64, 65, 1000, 4096} {
+			y := new(Nat).SetUint64(65537).Resize(bits)
+			actual := new(Nat).Exp(x, y, m)
+			if expected.Eq(actual) != 1 {
+				t.Errorf("%d bits: %+v != %+v", bits, expected, actual)
+			}
+		}
+	}
+}
+
+func testExpLadderMatchesBig(x Nat, y Nat, m Modulus) bool {
+	expected := new(big.Int).Exp(x.Big(), y.Big(), m.Big())
+	actual := new(Nat).ExpLadder(&x, &y, &m)
+	if !actual.checkInvariants() {
+		return false
+	}
+	return actual.Big().Cmp(expected) == 0
+}
+
+func TestExpLadderMatchesBig(t *testing.T) {
+	err := quick.Check(testExpLadderMatchesBig, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestExpLadderExamples(t *testing.T) {
+	for _, m := range []*Modulus{ModulusFromUint64(13), ModulusFromUint64(10), ModulusFromBytes(modulus2048Even())} {
+		x := new(Nat).SetUint64(3)
+		y := new(Nat).SetUint64(5)
+		// 3^5 = 243
+		expected := new(Nat).SetUint64(243)
+		expected.Mod(expected, m)
+		actual := x.ExpLadder(x, y, m)
+		if expected.Eq(actual) != 1 {
+			t.Errorf("%+v != %+v", expected, actual)
+		}
+		// A large exponent, checked against big.Int
+		huge := new(Nat).SetBytes(ones())
+		expectedBig := new(big.Int).Exp(big.NewInt(3), huge.Big(), m.Big())
+		actual = new(Nat).ExpLadder(new(Nat).SetUint64(3), huge, m)
+		if actual.Big().Cmp(expectedBig) != 0 {
+			t.Errorf("%+v != %+v", expectedBig, actual)
+		}
+		expected.SetUint64(1)
+		actual.ExpLadder(actual, new(Nat), m)
+		if expected.Eq(actual) != 1 {
+			t.Errorf("%+v != %+v", expected, actual)
+		}
+	}
+}
+
+func testExpFixedWidthMatchesExp(x Nat, y Nat, m Modulus) bool {
+	expected := new(Nat).Exp(&x, &y, &m)
+	for _, bits := range []int{y.TrueLen(), y.AnnouncedLen(), y.AnnouncedLen() + 200} {
+		actual := new(Nat).ExpFixedWidth(&x, &y, &m, bits)
+		if !actual.checkInvariants() {
+			return false
+		}
+		if expected.Eq(actual) != 1 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestExpFixedWidthMatchesExp(t *testing.T) {
+	err := quick.Check(testExpFixedWidthMatchesExp, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestExpFixedWidthExamples(t *testing.T) {
+	for _, m := range []*Modulus{ModulusFromUint64(1000003), ModulusFromUint64(1 << 20), ModulusFromBytes(modulus2048Even())} {
+		x := new(Nat).SetUint64(2)
+		// 0x13 needs exactly 5 bits
+		y := new(Nat).SetUint64(0x13)
+		expected := new(Nat).SetBig(new(big.Int).Exp(big.NewInt(2), big.NewInt(0x13), m.Big()), m.BitLen())
+		for _, bits := range []int{5, 64, 300} {
+			actual := new(Nat).ExpFixedWidth(x, y, m, bits)
+			if expected.Eq(actual) != 1 {
+				t.Errorf("%+v != %+v", expected, actual)
+			}
+		}
+	}
+	// An exponent that doesn't fit isn't silently truncated
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected an exponent larger than exponentBits to panic")
+		}
+	}()
+	new(Nat).ExpFixedWidth(new(Nat).SetUint64(2), new(Nat).SetUint64(0x13), ModulusFromUint64(1000003), 4)
+}
+
+func testExpSecretExponentMatchesExp(x Nat, y Nat, m Modulus) bool {
+	// Also check the bases with a special case
+	for _, base := range []*Nat{&x, new(Nat), new(Nat).SetUint64(1), new(Nat).Add(&m.nat, new(Nat).SetUint64(1), -1)} {
+		expected := new(Nat).Exp(base, &y, &m)
+		actual := new(Nat).ExpSecretExponent(base, &y, &m)
+		if !actual.checkInvariants() {
+			return false
+		}
+		if expected.Eq(actual) != 1 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestExpSecretExponentMatchesExp(t *testing.T) {
+	err := quick.Check(testExpSecretExponentMatchesExp, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestExpSecretExponentExamples(t *testing.T) {
+	m := ModulusFromUint64(13)
+	zero := new(Nat)
+	actual := new(Nat).ExpSecretExponent(zero, zero, m)
+	expected := new(Nat).SetUint64(1)
+	if expected.Eq(actual) != 1 {
+		t.Errorf("%+v != %+v", expected, actual)
+	}
+	actual.ExpSecretExponent(zero, new(Nat).SetUint64(5), m)
+	if actual.EqZero() != 1 {
+		t.Errorf("%+v != %+v", zero, actual)
+	}
+	actual.ExpSecretExponent(new(Nat).SetUint64(2), new(Nat).SetUint64(5), m)
+	expected.SetUint64(6)
+	if expected.Eq(actual) != 1 {
+		t.Errorf("%+v != %+v", expected, actual)
+	}
+}
+
+func testExpReducedMatchesExp(x Nat, y Nat) bool {
+	for _, p := range []uint64{13, 65537, (1 << 61) - 1} {
+		m := ModulusFromUint64(p)
+		if x.IsUnit(m) != 1 {
+			continue
+		}
+		// By Fermat's little theorem, x^(p - 1) = 1 mod p
+		order := ModulusFromUint64(p - 1)
+		expected := new(Nat).Exp(&x, &y, m)
+		actual := new(Nat).ExpReduced(&x, &y, m, order)
+		if !actual.checkInvariants() {
+			return false
+		}
+		if expected.Eq(actual) != 1 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestExpReducedMatchesExp(t *testing.T) {
+	err := quick.Check(testExpReducedMatchesExp, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestExpReducedExamples(t *testing.T) {
+	m := ModulusFromUint64(13)
+	// 5 has order 4 modulo 13
+	order := ModulusFromUint64(4)
+	x := new(Nat).SetUint64(5)
+	y, _ := new(Nat).SetHex("FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFF")
+	expected := new(Nat).Exp(x, y, m)
+	actual := new(Nat).ExpReduced(x, y, m, order)
+	if expected.Eq(actual) != 1 {
+		t.Errorf("%+v != %+v", expected, actual)
+	}
+}
+
+func testSqrtRoundTrip(x *Nat, p *Modulus) bool {
+	xSquared := x.ModMul(x, x, p)
+	xRoot := new(Nat).ModSqrt(xSquared, p)
+	if !(xRoot.checkInvariants() && xSquared.checkInvariants()) {
+		return false
+	}
+	xRoot.ModMul(xRoot, xRoot, p)
+	if !xRoot.checkInvariants() {
+		return false
+	}
+	return xRoot.Eq(xSquared) == 1
+}
+
+func testModSqrt(x Nat) bool {
+	if !x.checkInvariants() {
+		return false
+	}
+	p := ModulusFromBytes([]byte{
+		13,
+	})
+	if !testSqrtRoundTrip(&x, p) {
+		return false
+	}
+	p = ModulusFromUint64((1 << 61) - 1)
+	if !testSqrtRoundTrip(&x, p) {
+		return false
+	}
+	p = ModulusFromBytes([]byte{
+		0x1, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+	})
+	if !testSqrtRoundTrip(&x, p) {
+		return false
+	}
+	p = ModulusFromBytes([]byte{
+		0x3,
+		0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+		0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xfb,
+	})
+	if !testSqrtRoundTrip(&x, p) {
+		return false
+	}
+	// 2^255 - 19, which is 5 mod 8
+	p, _ = ModulusFromHex("7FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFED")
+	if !testSqrtRoundTrip(&x, p) {
+		return false
+	}
+	// 2^224 - 2^96 + 1
+	p = ModulusFromBytes([]byte{
+		0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+		00, 00, 00, 00, 00, 00, 00, 00, 00, 00, 00, 1,
+	})
+	return testSqrtRoundTrip(&x, p)
+}
+
+func TestModSqrt(t *testing.T) {
+	err := quick.Check(testModSqrt, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func testModSqrtBoth(x Nat) bool {
+	for _, p := range []*Modulus{ModulusFromUint64(13), ModulusFromUint64((1 << 61) - 1)} {
+		xSquared := new(Nat).ModMul(&x, &x, p)
+		r1, r2, ok := new(Nat).ModSqrtBoth(xSquared, p)
+		if !ok || !(r1.checkInvariants() && r2.checkInvariants()) {
+			return false
+		}
+		if new(Nat).ModAdd(r1, r2, p).EqZero() != 1 {
+			return false
+		}
+		for _, r := range []*Nat{r1, r2} {
+			if new(Nat).ModMul(r, r, p).Eq(xSquared) != 1 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func TestModSqrtBoth(t *testing.T) {
+	err := quick.Check(testModSqrtBoth, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestModSqrtBothExamples(t *testing.T) {
+	p := ModulusFromUint64(13)
+	r1, r2, ok := new(Nat).ModSqrtBoth(new(Nat).SetUint64(0), p)
+	if !ok || r1.EqZero() != 1 || r2.EqZero() != 1 {
+		t.Errorf("expected both roots of 0 to be 0")
+	}
+	// 2 is not a square modulo 13
+	_, _, ok = new(Nat).ModSqrtBoth(new(Nat).SetUint64(2), p)
+	if ok {
+		t.Errorf("expected 2 to have no square root mod 13")
+	}
+	r1, r2, ok = new(Nat).ModSqrtBoth(new(Nat).SetUint64(4), p)
+	expected1 := new(Nat).SetUint64(2)
+	expected2 := new(Nat).SetUint64(11)
+	if !ok || r1.Eq(r2) == 1 || (r1.Eq(expected1)|r1.Eq(expected2)) != 1 || (r2.Eq(expected1)|r2.Eq(expected2)) != 1 {
+		t.Errorf("expected roots of 4 to be 2 and 11, got %+v and %+v", r1, r2)
+	}
+}
+
+func testModSqrtCRT(x Nat) bool {
+	factors := []*Modulus{
+		ModulusFromUint64(7),
+		ModulusFromUint64(13),
+		ModulusFromUint64((1 << 61) - 1),
+	}
+	nBig := big.NewInt(1)
+	for _, p := range factors {
+		nBig.Mul(nBig, p.Big())
+	}
+	n := ModulusFromNat(new(Nat).SetBig(nBig, nBig.BitLen()))
+	xSquared := new(Nat).ModMul(&x, &x, n)
+	roots, ok := ModSqrtCRT(xSquared, factors)
+	if !ok || len(roots) != 1<<len(factors) {
+		return false
+	}
+	for _, r := range roots {
+		if !r.checkInvariants() {
+			return false
+		}
+		rBig := r.Big()
+		if rBig.Cmp(nBig) >= 0 {
+			return false
+		}
+		squared := new(big.Int).Mul(rBig, rBig)
+		if squared.Mod(squared, nBig).Cmp(xSquared.Big()) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestModSqrtCRT(t *testing.T) {
+	err := quick.Check(testModSqrtCRT, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestModSqrtCRTExamples(t *testing.T) {
+	// Rabin decryption with n = 7 * 11 = 77, with 4 distinct roots of 15
+	factors := []*Modulus{ModulusFromUint64(7), ModulusFromUint64(11)}
+	roots, ok := ModSqrtCRT(new(Nat).SetUint64(15), factors)
+	if !ok {
+		t.Errorf("expected 15 to have a square root mod 77")
+	}
+	found := make(map[uint64]bool)
+	for _, r := range roots {
+		found[r.Uint64()] = true
+	}
+	for _, expected := range []uint64{13, 20, 57, 64} {
+		if !found[expected] {
+			t.Errorf("expected %d to be a root of 15 mod 77, got %+v", expected, roots)
+		}
+	}
+	// 3 is a square mod 11, but not mod 7
+	_, ok = ModSqrtCRT(new(Nat).SetUint64(3), factors)
+	if ok {
+		t.Errorf("expected 3 to have no square root mod 77")
+	}
+	// A single factor works like ModSqrtBoth
+	roots, ok = ModSqrtCRT(new(Nat).SetUint64(4), []*Modulus{ModulusFromUint64(13)})
+	if !ok || len(roots) != 2 || new(Nat).ModAdd(roots[0], roots[1], ModulusFromUint64(13)).EqZero() != 1 {
+		t.Errorf("expected the roots of 4 mod 13 to be negations, got %+v", roots)
+	}
+}
+
+func TestModSqrtCRTDoesNotMutateFactors(t *testing.T) {
+	factors := []*Modulus{ModulusFromUint64(7), ModulusFromUint64(11), ModulusFromUint64(13)}
+	limbs := make([][]Word, len(factors))
+	for i, p := range factors {
+		limbs[i] = p.nat.limbs
+	}
+	ModSqrtCRT(new(Nat).SetUint64(4), factors)
+	for i, p := range factors {
+		// The limbs shouldn't have been touched, or even reallocated
+		if len(p.nat.limbs) != len(limbs[i]) || cap(p.nat.limbs) != cap(limbs[i]) || &p.nat.limbs[0] != &limbs[i][0] {
+			t.Errorf("the limbs of factor %d were reallocated", i)
+		}
+		if !p.nat.checkInvariants() {
+			t.Errorf("invariants don't hold for factor %d", i)
+		}
+	}
+}
+
+func TestQuadraticNonResidueExamples(t *testing.T) {
+	primes := []*Modulus{
+		ModulusFromUint64(3),
+		ModulusFromUint64(13),
+		ModulusFromUint64(17),
+		ModulusFromUint64(41),
+		ModulusFromUint64((1 << 61) - 1),
+		// 2^224 - 2^96 + 1
+		ModulusFromBytes([]byte{
+			0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+			00, 00, 00, 00, 00, 00, 00, 00, 00, 00, 00, 1,
+		}),
+	}
+	for _, p := range primes {
+		n := p.QuadraticNonResidue()
+		if n == nil {
+			t.Errorf("no non-residue found for %v", p)
+			continue
+		}
+		if !n.checkInvariants() {
+			t.Errorf("invariants don't hold for %v", n)
+		}
+		if big.Jacobi(n.Big(), p.Big()) != -1 {
+			t.Errorf("%v is not a non-residue mod %v", n, p)
+		}
+		// The cached value should be the same, and mutating it shouldn't matter
+		n.SetUint64(0)
+		again := p.QuadraticNonResidue()
+		if big.Jacobi(again.Big(), p.Big()) != -1 {
+			t.Errorf("%v is not a non-residue mod %v", again, p)
+		}
+	}
+	// 17 = 1 mod 16, the smallest non-residue is 3
+	expected := new(Nat).SetUint64(3)
+	actual := ModulusFromUint64(17).QuadraticNonResidue()
+	if expected.Eq(actual) != 1 {
+		t.Errorf("%+v != %+v", expected, actual)
+	}
+	// A missing non-residue is cached too, and calling this again still works
+	two := ModulusFromUint64(2)
+	for i := 0; i < 2; i++ {
+		if two.QuadraticNonResidue() != nil {
+			t.Errorf("expected no non-residue mod 2")
+		}
+	}
+	// The search is bounded, even for large composite moduli
+	composite := ModulusFromUint64(0xFFFF_FFFF_FFFF_FFFF)
+	for i := 0; i < 2; i++ {
+		n := composite.QuadraticNonResidue()
+		if n != nil && n.Big().Cmp(big.NewInt(64*64)) >= 0 {
+			t.Errorf("expected the search to stop below 64^2, found %v", n)
+		}
+	}
+}
+
+func TestTotientFromFactorsExamples(t *testing.T) {
+	for n := int64(1); n < 500; n++ {
+		// Find the factorization of n, by trial division
+		var factors []*Modulus
+		remaining := n
+		for p := int64(2); p <= remaining; p++ {
+			for remaining%p == 0 {
+				factors = append(factors, ModulusFromUint64(uint64(p)))
+				remaining /= p
+			}
+		}
+		// Count the units modulo n directly
+		expected := int64(0)
+		gcd := new(big.Int)
+		for k := int64(1); k <= n; k++ {
+			if gcd.GCD(nil, nil, big.NewInt(k), big.NewInt(n)).Cmp(big.NewInt(1)) == 0 {
+				expected++
+			}
+		}
+		actual := TotientFromFactors(factors)
+		if !actual.checkInvariants() {
+			t.Errorf("invariants broken for %d", n)
+		}
+		if actual.Big().Cmp(big.NewInt(expected)) != 0 {
+			t.Errorf("phi(%d): %+v != %+v", n, expected, actual.Big())
+		}
+		if len(factors) == 1 {
+			prime := factors[0].TotientPrime()
+			if prime.Eq(actual) != 1 {
+				t.Errorf("phi(%d): %+v != %+v", n, prime, actual)
+			}
+		}
+	}
+}
+
+func TestTotientFromFactorsDoesNotMutateFactors(t *testing.T) {
+	// Repeated factors get multiplied in directly, and the product outgrows a single limb
+	p := ModulusFromUint64((1 << 61) - 1)
+	q := ModulusFromUint64(11)
+	factors := []*Modulus{p, p, q, q}
+	pLimbs := p.nat.limbs
+	qLimbs := q.nat.limbs
+	TotientFromFactors(factors)
+	// The limbs shouldn't have been touched, or even reallocated
+	if len(p.nat.limbs) != len(pLimbs) || cap(p.nat.limbs) != cap(pLimbs) || &p.nat.limbs[0] != &pLimbs[0] {
+		t.Errorf("the limbs of %v were reallocated", p)
+	}
+	if len(q.nat.limbs) != len(qLimbs) || cap(q.nat.limbs) != cap(qLimbs) || &q.nat.limbs[0] != &qLimbs[0] {
+		t.Errorf("the limbs of %v were reallocated", q)
+	}
+	if !p.nat.checkInvariants() || !q.nat.checkInvariants() {
+		t.Errorf("invariants don't hold for the factors")
+	}
+}
+
+func testMultiplyThenDivide(x Nat, m Modulus) bool {
+
+	if !x.checkInvariants() {
+		return false
+	}
+	mNat := &m.nat
+
+	xm := new(Nat).Mul(&x, mNat, x.AnnouncedLen()+mNat.AnnouncedLen())
+	divided := new(Nat).Div(xm, &m, x.AnnouncedLen())
+	if divided.Eq(&x) != 1 {
+		return false
+	}
+	// Adding m - 1 shouldn't change the result either
+	xm.Add(xm, new(Nat).Sub(mNat, new(Nat).SetUint64(1), xm.AnnouncedLen()), xm.AnnouncedLen())
+	divided = new(Nat).Div(xm, &m, x.AnnouncedLen())
+	if !(divided.checkInvariants() && xm.checkInvariants()) {
+		return false
+	}
+	return divided.Eq(&x) == 1
+}
+
+func TestMultiplyThenDivide(t *testing.T) {
+	err := quick.Check(testMultiplyThenDivide, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestUint64Creation(t *testing.T) {
+	var x, y Nat
+	x.SetUint64(0)
+	y.SetUint64(0)
+	if x.Eq(&y) != 1 {
+		t.Errorf("%+v != %+v", x, y)
+	}
+	x.SetUint64(1)
+	if x.Eq(&y) == 1 {
+		t.Errorf("%+v == %+v", x, y)
+	}
+	x.SetUint64(0x1111)
+	y.SetUint64(0x1111)
+	if x.Eq(&y) != 1 {
+		t.Errorf("%+v != %+v", x, y)
+	}
+}
+
+func TestAddExamples(t *testing.T) {
+	var x, y, z Nat
+	x.SetUint64(100)
+	y.SetUint64(100)
+	z.SetUint64(200)
+	x = *x.Add(&x, &y, 8)
+	if x.Eq(&z) != 1 {
+		t.Errorf("%+v != %+v", x, z)
+	}
+	z.SetUint64(300 - 256)
+	x = *x.Add(&x, &y, 8)
+	if x.Eq(&z) != 1 {
+		t.Errorf("%+v != %+v", x, z)
+	}
+	x.SetUint64(0xf3e5487232169930)
+	y.SetUint64(0)
+	z.SetUint64(0xf3e5487232169930)
+	var x2 Nat
+	x2.Add(&x, &y, 128)
+	if x2.Eq(&z) != 1 {
+		t.Errorf("%+v != %+v", x, z)
+	}
+}
+
+func TestSubExamples(t *testing.T) {
+	x := new(Nat).SetUint64(100)
+	y := new(Nat).SetUint64(200)
+	y.Sub(y, x, 8)
+	if y.Eq(x) != 1 {
+		t.Errorf("%+v != %+v", y, x)
+	}
+}
+
+func TestMulExamples(t *testing.T) {
+	var x, y, z Nat
+	x.SetUint64(10)
+	y.SetUint64(10)
+	z.SetUint64(100)
+	x = *x.Mul(&x, &y, 8)
+	if x.Eq(&z) != 1 {
+		t.Errorf("%+v != %+v", x, z)
+	}
+	z.SetUint64(232)
+	x = *x.Mul(&x, &y, 8)
+	if x.Eq(&z) != 1 {
+		t.Errorf("%+v != %+v", x, z)
+	}
+}
+
+func TestModAddExamples(t *testing.T) {
+	m := ModulusFromUint64(13)
+	var x, y, z Nat
+	x.SetUint64(40)
+	y.SetUint64(40)
+	x = *x.ModAdd(&x, &y, m)
+	z.SetUint64(2)
+	if x.Eq(&z) != 1 {
+		t.Errorf("%+v != %+v", x, z)
+	}
+}
+
+func TestModMulExamples(t *testing.T) {
+	var x, y, z Nat
+	m := ModulusFromUint64(13)
+	x.SetUint64(40)
+	y.SetUint64(40)
+	x = *x.ModMul(&x, &y, m)
+	z.SetUint64(1)
+	if x.Eq(&z) != 1 {
+		t.Errorf("%+v != %+v", x, z)
+	}
+	m = ModulusFromBytes([]byte{1, 0, 0, 0, 0, 0, 0, 0, 1})
+	x.SetUint64(1)
+	x = *x.ModMul(&x, &x, m)
+	z.SetUint64(1)
+	if x.Eq(&z) != 1 {
+		t.Errorf("%+v != %+v", x, z)
+	}
+	m = ModulusFromBytes([]byte{1, 0, 0, 0, 0, 0, 0, 0, 1})
+	x.SetUint64(16390320477281102916)
+	y.SetUint64(13641051446569424315)
+	x = *x.ModMul(&x, &y, m)
+	z.SetUint64(12559215458690093993)
+	if x.Eq(&z) != 1 {
+		t.Errorf("%+v != %+v", x, z)
+	}
+}
+
+func TestModExamples(t *testing.T) {
+	var x, test Nat
+	x.SetUint64(40)
+	m := ModulusFromUint64(13)
+	x.Mod(&x, m)
+	test.SetUint64(1)
+	if x.Eq(&test) != 1 {
+		t.Errorf("%+v != %+v", x, test)
+	}
+	m = ModulusFromBytes([]byte{13, 0, 0, 0, 0, 0, 0, 0, 1})
+	x.SetBytes([]byte{41, 0, 0, 0, 0, 0, 0, 0, 0})
+	x.Mod(&x, m)
+	test.SetBytes([]byte{1, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFD})
+	if x.Eq(&test) != 1 {
+		t.Errorf("%+v != %+v", x, test)
+	}
+}
+
+func TestModInverseExamples(t *testing.T) {
+	x, z := new(Nat), new(Nat)
+	x.SetUint64(2)
+	m := ModulusFromUint64(13)
+	x = x.ModInverse(x, m)
+	z.SetUint64(7)
+	if x.Eq(z) != 1 {
+		t.Errorf("%+v != %+v", x, z)
+	}
+	x.SetUint64(16359684999990746055)
+	m = ModulusFromUint64(7)
+	x = x.ModInverse(x, m)
+	z.SetUint64(3)
+	if x.Eq(z) != 1 {
+		t.Errorf("%+v != %+v", x, z)
+	}
+	x.SetUint64(461423694560)
+	m = ModulusFromUint64(461423694561)
+	z.ModInverse(x, m)
+	if x.Eq(z) != 1 {
+		t.Errorf("%+v != %+v", x, z)
+	}
+	x.SetHex("2AFAE74A613B0764098D86")
+	m, _ = ModulusFromHex("2AFAE74A613B0764098D87")
+	z.ModInverse(x, m)
+	if x.Eq(z) != 1 {
+		t.Errorf("%+v != %+v", x, z)
+	}
+	x.SetHex("930330931B69B44B8E")
+	m, _ = ModulusFromHex("930330931B69B44B8F")
+	z.ModInverse(x, m)
+	if x.Eq(z) != 1 {
+		t.Errorf("%+v != %+v", x, z)
+	}
+	x.SetHex("DDAB4CDD41300C5F9511FE68")
+	m, _ = ModulusFromHex("DDAB4CDD41300C5F9511FE69")
+	z.ModInverse(x, m)
+	if x.Eq(z) != 1 {
+		t.Errorf("%+v != %+v", x, z)
+	}
+	x.SetHex("A200F1C011C86FFF9A")
+	m, _ = ModulusFromHex("A200F1C011C86FFF9B")
+	z.ModInverse(x, m)
+	if x.Eq(z) != 1 {
+		t.Errorf("%+v != %+v", x, z)
+	}
+	x.SetHex("E7B6E7C1CCB2CEDE797F87937E")
+	m, _ = ModulusFromHex("E7B6E7C1CCB2CEDE797F87937F")
+	z.ModInverse(x, m)
+	if x.Eq(z) != 1 {
+		t.Errorf("%+v != %+v", x, z)
+	}
+}
+
+func TestExpExamples(t *testing.T) {
+	var x, y, z Nat
+	x.SetUint64(3)
+	y.SetUint64(345)
+	m := ModulusFromUint64(13)
+	x = *x.Exp(&x, &y, m)
+	z.SetUint64(1)
+	if x.Eq(&z) != 1 {
+		t.Errorf("%+v != %+v", x, z)
+	}
+	m = ModulusFromBytes([]byte{1, 0, 0, 0, 0, 0, 0, 0, 1})
+	x.SetUint64(1)
+	y.SetUint64(2)
+	x = *x.Exp(&x, &y, m)
+	z.SetUint64(1)
+	if x.Eq(&z) != 1 {
+		t.Errorf("%+v != %+v", x, z)
+	}
+}
+
+func TestSetBytesExamples(t *testing.T) {
+	var x, z Nat
+	x.SetBytes([]byte{0x12, 0x34, 0x56})
+	z.SetUint64(0x123456)
+	if x.Eq(&z) != 1 {
+		t.Errorf("%+v != %+v", x, z)
+	}
+	x.SetBytes([]byte{0x00, 0x00, 0xAA, 0xBB, 0xCC, 0xDD, 0xEE, 0xFF})
+	z.SetUint64(0xAABBCCDDEEFF)
+	if x.Eq(&z) != 1 {
+		t.Errorf("%+v != %+v", x, z)
+	}
+}
+
+func TestFillBytesExamples(t *testing.T) {
+	var x Nat
+	expected := []byte{0x00, 0x00, 0x00, 0x00, 0xAA, 0xBB, 0xCC, 0xDD}
+	x.SetBytes(expected)
+	buf := make([]byte, 8)
+	x.FillBytes(buf)
+	if !bytes.Equal(expected, buf) {
+		t.Errorf("%+v != %+v", expected, buf)
+	}
+}
+
+func TestBytesExamples(t *testing.T) {
+	var x Nat
+	expected := []byte{0x11, 0x22, 0x33, 0x44, 0xAA, 0xBB, 0xCC, 0xDD}
+	x.SetBytes(expected)
+	out := x.Bytes()
+	if !bytes.Equal(expected, out) {
+		t.Errorf("%+v != %+v", expected, out)
+	}
+}
+
+func TestByteExample(t *testing.T) {
+	x := new(Nat).SetBytes([]byte{8, 7, 6, 5, 4, 3, 2, 1, 0})
+	for i := 0; i <= 8; i++ {
+		expected := byte(i)
+		actual := x.Byte(i)
+		if expected != actual {
+			t.Errorf("%+v != %+v", expected, actual)
+		}
+	}
+}
+
+func TestModInverseEvenExamples(t *testing.T) {
+	var z, x Nat
+	x.SetUint64(9)
+	m := ModulusFromUint64(10)
+	x.ModInverse(&x, m)
+	z.SetUint64(9)
+	if x.Eq(&z) != 1 {
+		t.Errorf("%+v != %+v", x, z)
+	}
+	x.SetUint64(1)
+	m = ModulusFromUint64(10)
+	x.ModInverse(&x, m)
+	z.SetUint64(1)
+	if x.Eq(&z) != 1 {
+		t.Errorf("%+v != %+v", x, z)
+	}
+	x.SetUint64(19)
+	x.ModInverse(&x, m)
+	z.SetUint64(9)
+	if x.Eq(&z) != 1 {
+		t.Errorf("%+v != %+v", x, z)
+	}
+	x.SetUint64(99)
+	x.ModInverse(&x, m)
+	z.SetUint64(9)
+	if x.Eq(&z) != 1 {
+		t.Errorf("%+v != %+v", x, z)
+	}
+	x.SetUint64(999)
+	m = ModulusFromUint64(1000)
+	x.ModInverse(&x, m)
+	z.SetUint64(999)
+	if x.Eq(&z) != 1 {
+		t.Errorf("%+v != %+v", x, z)
+	}
+	// There's an edge case when the modulus is much larger than the input,
+	// in which case when we do m^-1 mod x, we need to first calculate the remainder
+	// of m.
+	x.SetUint64(3)
+	m = ModulusFromBytes([]byte{1, 0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 0})
+	x.ModInverse(&x, m)
+	z.SetBytes([]byte{0x55, 0x55, 0x55, 0x55, 0x55, 0x55, 0x55, 0x55, 0xAA, 0xAA, 0xAA, 0xAA, 0xAA, 0xAA, 0xAA, 0xAB})
+	if x.Eq(&z) != 1 {
+		t.Errorf("%+v != %+v", x, z)
+	}
+}
+
+func TestModSubExamples(t *testing.T) {
+	m := ModulusFromUint64(13)
+	x := new(Nat).SetUint64(0)
+	y := new(Nat).SetUint64(1)
+	x.ModSub(x, y, m)
+	z := new(Nat).SetUint64(12)
+	if x.Eq(z) != 1 {
+		t.Errorf("%+v != %+v", x, z)
+	}
+}
+
+func testModNegCondMatchesTwoStep(yes bool, a Nat, m Modulus) bool {
+	choice := Choice(0)
+	if yes {
+		choice = 1
+	}
+	expected := new(Nat).Mod(&a, &m)
+	negated := new(Nat).ModNeg(&a, &m)
+	expected.CondAssign(choice, negated)
+	actual := new(Nat).ModNegCond(choice, &a, &m)
+	if !actual.checkInvariants() {
+		return false
+	}
+	// Aliasing should also work
+	aliased := a.Clone()
+	aliased.ModNegCond(choice, aliased, &m)
+	return expected.Eq(actual) == 1 && expected.Eq(aliased) == 1
+}
+
+func TestModNegCondMatchesTwoStep(t *testing.T) {
+	err := quick.Check(testModNegCondMatchesTwoStep, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func testRSquaredMatchesMontgomeryRepresentation(x Nat, m Modulus) bool {
+	if m.even {
+		return true
+	}
+	size := len(m.nat.limbs)
+	r2 := m.RSquared()
+	if !r2.checkInvariants() {
+		return false
+	}
+	rBig := new(big.Int).Lsh(big.NewInt(1), uint(_W*size))
+	expectedR2 := new(big.Int).Mul(rBig, rBig)
+	expectedR2.Mod(expectedR2, m.Big())
+	if r2.Big().Cmp(expectedR2) != 0 {
+		return false
+	}
+	// Converting with a single multiplication by R^2 should match shifting in size zero limbs
+	expected := new(Nat).Mod(&x, &m).limbs
+	scratch := make([]Word, size)
+	for i := 0; i < size; i++ {
+		shiftAddIn(expected, scratch, 0, &m)
+	}
+	actual := new(Nat).Mod(&x, &m).limbs
+	montgomeryRepresentation(actual, scratch, &m)
+	return cmpEq(expected, actual) == 1
+}
+
+func TestRSquaredMatchesMontgomeryRepresentation(t *testing.T) {
+	err := quick.Check(testRSquaredMatchesMontgomeryRepresentation, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRSquaredExamples(t *testing.T) {
+	m := ModulusFromUint64(13)
+	// R = 2^_W, so R^2 = 2^128 = 9 mod 13, or 2^64 = 3 mod 13, on 32 bit platforms
+	expected := new(Nat).SetUint64(9)
+	if _W == 32 {
+		expected.SetUint64(3)
+	}
+	actual := m.RSquared()
+	if expected.Eq(actual) != 1 {
+		t.Errorf("%+v != %+v", expected, actual)
+	}
+	// Modifying the result shouldn't affect the cached value
+	actual.SetUint64(0)
+	actual = m.RSquared()
+	if expected.Eq(actual) != 1 {
+		t.Errorf("%+v != %+v", expected, actual)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected a panic for an even modulus")
+		}
+	}()
+	ModulusFromUint64(14).RSquared()
+}
+
+func testPowerOfTwoDivModMatchesBig(m Modulus, twoPower uint16) bool {
+	power := int(twoPower % 4096)
+	q, r := PowerOfTwoDivMod(power, &m)
+	if !(q.checkInvariants() && r.checkInvariants()) {
+		return false
+	}
+	if q.AnnouncedLen() != power+1 || r.AnnouncedLen() != m.BitLen() {
+		return false
+	}
+	x := new(big.Int).Lsh(big.NewInt(1), uint(power))
+	expectedQ, expectedR := new(big.Int).DivMod(x, m.Big(), new(big.Int))
+	return q.Big().Cmp(expectedQ) == 0 && r.Big().Cmp(expectedR) == 0
+}
+
+func TestPowerOfTwoDivModMatchesBig(t *testing.T) {
+	err := quick.Check(testPowerOfTwoDivModMatchesBig, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPowerOfTwoDivModExamples(t *testing.T) {
+	m := ModulusFromUint64(13)
+	// 2^10 = 1024 = 78 * 13 + 10
+	q, r := PowerOfTwoDivMod(10, m)
+	expectedQ := new(Nat).SetUint64(78)
+	expectedR := new(Nat).SetUint64(10)
+	if expectedQ.Eq(q) != 1 {
+		t.Errorf("%+v != %+v", expectedQ, q)
+	}
+	if expectedR.Eq(r) != 1 {
+		t.Errorf("%+v != %+v", expectedR, r)
+	}
+	q, r = PowerOfTwoDivMod(0, m)
+	expectedQ.SetUint64(0)
+	expectedR.SetUint64(1)
+	if expectedQ.Eq(q) != 1 {
+		t.Errorf("%+v != %+v", expectedQ, q)
+	}
+	if expectedR.Eq(r) != 1 {
+		t.Errorf("%+v != %+v", expectedR, r)
+	}
+	m = ModulusFromBytes(modulus2048())
+	q, r = PowerOfTwoDivMod(4096, m)
+	x := new(big.Int).Lsh(big.NewInt(1), 4096)
+	expectedQBig, expectedRBig := new(big.Int).DivMod(x, m.Big(), new(big.Int))
+	if q.Big().Cmp(expectedQBig) != 0 || r.Big().Cmp(expectedRBig) != 0 {
+		t.Errorf("%+v, %+v != %+v, %+v", expectedQBig, expectedRBig, q, r)
+	}
+}
+
+func testModMulEvenCompositeMatchesBig(a Nat, b Nat, k uint16) bool {
+	// Even moduli which aren't powers of two
+	moduli := []*Modulus{
+		ModulusFromUint64(6),
+		ModulusFromUint64(10),
+		ModulusFromUint64(2 * 1009),
+		ModulusFromUint64(uint64(k)*2 + 6),
+		ModulusFromBytes(modulus2048Even()),
+	}
+	for _, m := range moduli {
+		expected := new(big.Int).Mul(a.Big(), b.Big())
+		expected.Mod(expected, m.Big())
+		actual := new(Nat).ModMul(&a, &b, m)
+		if !actual.checkInvariants() || actual.Big().Cmp(expected) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestModMulEvenCompositeMatchesBig(t *testing.T) {
+	err := quick.Check(testModMulEvenCompositeMatchesBig, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestModMulEvenCompositeExamples(t *testing.T) {
+	for _, mU64 := range []uint64{6, 10, 12, 2 * 1009, 2 * ((1 << 61) - 1)} {
+		m := ModulusFromUint64(mU64)
+		mBig := m.Big()
+		mMinusOne := new(big.Int).Sub(mBig, big.NewInt(1))
+		// Operands at the edges of the range, and much larger than the modulus
+		operands := []*big.Int{
+			big.NewInt(0),
+			big.NewInt(1),
+			mMinusOne,
+			mBig,
+			new(big.Int).Lsh(mMinusOne, 200),
+		}
+		for _, a := range operands {
+			for _, b := range operands {
+				expected := new(big.Int).Mul(a, b)
+				expected.Mod(expected, mBig)
+				aNat := new(Nat).SetBig(a, a.BitLen())
+				bNat := new(Nat).SetBig(b, b.BitLen())
+				actual := new(Nat).ModMul(aNat, bNat, m)
+				if actual.Big().Cmp(expected) != 0 {
+					t.Errorf("%v * %v mod %v: %+v != %+v", a, b, mBig, expected, actual)
+				}
+			}
+		}
+	}
+}
+
+func testModAddSubNegMismatchedSizes(a Nat, b Nat, m Modulus, shrink uint8, grow uint16) bool {
+	mBig := m.Big()
+	// Inputs with announced lengths smaller than, equal to, and larger than m's
+	sizes := []int{int(shrink) % (m.BitLen() + 1), m.BitLen(), m.BitLen() + 1 + int(grow%2048)}
+	for _, aSize := range sizes {
+		for _, bSize := range sizes {
+			x := a.Clone().Resize(aSize)
+			y := b.Clone().Resize(bSize)
+			xBig, yBig := x.Big(), y.Big()
+
+			expectedAdd := new(big.Int).Add(xBig, yBig)
+			expectedAdd.Mod(expectedAdd, mBig)
+			expectedSub := new(big.Int).Sub(xBig, yBig)
+			expectedSub.Mod(expectedSub, mBig)
+			expectedNeg := new(big.Int).Neg(xBig)
+			expectedNeg.Mod(expectedNeg, mBig)
+
+			actualAdd := new(Nat).ModAdd(x, y, &m)
+			actualSub := new(Nat).ModSub(x, y, &m)
+			actualNeg := new(Nat).ModNeg(x, &m)
+			for _, actual := range []*Nat{actualAdd, actualSub, actualNeg} {
+				if !actual.checkInvariants() || actual.AnnouncedLen() != m.BitLen() {
+					return false
+				}
+			}
+			if actualAdd.Big().Cmp(expectedAdd) != 0 || actualSub.Big().Cmp(expectedSub) != 0 || actualNeg.Big().Cmp(expectedNeg) != 0 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func TestModAddSubNegMismatchedSizes(t *testing.T) {
+	err := quick.Check(testModAddSubNegMismatchedSizes, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestModAddSubNegMismatchedSizesExamples(t *testing.T) {
+	m := ModulusFromUint64(13)
+	// Values much larger than 2m need more than a single subtraction
+	x := new(Nat).SetUint64(1000)
+	y := new(Nat).SetBig(new(big.Int).Lsh(big.NewInt(1), 300), 301)
+	expected := new(big.Int).Add(x.Big(), y.Big())
+	expected.Mod(expected, m.Big())
+	actual := new(Nat).ModAdd(x, y, m)
+	if actual.Big().Cmp(expected) != 0 {
+		t.Errorf("%+v != %+v", expected, actual)
+	}
+	expected = new(big.Int).Sub(x.Big(), y.Big())
+	expected.Mod(expected, m.Big())
+	actual = new(Nat).ModSub(x, y, m)
+	if actual.Big().Cmp(expected) != 0 {
+		t.Errorf("%+v != %+v", expected, actual)
+	}
+	// Small announced lengths
+	x = new(Nat).SetUint64(1).Resize(1)
+	y = new(Nat).SetUint64(12).Resize(4)
+	expected = big.NewInt(0)
+	actual = new(Nat).ModAdd(x, y, m)
+	if actual.Big().Cmp(expected) != 0 {
+		t.Errorf("%+v != %+v", expected, actual)
+	}
+	expected = big.NewInt(12)
+	actual = new(Nat).ModNeg(x, m)
+	if actual.Big().Cmp(expected) != 0 {
+		t.Errorf("%+v != %+v", expected, actual)
+	}
+}
+
+func testModMulMatchesBig(a Nat, b Nat, m Modulus) bool {
+	actual := new(Nat).ModMul(&a, &b, &m)
+	if !actual.checkInvariants() {
+		return false
+	}
+	expected := new(big.Int).Mul(a.Big(), b.Big())
+	expected.Mod(expected, m.Big())
+	return actual.Big().Cmp(expected) == 0
+}
+
+func TestModMulMatchesBig(t *testing.T) {
+	err := quick.Check(testModMulMatchesBig, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestModMulThresholdExamples(t *testing.T) {
+	// Moduli on both sides of montgomeryModMulMaxBits, which use different reductions
+	for bits := 64; bits <= 2*montgomeryModMulMaxBits; bits += 64 {
+		for _, last := range []byte{0xFD, 0xFE} {
+			bytes := make([]byte, bits/8)
+			for i := 0; i < len(bytes); i++ {
+				bytes[i] = 0xFD
+			}
+			bytes[len(bytes)-1] = last
+			m := ModulusFromBytes(bytes)
+			x := new(Nat).ModNeg(new(Nat).SetUint64(3), m)
+			y := new(Nat).ModNeg(new(Nat).SetUint64(5), m)
+			expected := new(big.Int).Mul(x.Big(), y.Big())
+			expected.Mod(expected, m.Big())
+			actual := new(Nat).ModMul(x, y, m)
+			if actual.Big().Cmp(expected) != 0 {
+				t.Errorf("%+v != %+v", expected, actual.Big())
+			}
+			actual.ModSquare(x, m)
+			expected.Mul(x.Big(), x.Big())
+			expected.Mod(expected, m.Big())
+			if actual.Big().Cmp(expected) != 0 {
+				t.Errorf("%+v != %+v", expected, actual.Big())
+			}
+		}
+	}
+}
+
+// checkSingleLimbModulus compares ModMul, Exp, and ModInverse against big.Int for m
+func checkSingleLimbModulus(x *Nat, y *Nat, m *Modulus) bool {
+	xBig, yBig, mBig := x.Big(), y.Big(), m.Big()
+
+	expected := new(big.Int).Mul(xBig, yBig)
+	expected.Mod(expected, mBig)
+	if new(Nat).ModMul(x, y, m).Big().Cmp(expected) != 0 {
+		return false
+	}
+	expected.Mul(xBig, xBig)
+	expected.Mod(expected, mBig)
+	if new(Nat).ModMul(x, x, m).Big().Cmp(expected) != 0 {
+		return false
+	}
+	expected.Exp(xBig, yBig, mBig)
+	if new(Nat).Exp(x, y, m).Big().Cmp(expected) != 0 {
+		return false
+	}
+	if x.IsUnit(m) == 1 {
+		expected.ModInverse(xBig, mBig)
+		if new(Nat).ModInverse(x, m).Big().Cmp(expected) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func testSingleLimbModulusMatchesBig(x Nat, y Nat, mWord Word) bool {
+	// Make sure the modulus isn't 0, or 1
+	if mWord < 2 {
+		mWord += 2
+	}
+	m := ModulusFromNat(new(Nat).SetUint64(uint64(mWord)))
+	return checkSingleLimbModulus(&x, &y, m)
+}
+
+func TestSingleLimbModulusMatchesBig(t *testing.T) {
+	err := quick.Check(testSingleLimbModulusMatchesBig, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSingleLimbModulusExamples(t *testing.T) {
+	top := Word(1) << (_W - 1)
+	moduli := []Word{
+		3, 13, 16, 255,
+		// Around the top bit of a limb
+		top - 1, top, top + 1, top + 3,
+		// Around the largest value of a limb
+		^Word(0) - 2, ^Word(0) - 1, ^Word(0),
+	}
+	r := rand.New(rand.NewSource(0))
+	for _, mWord := range moduli {
+		m := ModulusFromNat(new(Nat).SetUint64(uint64(mWord)))
+		mMinusOne := new(Nat).SetUint64(uint64(mWord - 1))
+		values := []*Nat{
+			new(Nat).SetUint64(0),
+			new(Nat).SetUint64(1),
+			mMinusOne,
+			new(Nat).SetUint64(r.Uint64()),
+			new(Nat).SetBytes([]byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}),
+		}
+		for _, x := range values {
+			for _, y := range values {
+				if !checkSingleLimbModulus(x, y, m) {
+					t.Errorf("mismatch with x = %+v, y = %+v, m = %+v", x, y, m)
+				}
+			}
+		}
+	}
+}
+
+func testModMulSameOperandMatchesDistinct(a Nat, m Modulus) bool {
+	expected := new(Nat).ModMul(&a, a.Clone(), &m)
+	actual := new(Nat).ModMul(&a, &a, &m)
+	if !actual.checkInvariants() || actual.Eq(expected) != 1 {
+		return false
+	}
+	// Also with an input that's already reduced
+	aModM := new(Nat).Mod(&a, &m)
+	actual.ModMul(aModM, aModM, &m)
+	return actual.Eq(expected) == 1
+}
+
+func TestModMulSameOperandMatchesDistinct(t *testing.T) {
+	err := quick.Check(testModMulSameOperandMatchesDistinct, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func testLazyNatMatchesModAdd(a Nat, b Nat, c Nat, m Modulus) bool {
+	inputs := []*Nat{new(Nat).Mod(&a, &m), new(Nat).Mod(&b, &m), &c, new(Nat).ModNeg(&a, &m)}
+	expected := m.NewZero()
+	lazy := NewLazyNat(&m, len(inputs))
+	for _, x := range inputs {
+		expected.ModAdd(expected, x, &m)
+		lazy.AddReduced(x)
+	}
+	actual := lazy.Reduce()
+	if !actual.checkInvariants() || actual.reduced != &m {
+		return false
+	}
+	return actual.Eq(expected) == 1
+}
+
+func TestLazyNatMatchesModAdd(t *testing.T) {
+	err := quick.Check(testLazyNatMatchesModAdd, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestLazyNatExamples(t *testing.T) {
+	// Many large residues, which overflow the size of the modulus many times
+	m := ModulusFromBytes(modulus2048())
+	mMinusOne := new(Nat).ModNeg(new(Nat).SetUint64(1), m)
+	lazy := NewLazyNat(m, 1000)
+	for i := 0; i < 1000; i++ {
+		lazy.AddReduced(mMinusOne)
+	}
+	// 1000 * (m - 1) = -1000 mod m
+	expected := new(Nat).ModNeg(new(Nat).SetUint64(1000), m)
+	actual := lazy.Reduce()
+	if expected.Eq(actual) != 1 {
+		t.Errorf("%+v != %+v", expected, actual)
+	}
+	// An empty sum is 0
+	if NewLazyNat(m, 0).Reduce().EqZero() != 1 {
+		t.Errorf("expected an empty sum to be 0")
+	}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("expected too many additions to panic")
+			}
+		}()
+		NewLazyNat(m, 1).AddReduced(mMinusOne).AddReduced(mMinusOne)
+	}()
+}
+
+func testModOpsAliasing(a Nat, b Nat, m Modulus) bool {
+	mBig := m.Big()
+	ops := []struct {
+		nat func(z, x, y *Nat) *Nat
+		big func(x, y *big.Int) *big.Int
+	}{
+		{
+			func(z, x, y *Nat) *Nat { return z.ModAdd(x, y, &m) },
+			func(x, y *big.Int) *big.Int { return new(big.Int).Add(x, y) },
+		},
+		{
+			func(z, x, y *Nat) *Nat { return z.ModSub(x, y, &m) },
+			func(x, y *big.Int) *big.Int { return new(big.Int).Sub(x, y) },
+		},
+		{
+			func(z, x, y *Nat) *Nat { return z.ModMul(x, y, &m) },
+			func(x, y *big.Int) *big.Int { return new(big.Int).Mul(x, y) },
+		},
+	}
+	for _, op := range ops {
+		expectedXY := op.big(a.Big(), b.Big())
+		expectedXY.Mod(expectedXY, mBig)
+		expectedXX := op.big(a.Big(), a.Big())
+		expectedXX.Mod(expectedXX, mBig)
+
+		// z == x
+		z := a.Clone()
+		op.nat(z, z, &b)
+		if !z.checkInvariants() || z.Big().Cmp(expectedXY) != 0 {
+			return false
+		}
+		// z == y
+		z = b.Clone()
+		op.nat(z, &a, z)
+		if !z.checkInvariants() || z.Big().Cmp(expectedXY) != 0 {
+			return false
+		}
+		// x == y == z
+		z = a.Clone()
+		op.nat(z, z, z)
+		if !z.checkInvariants() || z.Big().Cmp(expectedXX) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestModOpsAliasing(t *testing.T) {
+	err := quick.Check(testModOpsAliasing, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestModMulAliasingExamples(t *testing.T) {
+	// An odd modulus, an even one, and a power of two, which each take different paths
+	for _, m := range []*Modulus{
+		ModulusFromUint64(13),
+		ModulusFromUint64(12),
+		ModulusFromUint64(16),
+	} {
+		x := new(Nat).SetUint64(30)
+		x.ModMul(x, x, m)
+		expected := new(big.Int).Mod(big.NewInt(900), m.Big())
+		if x.Big().Cmp(expected) != 0 {
+			t.Errorf("%+v != %+v", expected, x)
+		}
+		y := new(Nat).SetUint64(7)
+		x = new(Nat).SetUint64(30)
+		y.ModMul(x, y, m)
+		expected = new(big.Int).Mod(big.NewInt(210), m.Big())
+		if y.Big().Cmp(expected) != 0 {
+			t.Errorf("%+v != %+v", expected, y)
+		}
+	}
+}
+
+// recordingHash implements hash.Hash by remembering everything written to it.
+type recordingHash struct {
+	bytes.Buffer
+}
+
+func (h *recordingHash) Sum(b []byte) []byte {
+	return append(b, h.Bytes()...)
+}
+
+func (h *recordingHash) Size() int {
+	return h.Len()
+}
+
+func (h *recordingHash) BlockSize() int {
+	return 1
+}
+
+func testWriteDigestUnambiguous(a Nat, b Nat, c Nat, d Nat) bool {
+	var h1, h2 recordingHash
+	a.WriteDigest(&h1)
+	b.WriteDigest(&h1)
+	c.WriteDigest(&h2)
+	d.WriteDigest(&h2)
+	sameInput := bytes.Equal(h1.Bytes(), h2.Bytes())
+	samePair := a.AnnouncedLen() == c.AnnouncedLen() && b.AnnouncedLen() == d.AnnouncedLen() && a.Eq(&c) == 1 && b.Eq(&d) == 1
+	return sameInput == samePair
+}
+
+func TestWriteDigestUnambiguous(t *testing.T) {
+	err := quick.Check(testWriteDigestUnambiguous, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestWriteDigestExamples(t *testing.T) {
+	// Splitting the same bytes differently shouldn't produce the same input
+	a := new(Nat).SetBytes([]byte{0x01})
+	b := new(Nat).SetBytes([]byte{0x02, 0x03})
+	c := new(Nat).SetBytes([]byte{0x01, 0x02})
+	d := new(Nat).SetBytes([]byte{0x03})
+	var h1, h2 recordingHash
+	a.WriteDigest(&h1)
+	b.WriteDigest(&h1)
+	c.WriteDigest(&h2)
+	d.WriteDigest(&h2)
+	if bytes.Equal(h1.Bytes(), h2.Bytes()) {
+		t.Errorf("ambiguous encoding %+v", h1.Bytes())
+	}
+	expected := []byte{'N', 0, 0, 0, 1, 0x01, 'N', 0, 0, 0, 2, 0x02, 0x03}
+	if !bytes.Equal(expected, h1.Bytes()) {
+		t.Errorf("%+v != %+v", expected, h1.Bytes())
+	}
+	// A Nat and an Int with the same value are encoded differently
+	var h3 recordingHash
+	new(Int).SetNat(a).WriteDigest(&h3)
+	expected = []byte{'I', 0, 0, 0, 0, 1, 0x01}
+	if !bytes.Equal(expected, h3.Bytes()) {
+		t.Errorf("%+v != %+v", expected, h3.Bytes())
+	}
+}
+
+func testModSmallInputIsUnchanged(a Nat, m Modulus) bool {
+	// Make sure the input is smaller than m, with fewer bits than m
+	x := &a
+	if a.AnnouncedLen() >= m.BitLen() {
+		x = new(Nat).Mod(&a, &m).Resize(m.BitLen() - 1)
+	}
+	actual := new(Nat).Mod(x, &m)
+	if !actual.checkInvariants() || actual.Eq(x) != 1 {
+		return false
+	}
+	if actual.AnnouncedLen() != m.BitLen() || actual.reduced != &m {
+		return false
+	}
+	// The result should be usable as an already reduced input
+	expected := new(Nat).ModMul(x, x, &m)
+	return new(Nat).ModMul(actual, actual, &m).Eq(expected) == 1
+}
+
+func TestModSmallInputIsUnchanged(t *testing.T) {
+	err := quick.Check(testModSmallInputIsUnchanged, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestModSmallInputExamples(t *testing.T) {
+	m := ModulusFromBytes(modulus2048())
+	x := new(Nat).SetUint64(7)
+	actual := new(Nat).Mod(x, m)
+	if actual.Eq(x) != 1 || actual.AnnouncedLen() != m.BitLen() || actual.reduced != m {
+		t.Errorf("unexpected result %+v", actual)
+	}
+	expected := new(Nat).SetUint64(49)
+	actual.ModMul(actual, new(Nat).SetUint64(7), m)
+	if actual.Eq(expected) != 1 {
+		t.Errorf("%+v != %+v", expected, actual)
+	}
+	// Zero, and a zero length input
+	for _, x := range []*Nat{new(Nat), new(Nat).SetUint64(0)} {
+		actual = new(Nat).Mod(x, m)
+		if actual.EqZero() != 1 || actual.AnnouncedLen() != m.BitLen() || !actual.checkInvariants() {
+			t.Errorf("unexpected result %+v", actual)
+		}
+	}
+}
+
+func testModWithForeignReduction(a Nat, m Modulus, other Modulus) bool {
+	// x carries a reduction flag from a different modulus, which we shouldn't trust
+	x := new(Nat).Mod(&a, &other)
+	expected := new(big.Int).Mod(x.Big(), m.Big())
+	actual := new(Nat).Mod(x, &m)
+	if !actual.checkInvariants() {
+		return false
+	}
+	return actual.Big().Cmp(expected) == 0
+}
+
+func TestModWithForeignReduction(t *testing.T) {
+	err := quick.Check(testModWithForeignReduction, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func testDivWithForeignReduction(a Nat, m Modulus, other Modulus) bool {
+	x := new(Nat).Mod(&a, &other)
+	expected := new(big.Int).Div(x.Big(), m.Big())
+	// Both with an explicit capacity, and the default one
+	for _, cap := range []int{x.AnnouncedLen(), -1} {
+		actual := new(Nat).Div(x, &m, cap)
+		if !actual.checkInvariants() {
+			return false
+		}
+		if actual.Big().Cmp(expected) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestDivWithForeignReduction(t *testing.T) {
+	err := quick.Check(testDivWithForeignReduction, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestStaleReductionExamples(t *testing.T) {
+	small := ModulusFromUint64(7)
+	large := ModulusFromBytes([]byte{1, 0, 0, 0, 0, 0, 0, 0, 0, 1})
+
+	// A value much smaller than the modulus, but reduced by a different one
+	x := new(Nat).Mod(new(Nat).SetUint64(100), small)
+	expected := new(Nat).SetUint64(0)
+	actual := new(Nat).Div(x, large, 64)
+	if actual.Eq(expected) != 1 {
+		t.Errorf("%+v != %+v", expected, actual)
+	}
+	actual = new(Nat).Div(x, large, -1)
+	if actual.Eq(expected) != 1 {
+		t.Errorf("%+v != %+v", expected, actual)
+	}
+	expected = new(Nat).SetUint64(2)
+	actual = new(Nat).Mod(x, large)
+	if actual.Eq(expected) != 1 {
+		t.Errorf("%+v != %+v", expected, actual)
+	}
+
+	// Overwriting a reduced value with SetBig should forget the reduction
+	z := new(Nat).Mod(new(Nat).SetUint64(3), small)
+	z.SetBig(big.NewInt(100), 64)
+	expected = new(Nat).SetUint64(2)
+	actual = new(Nat).Mod(z, small)
+	if actual.Eq(expected) != 1 {
+		t.Errorf("%+v != %+v", expected, actual)
+	}
+
+	// Resizing a reduced value shouldn't let it be used as if it had the size of the modulus
+	z = new(Nat).Mod(new(Nat).SetUint64(3), small)
+	z.Resize(256)
+	if !z.checkInvariants() {
+		t.Errorf("invariants don't hold after resizing a reduced value")
+	}
+	expected = new(Nat).SetUint64(6)
+	actual = new(Nat).ModAdd(z, z, small)
+	if !actual.checkInvariants() || actual.Eq(expected) != 1 {
+		t.Errorf("%+v != %+v", expected, actual)
+	}
+}
+
+func testModWideMatchesMod(a Nat, b Nat, m Modulus) bool {
+	aModM := new(Nat).Mod(&a, &m)
+	bModM := new(Nat).Mod(&b, &m)
+	for _, wide := range []*Nat{new(Nat).Mul(aModM, bModM, -1), &a} {
+		expected := new(Nat).Mod(wide, &m)
+		actual := new(Nat).ModWide(wide, &m)
+		if !actual.checkInvariants() {
+			return false
+		}
+		if expected.Eq(actual) != 1 {
+			return false
+		}
 	}
-	// There's an edge case when the modulus is much larger than the input,
-	// in which case when we do m^-1 mod x, we need to first calculate the remainder
-	// of m.
-	x.SetUint64(3)
-	m = ModulusFromBytes([]byte{1, 0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 0})
-	x.ModInverse(&x, m)
-	z.SetBytes([]byte{0x55, 0x55, 0x55, 0x55, 0x55, 0x55, 0x55, 0x55, 0xAA, 0xAA, 0xAA, 0xAA, 0xAA, 0xAA, 0xAA, 0xAB})
-	if x.Eq(&z) != 1 {
-		t.Errorf("%+v != %+v", x, z)
+	return true
+}
+
+func TestModWideMatchesMod(t *testing.T) {
+	err := quick.Check(testModWideMatchesMod, &quick.Config{})
+	if err != nil {
+		t.Error(err)
 	}
 }
 
-func TestModSubExamples(t *testing.T) {
-	m := ModulusFromUint64(13)
-	x := new(Nat).SetUint64(0)
-	y := new(Nat).SetUint64(1)
-	x.ModSub(x, y, m)
-	z := new(Nat).SetUint64(12)
-	if x.Eq(z) != 1 {
-		t.Errorf("%+v != %+v", x, z)
+func TestModWideExamples(t *testing.T) {
+	// Moduli which are exact powers of the limb size are an edge case for Barrett reduction
+	for _, m := range []*Modulus{
+		ModulusFromUint64(1),
+		ModulusFromUint64(2),
+		ModulusFromNat(new(Nat).Lsh(new(Nat).SetUint64(1), _W, -1)),
+		ModulusFromNat(new(Nat).Lsh(new(Nat).SetUint64(1), 2*_W, -1)),
+	} {
+		max := new(Nat).Resize(2 * _W * len(m.nat.limbs))
+		for i := range max.limbs {
+			max.limbs[i] = ^Word(0)
+		}
+		expected := new(Nat).Mod(max, m)
+		actual := new(Nat).ModWide(max, m)
+		if expected.Eq(actual) != 1 {
+			t.Errorf("%+v != %+v", expected, actual)
+		}
 	}
 }
 
@@ -1081,6 +4181,28 @@ func TestModSqrtExamples(t *testing.T) {
 	if x.EqZero() != 1 {
 		t.Errorf("%+v != 0", x)
 	}
+	// 65 = 5 * 13 = 1 mod 8, and has no quadratic non-residue to find
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected a modulus without a non-residue to panic")
+		}
+	}()
+	new(Nat).ModSqrt(new(Nat).SetUint64(4), ModulusFromUint64(65))
+}
+
+func TestModSqrt5Mod8Examples(t *testing.T) {
+	// Every square modulo some small primes which are 5 mod 8
+	for _, prime := range []uint64{5, 13, 29, 37, 53, 61, 101} {
+		p := ModulusFromUint64(prime)
+		for i := uint64(0); i < prime; i++ {
+			x := new(Nat).SetUint64(i * i % prime)
+			root := new(Nat).ModSqrt(x, p)
+			squared := new(Nat).ModMul(root, root, p)
+			if squared.Eq(x) != 1 {
+				t.Errorf("sqrt(%d) mod %d: %+v^2 != %+v", i*i%prime, prime, root, x)
+			}
+		}
+	}
 }
 
 func TestBigExamples(t *testing.T) {
@@ -1098,6 +4220,56 @@ func TestBigExamples(t *testing.T) {
 	}
 }
 
+func testDivDefaultCapMatchesBig(a Nat, m Modulus) bool {
+	expected := new(big.Int).Div(a.Big(), m.Big())
+	actual := new(Nat).Div(&a, &m, -1)
+	if !actual.checkInvariants() {
+		return false
+	}
+	return actual.Big().Cmp(expected) == 0
+}
+
+func TestDivDefaultCapMatchesBig(t *testing.T) {
+	err := quick.Check(testDivDefaultCapMatchesBig, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDivDefaultCapExamples(t *testing.T) {
+	for _, m := range []*Modulus{
+		ModulusFromUint64(13),
+		ModulusFromUint64(1 << 63),
+		ModulusFromUint64((1 << 64) - 1),
+		ModulusFromBytes(modulus2048()),
+	} {
+		mBig := m.Big()
+		for q := int64(0); q <= 2; q++ {
+			for _, r := range []int64{0, 1} {
+				xBig := new(big.Int).Mul(mBig, big.NewInt(q))
+				xBig.Add(xBig, big.NewInt(r))
+				// The same size as the modulus, one bit larger, and much larger
+				for _, bits := range []int{m.BitLen(), m.BitLen() + 1, m.BitLen() + 200} {
+					if xBig.BitLen() > bits {
+						continue
+					}
+					x := new(Nat).SetBig(xBig, bits)
+					actual := new(Nat).Div(x, m, -1)
+					if actual.Big().Cmp(big.NewInt(q)) != 0 {
+						t.Errorf("(%v * %v + %v) / %v: %+v != %+v", q, mBig, r, mBig, q, actual)
+					}
+				}
+			}
+		}
+	}
+	// A value much smaller than the modulus
+	x := new(Nat).SetUint64(5).Resize(3)
+	actual := new(Nat).Div(x, ModulusFromBytes(modulus2048()), -1)
+	if actual.EqZero() != 1 || actual.AnnouncedLen() != 1 {
+		t.Errorf("unexpected quotient %+v", actual)
+	}
+}
+
 func TestDivExamples(t *testing.T) {
 	x := &Nat{announced: 3 * _W, limbs: []Word{0, 64, 64}}
 	n := &Nat{announced: 2 * _W, limbs: []Word{1, 1}}
@@ -1116,6 +4288,165 @@ func TestDivExamples(t *testing.T) {
 	}
 }
 
+func testDivNatMatchesBig(x Nat, d Nat, padding uint8) bool {
+	if d.EqZero() == 1 {
+		return true
+	}
+	// The divisor shouldn't need to be trimmed to its true size
+	d.Resize(d.AnnouncedLen() + int(padding))
+	expectedQ, expectedR := new(big.Int).DivMod(x.Big(), d.Big(), new(big.Int))
+	q, r := new(Nat).DivNat(&x, &d, -1)
+	if !(q.checkInvariants() && r.checkInvariants()) {
+		return false
+	}
+	if r.AnnouncedLen() != d.AnnouncedLen() {
+		return false
+	}
+	return q.Big().Cmp(expectedQ) == 0 && r.Big().Cmp(expectedR) == 0
+}
+
+func TestDivNatMatchesBig(t *testing.T) {
+	err := quick.Check(testDivNatMatchesBig, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDivNatExamples(t *testing.T) {
+	x := &Nat{announced: 3 * _W, limbs: []Word{0, 64, 64}}
+	d := &Nat{announced: 2 * _W, limbs: []Word{1, 1}}
+	expectedQ := &Nat{announced: 2 * _W, limbs: []Word{0, 64}}
+	expectedR := new(Nat).SetUint64(0)
+	actualQ, actualR := new(Nat).DivNat(x, d, 2*_W)
+	if expectedQ.Eq(actualQ) != 1 {
+		t.Errorf("%+v != %+v", expectedQ, actualQ)
+	}
+	if expectedR.Eq(actualR) != 1 {
+		t.Errorf("%+v != %+v", expectedR, actualR)
+	}
+
+	// A small divisor with many padding limbs, and aliasing
+	x, _ = new(Nat).SetHex("BC5B56830516E486DD0C5C76DF5838511BF68ECB4503FDE3A76C")
+	d = new(Nat).SetUint64(0xDF).Resize(4 * _W)
+	expectedQ, _ = new(Nat).SetHex("D83AEF5E2848331DB0D83C3A6690E5F5CB268613D33F212A14")
+	actualQ, actualR = x.DivNat(x, d, -1)
+	if expectedQ.Eq(actualQ) != 1 {
+		t.Errorf("%+v != %+v", expectedQ, actualQ)
+	}
+	expectedR.SetUint64(0)
+	if expectedR.Eq(actualR) != 1 {
+		t.Errorf("%+v != %+v", expectedR, actualR)
+	}
+}
+
+func testModByNatMatchesBig(x Nat, d Nat, padding uint8) bool {
+	if d.EqZero() == 1 {
+		return true
+	}
+	d.Resize(d.AnnouncedLen() + int(padding))
+	expected := new(big.Int).Mod(x.Big(), d.Big())
+	actual := new(Nat).ModByNat(&x, &d)
+	if !actual.checkInvariants() || actual.AnnouncedLen() != d.AnnouncedLen() {
+		return false
+	}
+	return actual.Big().Cmp(expected) == 0
+}
+
+func TestModByNatMatchesBig(t *testing.T) {
+	err := quick.Check(testModByNatMatchesBig, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func testModByNatMatchesMod(x Nat, m Modulus) bool {
+	expected := new(Nat).Mod(&x, &m)
+	actual := new(Nat).ModByNat(&x, &m.nat)
+	return expected.Eq(actual) == 1
+}
+
+func TestModByNatMatchesMod(t *testing.T) {
+	err := quick.Check(testModByNatMatchesMod, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestModByNatExamples(t *testing.T) {
+	x := new(Nat).SetUint64(1000)
+	d := new(Nat).SetUint64(13).Resize(256)
+	// 1000 = 76 * 13 + 12
+	expected := new(Nat).SetUint64(12)
+	actual := x.ModByNat(x, d)
+	if expected.Eq(actual) != 1 {
+		t.Errorf("%+v != %+v", expected, actual)
+	}
+	if actual.AnnouncedLen() != 256 {
+		t.Errorf("%+v != %+v", actual.AnnouncedLen(), 256)
+	}
+}
+
+func testGCDConstantTimeMatchesBig(a Nat, b Nat) bool {
+	actual := new(Nat).GCDConstantTime(&a, &b)
+	if !actual.checkInvariants() || actual.AnnouncedLen() != a.maxAnnounced(&b) {
+		return false
+	}
+	expected := new(big.Int).GCD(nil, nil, a.Big(), b.Big())
+	if actual.Big().Cmp(expected) != 0 {
+		return false
+	}
+	// Also with a common power of two
+	a.Lsh(&a, 5, -1)
+	b.Lsh(&b, 7, -1)
+	actual.GCDConstantTime(&a, &b)
+	expected.GCD(nil, nil, a.Big(), b.Big())
+	return actual.Big().Cmp(expected) == 0
+}
+
+func TestGCDConstantTimeMatchesBig(t *testing.T) {
+	err := quick.Check(testGCDConstantTimeMatchesBig, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGCDConstantTimeExamples(t *testing.T) {
+	examples := []struct {
+		x        uint64
+		y        uint64
+		expected uint64
+	}{
+		{0, 0, 0},
+		{0, 12, 12},
+		{12, 0, 12},
+		{1, 1, 1},
+		{12, 18, 6},
+		{5 * 7 * 13, 3 * 7 * 11, 7},
+		{1 << 40, 1 << 20, 1 << 20},
+		{3 << 10, 9 << 12, 3 << 10},
+		{0xFFFFFFFFFFFFFFFF, 0xFFFFFFFF, 0xFFFFFFFF},
+	}
+	for _, example := range examples {
+		x := new(Nat).SetUint64(example.x)
+		y := new(Nat).SetUint64(example.y)
+		expected := new(Nat).SetUint64(example.expected)
+		actual := new(Nat).GCDConstantTime(x, y)
+		if expected.Eq(actual) != 1 {
+			t.Errorf("gcd(%d, %d): %+v != %+v", example.x, example.y, expected, actual)
+		}
+	}
+	// The result can alias the inputs
+	x := new(Nat).SetUint64(12)
+	x.GCDConstantTime(x, new(Nat).SetUint64(18))
+	if x.Eq(new(Nat).SetUint64(6)) != 1 {
+		t.Errorf("%+v != %+v", 6, x)
+	}
+	// Empty numbers have a gcd of 0
+	if new(Nat).GCDConstantTime(new(Nat), new(Nat)).EqZero() != 1 {
+		t.Errorf("expected gcd(0, 0) to be 0")
+	}
+}
+
 func TestCoprimeExamples(t *testing.T) {
 	x := new(Nat).SetUint64(5 * 7 * 13)
 	y := new(Nat).SetUint64(3 * 7 * 11)
@@ -1165,6 +4496,42 @@ func TestCoprimeExamples(t *testing.T) {
 	}
 }
 
+func testTrimToTrueLen(a Nat) bool {
+	expected := a.Big()
+	trueLen := a.TrueLen()
+	a.TrimToTrueLen()
+	if !a.checkInvariants() || a.AnnouncedLen() != trueLen {
+		return false
+	}
+	return a.Big().Cmp(expected) == 0
+}
+
+func TestTrimToTrueLen(t *testing.T) {
+	err := quick.Check(testTrimToTrueLen, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTrimToTrueLenExamples(t *testing.T) {
+	x := new(Nat).SetUint64(5).Resize(1000)
+	x.TrimToTrueLen()
+	if x.AnnouncedLen() != 3 {
+		t.Errorf("%+v != %+v", 3, x.AnnouncedLen())
+	}
+	// Trimming a reduced value should forget the reduction
+	m := ModulusFromUint64(13)
+	x = new(Nat).Mod(new(Nat).SetUint64(1), m)
+	x.TrimToTrueLen()
+	if !x.checkInvariants() || x.AnnouncedLen() != 1 {
+		t.Errorf("unexpected result %+v", x)
+	}
+	x = new(Nat).SetUint64(0).TrimToTrueLen()
+	if x.AnnouncedLen() != 0 || x.EqZero() != 1 {
+		t.Errorf("unexpected result %+v", x)
+	}
+}
+
 func TestTrueLenExamples(t *testing.T) {
 	x := new(Nat).SetUint64(0x0000_0000_0000_0001)
 	expected := 1
@@ -1180,6 +4547,45 @@ func TestTrueLenExamples(t *testing.T) {
 	}
 }
 
+func testSecretBitLenMatchesTrueLen(x Nat) bool {
+	length := x.SecretBitLen()
+	if !length.checkInvariants() {
+		return false
+	}
+	return length.Eq(new(Nat).SetUint64(uint64(x.TrueLen()))) == 1
+}
+
+func TestSecretBitLenMatchesTrueLen(t *testing.T) {
+	err := quick.Check(testSecretBitLenMatchesTrueLen, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSecretBitLenExamples(t *testing.T) {
+	x := new(Nat).SetUint64(0x0000_0000_0100_0001).Resize(256)
+	expected := new(Nat).SetUint64(25)
+	actual := x.SecretBitLen()
+	if expected.Eq(actual) != 1 {
+		t.Errorf("%+v != %+v", expected, actual)
+	}
+	if actual.AnnouncedLen() != 9 {
+		t.Errorf("%+v != %+v", 9, actual.AnnouncedLen())
+	}
+	x.SetUint64(0).Resize(256)
+	expected.SetUint64(0)
+	actual = x.SecretBitLen()
+	if expected.Eq(actual) != 1 {
+		t.Errorf("%+v != %+v", expected, actual)
+	}
+	x.Lsh(new(Nat).SetUint64(1), 300, 512)
+	expected.SetUint64(301)
+	actual = x.SecretBitLen()
+	if expected.Eq(actual) != 1 {
+		t.Errorf("%+v != %+v", expected, actual)
+	}
+}
+
 func TestTruncateExamples(t *testing.T) {
 	x := new(Nat).SetUint64(0xAABB)
 	x.Resize(16)
@@ -1214,6 +4620,254 @@ func TestHexExamples(t *testing.T) {
 	}
 }
 
+func testHexRoundtrip(x Nat) bool {
+	upper, err := new(Nat).SetHex(x.Hex())
+	if err != nil || upper.Eq(&x) != 1 {
+		return false
+	}
+	lowerHex := x.HexLower()
+	if lowerHex != strings.ToLower(x.Hex()) {
+		return false
+	}
+	lower, err := new(Nat).SetHexFlexible(lowerHex)
+	if err != nil {
+		return false
+	}
+	return lower.Eq(&x) == 1
+}
+
+func TestHexRoundtrip(t *testing.T) {
+	err := quick.Check(testHexRoundtrip, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func testSetHexOddLength(x Nat) bool {
+	// Adding a leading digit gives us an odd length string
+	hex := "F" + x.Hex()
+	actual, err := new(Nat).SetHex(hex)
+	if err != nil || !actual.checkInvariants() || actual.AnnouncedLen() != 4*len(hex) {
+		return false
+	}
+	// The top nibble is the one we added
+	expected := new(Nat).Add(&x, new(Nat).Lsh(new(Nat).SetUint64(0xF), uint(4*len(hex)-4), -1), -1)
+	if actual.Eq(expected) != 1 {
+		return false
+	}
+	// Hex pads this back to an even length
+	roundtrip := actual.Hex()
+	return roundtrip == "0"+hex
+}
+
+func TestSetHexOddLength(t *testing.T) {
+	err := quick.Check(testSetHexOddLength, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSetHexOddLengthExamples(t *testing.T) {
+	examples := []struct {
+		hex       string
+		announced int
+		value     uint64
+		roundtrip string
+	}{
+		{"", 0, 0, ""},
+		{"F", 4, 0xF, "0F"},
+		{"ABC", 12, 0xABC, "0ABC"},
+		{"FFFFF", 20, 0xFFFFF, "0FFFFF"},
+		{"123456789", 36, 0x123456789, "0123456789"},
+	}
+	for _, example := range examples {
+		x, err := new(Nat).SetHex(example.hex)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !x.checkInvariants() {
+			t.Errorf("SetHex(%q) doesn't satisfy invariants", example.hex)
+		}
+		if x.AnnouncedLen() != example.announced {
+			t.Errorf("%+v != %+v", example.announced, x.AnnouncedLen())
+		}
+		expected := new(Nat).SetUint64(example.value)
+		if x.Eq(expected) != 1 {
+			t.Errorf("%+v != %+v", expected, x)
+		}
+		if x.Hex() != example.roundtrip {
+			t.Errorf("%+v != %+v", example.roundtrip, x.Hex())
+		}
+	}
+	// 17 nibbles spills over into a second limb on 64 bit platforms
+	x, _ := new(Nat).SetHex("1FFFFFFFFFFFFFFFF")
+	if !x.checkInvariants() || x.AnnouncedLen() != 68 {
+		t.Errorf("SetHex with 17 digits doesn't satisfy invariants")
+	}
+	expected := new(Nat).Lsh(new(Nat).SetUint64(1), 65, -1)
+	expected.Sub(expected, new(Nat).SetUint64(1), -1)
+	if x.Eq(expected) != 1 {
+		t.Errorf("%+v != %+v", expected, x)
+	}
+}
+
+func TestHexLowerExamples(t *testing.T) {
+	x := new(Nat).SetUint64(0x0123456789ABCDEF)
+	expected := "0123456789abcdef"
+	actual := x.HexLower()
+	if expected != actual {
+		t.Errorf("%+v != %+v", expected, actual)
+	}
+}
+
+func testScanHexMatchesSetHex(x Nat) bool {
+	hex := x.Hex()
+	expected, err := new(Nat).SetHex(hex)
+	if err != nil {
+		return false
+	}
+	actual, err := new(Nat).ScanHex(strings.NewReader(hex))
+	if err != nil {
+		return false
+	}
+	if !actual.checkInvariants() {
+		return false
+	}
+	return actual.AnnouncedLen() == expected.AnnouncedLen() && actual.Eq(expected) == 1
+}
+
+func TestScanHexMatchesSetHex(t *testing.T) {
+	err := quick.Check(testScanHexMatchesSetHex, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestScanHexExamples(t *testing.T) {
+	r := strings.NewReader("123456789ABCDEF0123 rest")
+	x, err := new(Nat).ScanHex(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, _ := new(Nat).SetHex("123456789ABCDEF0123")
+	if expected.Eq(x) != 1 {
+		t.Errorf("%+v != %+v", expected, x)
+	}
+	if x.AnnouncedLen() != 4*19 {
+		t.Errorf("%+v != %+v", x.AnnouncedLen(), 4*19)
+	}
+	var rest strings.Builder
+	_, _ = io.Copy(&rest, r)
+	if rest.String() != " rest" {
+		t.Errorf("%q != %q", rest.String(), " rest")
+	}
+	_, err = new(Nat).ScanHex(strings.NewReader("12G4"))
+	if err == nil {
+		t.Errorf("expected error for invalid character")
+	}
+	x, err = new(Nat).ScanHex(strings.NewReader(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if x.AnnouncedLen() != 0 || x.EqZero() != 1 {
+		t.Errorf("expected empty input to produce zero")
+	}
+}
+
+func testSetStringMatchesBig(x Nat, base uint8) bool {
+	b := 2 + int(base%35)
+	str := x.Big().Text(b)
+	actual, err := new(Nat).SetString(str, b)
+	if err != nil {
+		return false
+	}
+	if !actual.checkInvariants() {
+		return false
+	}
+	return actual.Big().Cmp(x.Big()) == 0
+}
+
+func TestSetStringMatchesBig(t *testing.T) {
+	err := quick.Check(testSetStringMatchesBig, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSetStringExamples(t *testing.T) {
+	x, err := new(Nat).SetString("999", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := new(Nat).SetUint64(999)
+	if expected.Eq(x) != 1 {
+		t.Errorf("%+v != %+v", expected, x)
+	}
+	if x.AnnouncedLen() != 10 {
+		t.Errorf("%+v != %+v", x.AnnouncedLen(), 10)
+	}
+	x, err = new(Nat).SetString("ff", 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if x.AnnouncedLen() != 8 {
+		t.Errorf("%+v != %+v", x.AnnouncedLen(), 8)
+	}
+	for _, str := range []string{"0x1F", "0b11111", "0o37", "037", "31"} {
+		x, err = new(Nat).SetString(str, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected.SetUint64(31)
+		if expected.Eq(x) != 1 {
+			t.Errorf("%s: %+v != %+v", str, expected, x)
+		}
+	}
+	for _, str := range []string{"", "12a", "1_000", "0x", "-1"} {
+		_, err = new(Nat).SetString(str, 0)
+		if err == nil {
+			t.Errorf("expected error for %q", str)
+		}
+	}
+	_, err = new(Nat).SetString("12", 37)
+	if err == nil {
+		t.Errorf("expected error for base 37")
+	}
+}
+
+func TestSetHexFlexibleExamples(t *testing.T) {
+	expected, _ := new(Nat).SetHex("DEADBEEF0123")
+	for _, hex := range []string{
+		"DEADBEEF0123",
+		"0xdeadbeef0123",
+		"0XDeAdBeEf0123",
+		"de:ad:be:ef:01:23",
+		"dead beef\n0123\r\n",
+		"\tDE AD:BE EF 01 23",
+		"  0xdeadbeef0123\n",
+		"\r\n0XDEADBEEF0123",
+		"\vdead\fbeef\v0123\f",
+		"\f0x de ad be ef 01 23\v",
+	} {
+		actual, err := new(Nat).SetHexFlexible(hex)
+		if err != nil {
+			t.Errorf("%q: %v", hex, err)
+			continue
+		}
+		if !actual.checkInvariants() {
+			t.Errorf("%q: invariants broken", hex)
+		}
+		if expected.Eq(actual) != 1 || actual.AnnouncedLen() != expected.AnnouncedLen() {
+			t.Errorf("%q: %+v != %+v", hex, expected, actual)
+		}
+	}
+	for _, hex := range []string{"0xdeadbeeg", "de-ad", "x0123", "00x12", "de\u00e9ad", "0x 0x12"} {
+		if _, err := new(Nat).SetHexFlexible(hex); err == nil {
+			t.Errorf("%q: expected error", hex)
+		}
+	}
+}
+
 func TestDivEdgeCase(t *testing.T) {
 	x, _ := new(Nat).SetHex("B857C2BFBB8F9C8529B37228BE59017114876E17623A605308BFF084CBA97565BC97F9A2ED65895572B157AF6CADE2D7DD018772149E3216DA6D5B57EA703AF1598E23F3A79637C3072053427732C9E336AF983AB8FFD4F0AD08F042C8D3709FC6CC7247AE6C5D1181183FDBC4A1252D6B8C124FF50D6C72579AC2EC75F79FFD040F61F771D8E4116B40E595DB898A702DC99A882A37F091CDC897171921D744E5F2ACA5F466E4D9087B8D04E90CA99DBB259329C30CD925E046FFCB0CDB17FF2EB9C7475D4280C14711B1538F1282A2259348EAB246296D03051774D34D968329C336997EA4EEEBE9D8EE2EBAEBEF4B97076DF9431556F219DFEEFB58D9828E6AB9944C6717AD201331C8A12A11544389251E9A80388378F5B5596D129DDB5BC80F4D1AC993F0E6EF65AD7F832189DA2BDA0E642B6F1CDC539F07913FCFD65BCDE7D7CD2B7223D37B3666D58879B8EE61D61CE3683B6168F392B61A7C99F162C12138CD598770CC7604577E67B8A28C96AF7BDCB24CBD9B0E2801A2F122EFF7A21249C65BA49BD39B9F6B62BD4B0B16EBA1B8FC4AA2EFD03AD4D08AE17371D4B0A88020B77BCD072063DE9EB3F1FCC54FD2D35E587A424C7F62090E6A82B4839ED376BC572882E415F0A3277AF19E9A8BD4F19C69BA445ADAEAB178CE6952BE8140B0FACF0E7E045B9B8A54986481F8279D78048959FAB13B41AC11EB12AA4C")
 	nNat, _ := new(Nat).SetHex("D93C94E373D1B82924130A345FA7B8664AAFF9F335C0E6E79DCFEF49C88DC444885CA953F12BAA4A67B7B21C2FF6B4EECF6A750C76A456B2C800AFCBD0660CA03CB256A594C0D46B00118D6179F845D91EE0D4AFB2168E0FBFAB9958FE3A831950C8D8F402E4CD72C90128F1AE3BE986CE5FFD2EABC3363DE1EEB71BBC7245F4C78899301031803F0AE5B09C803E5E02E18FFA540202E65C29D1692058C34F34B9C9F42482E31436511B23A80F4642DB06BCE8E7C1B0A54E537418B411E4856277B9EC30C0103E1C7881E85F29AD6F7C27109DEEEC1676EE6A74E9641440A9E1095076CFBDD23FFF84A2C683EB19EBEE82811A8B6771CC7AF01DF85BA8A66FCD")
@@ -1249,3 +4903,19 @@ func TestRshExamples(t *testing.T) {
 		t.Errorf("%+v != %+v", expected, actual)
 	}
 }
+
+func TestInRangeExamples(t *testing.T) {
+	lo := new(Nat).SetUint64(10)
+	hi := new(Nat).SetUint64(20).Resize(128)
+	for _, x := range []uint64{0, 9, 10, 15, 20, 21} {
+		z := new(Nat).SetUint64(x)
+		expected := Choice(0)
+		if x >= 10 && x <= 20 {
+			expected = 1
+		}
+		actual := z.InRange(lo, hi)
+		if expected != actual {
+			t.Errorf("%d: %+v != %+v", x, expected, actual)
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package saferith
+
+import (
+	"errors"
+	"io"
+	"math/bits"
+	"math/rand"
+)
+
+// The odd primes below 100, used for trial division.
+var smallOddPrimes = []Word{
+	3, 5, 7, 11, 13, 17, 19, 23, 29, 31, 37, 41, 43, 47, 53, 59, 61, 67, 71, 73, 79, 83, 89, 97,
+}
+
+// Any number below this bound without small factors is prime.
+const smallPrimesBound = 101 * 101
+
+// remWord calculates x mod d, for a single word d.
+//
+// This will leak the value of x.
+func remWord(x []Word, d Word) Word {
+	var r Word
+	for i := len(x) - 1; i >= 0; i-- {
+		r = Word(bits.Rem(uint(r), uint(x[i]), uint(d)))
+	}
+	return r
+}
+
+// millerRabin checks if n passes a single round of the Miller-Rabin test with base a.
+//
+// nMinusOne should be n - 1, with nMinusOne = 2^s * d, with d odd.
+func millerRabin(n *Modulus, nMinusOne *Nat, d *Nat, s int, a *Nat) bool {
+	x := new(Nat).Exp(a, d, n)
+	if x.EqOne() == 1 || x.Eq(nMinusOne) == 1 {
+		return true
+	}
+	for i := 1; i < s; i++ {
+		x.ModMul(x, x, n)
+		if x.Eq(nMinusOne) == 1 {
+			return true
+		}
+		if x.EqOne() == 1 {
+			return false
+		}
+	}
+	return false
+}
+
+// ProbablyPrime checks if z is a prime number.
+//
+// Small factors are first checked for by trial division. After that, a Miller-Rabin
+// test with base 2 is performed, followed by rounds additional Miller-Rabin tests,
+// with pseudo-random bases chosen deterministically from the value of z.
+//
+// If z is prime, this always returns true. If z is composite and wasn't crafted
+// to fool this test, this returns true with probability at most 4^-rounds.
+// This is not suitable for checking numbers that an adversary might have chosen.
+//
+// This panics if rounds < 0.
+//
+// This function will leak the value of z, and isn't intended to be used
+// with secret numbers.
+func (z *Nat) ProbablyPrime(rounds int) bool {
+	if rounds < 0 {
+		panic("negative number of Miller-Rabin rounds")
+	}
+	limbs := z.limbs[:trueSize(z.limbs)]
+	if len(limbs) == 0 {
+		return false
+	}
+	if limbs[0]&1 == 0 {
+		return len(limbs) == 1 && limbs[0] == 2
+	}
+	for _, p := range smallOddPrimes {
+		if remWord(limbs, p) == 0 {
+			return len(limbs) == 1 && limbs[0] == p
+		}
+	}
+	if len(limbs) == 1 && limbs[0] < smallPrimesBound {
+		return limbs[0] > 1
+	}
+
+	n := ModulusFromNat(z)
+	one := new(Nat).SetUint64(1)
+	nMinusOne := new(Nat).Sub(&n.nat, one, n.BitLen())
+	s := 0
+	for _, limb := range nMinusOne.limbs {
+		if limb != 0 {
+			s += bits.TrailingZeros(uint(limb))
+			break
+		}
+		s += _W
+	}
+	d := new(Nat).Rsh(nMinusOne, uint(s), -1)
+
+	if !millerRabin(n, nMinusOne, d, s, new(Nat).SetUint64(2)) {
+		return false
+	}
+
+	// We pick bases in [2, n - 2], by reducing modulo n - 3, and then adding 2.
+	three := new(Nat).SetUint64(3)
+	nMinusThree := ModulusFromNat(new(Nat).Sub(&n.nat, three, n.BitLen()))
+	two := new(Nat).SetUint64(2)
+	rng := rand.New(rand.NewSource(int64(limbs[0])))
+	buf := make([]byte, (n.BitLen()+7)/8+8)
+	a := new(Nat)
+	for i := 0; i < rounds; i++ {
+		_, _ = rng.Read(buf)
+		a.SetBytes(buf)
+		a.Mod(a, nMinusThree)
+		a.Add(a, two, n.BitLen())
+		if !millerRabin(n, nMinusOne, d, s, a) {
+			return false
+		}
+	}
+	return true
+}
+
+// ProbablyPrime checks if z is a prime number.
+//
+// Primality is only defined for positive numbers, so this returns false if z
+// is negative, or zero, even if the absolute value of z is prime. Otherwise,
+// this behaves like Nat.ProbablyPrime, and panics if rounds < 0.
+//
+// This function will leak the value of z, including its sign, and isn't intended
+// to be used with secret numbers.
+func (z *Int) ProbablyPrime(rounds int) bool {
+	if rounds < 0 {
+		panic("negative number of Miller-Rabin rounds")
+	}
+	if z.sign == 1 {
+		return false
+	}
+	return z.abs.ProbablyPrime(rounds)
+}
+
+// The number of Miller-Rabin rounds used when generating primes.
+const generatePrimeRounds = 20
+
+// randomCandidate samples a random odd number with exactly bits bits, with the top two bits set.
+//
+// Setting the top two bits makes sure that the product of two such numbers has
+// exactly twice as many bits.
+func randomCandidate(rand io.Reader, bits int) (*Nat, error) {
+	buf := make([]byte, (bits+7)/8)
+	if _, err := io.ReadFull(rand, buf); err != nil {
+		return nil, err
+	}
+	z := new(Nat).SetBytes(buf).Resize(bits)
+	z.limbs[0] |= 1
+	z.limbs[(bits-1)/_W] |= 1 << ((bits - 1) % _W)
+	z.limbs[(bits-2)/_W] |= 1 << ((bits - 2) % _W)
+	return z, nil
+}
+
+// GeneratePrime generates a random prime number with exactly bits bits, using rand as a source of randomness.
+//
+// The top two bits of the prime are always set, so that the product of two such
+// primes has exactly 2 * bits bits. The number of bits must be at least 2.
+//
+// This function is not constant-time. Candidates are rejected until a prime is found,
+// and every candidate, including the prime returned, is checked with ProbablyPrime,
+// which leaks the value of the number it checks. This means that the value of the
+// prime returned leaks through timing, so this shouldn't be used where an attacker
+// can measure the time taken by this function.
+func GeneratePrime(rand io.Reader, bits int) (*Modulus, error) {
+	if bits < 2 {
+		return nil, errors.New("prime size must be at least 2 bits")
+	}
+	for {
+		p, err := randomCandidate(rand, bits)
+		if err != nil {
+			return nil, err
+		}
+		if p.ProbablyPrime(generatePrimeRounds) {
+			return ModulusFromNat(p), nil
+		}
+	}
+}
+
+// GenerateSafePrime generates a random safe prime with exactly bits bits, using rand as a source of randomness.
+//
+// A safe prime is a prime p, such that (p - 1) / 2 is also prime. The top two bits
+// of the prime are always set, like with GeneratePrime. The number of bits must be at least 3.
+//
+// This function is not constant-time. Candidates are rejected until a prime is found,
+// and every candidate, including the prime returned, is checked with ProbablyPrime,
+// which leaks the value of the number it checks. This means that the value of the
+// prime returned leaks through timing, so this shouldn't be used where an attacker
+// can measure the time taken by this function.
+func GenerateSafePrime(rand io.Reader, bits int) (*Modulus, error) {
+	if bits < 3 {
+		return nil, errors.New("safe prime size must be at least 3 bits")
+	}
+	one := new(Nat).SetUint64(1)
+	p := new(Nat)
+	for {
+		q, err := randomCandidate(rand, bits-1)
+		if err != nil {
+			return nil, err
+		}
+		// p = 2q + 1, which keeps the top two bits set
+		p.Lsh(q, 1, bits)
+		p.Add(p, one, bits)
+		// Checking p first, with trial division and a single base 2 Miller-Rabin test,
+		// rejects most candidates before running the full test on q.
+		if p.ProbablyPrime(0) && q.ProbablyPrime(generatePrimeRounds) && p.ProbablyPrime(generatePrimeRounds) {
+			return ModulusFromNat(p), nil
+		}
+	}
+}
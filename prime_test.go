@@ -0,0 +1,106 @@
+package saferith
+
+import (
+	"math/big"
+	"math/rand"
+	"testing"
+	"testing/quick"
+)
+
+func testProbablyPrimeMatchesBig(x Nat) bool {
+	return x.ProbablyPrime(20) == x.Big().ProbablyPrime(20)
+}
+
+func TestProbablyPrimeMatchesBig(t *testing.T) {
+	err := quick.Check(testProbablyPrimeMatchesBig, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestProbablyPrimeExamples(t *testing.T) {
+	for n := uint64(0); n < 20000; n++ {
+		expected := new(big.Int).SetUint64(n).ProbablyPrime(0)
+		actual := new(Nat).SetUint64(n).ProbablyPrime(0)
+		if expected != actual {
+			t.Errorf("%d: %+v != %+v", n, expected, actual)
+		}
+	}
+	// 2^127 - 1 is prime
+	x, _ := new(Nat).SetHex("7FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFF")
+	if !x.ProbablyPrime(20) {
+		t.Errorf("expected 2^127 - 1 to be prime")
+	}
+	// A Carmichael number
+	x.SetUint64(3825123056546413051)
+	if x.ProbablyPrime(20) {
+		t.Errorf("expected %v to be composite", x)
+	}
+}
+
+func testIntProbablyPrimeMatchesBig(x *Int) bool {
+	expected := x.Big().Sign() > 0 && x.Big().ProbablyPrime(20)
+	return x.ProbablyPrime(20) == expected
+}
+
+func TestIntProbablyPrimeMatchesBig(t *testing.T) {
+	err := quick.Check(testIntProbablyPrimeMatchesBig, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestIntProbablyPrimeExamples(t *testing.T) {
+	x := new(Int).SetUint64(7)
+	if !x.ProbablyPrime(20) {
+		t.Errorf("expected 7 to be prime")
+	}
+	x.Neg(1)
+	if x.ProbablyPrime(20) {
+		t.Errorf("expected -7 not to be prime")
+	}
+	x = new(Int).SetUint64(0)
+	if x.ProbablyPrime(20) || x.Neg(1).ProbablyPrime(20) {
+		t.Errorf("expected 0 not to be prime")
+	}
+}
+
+func TestGeneratePrime(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	for _, bits := range []int{2, 3, 17, 64, 127, 256} {
+		p, err := GeneratePrime(rng, bits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.BitLen() != bits {
+			t.Errorf("%+v != %+v", p.BitLen(), bits)
+		}
+		if !p.Big().ProbablyPrime(20) {
+			t.Errorf("%v is not prime", p)
+		}
+	}
+	if _, err := GeneratePrime(rng, 1); err == nil {
+		t.Errorf("expected error for 1 bit prime")
+	}
+}
+
+func TestGenerateSafePrime(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	for _, bits := range []int{3, 17, 64, 128} {
+		p, err := GenerateSafePrime(rng, bits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.BitLen() != bits {
+			t.Errorf("%+v != %+v", p.BitLen(), bits)
+		}
+		pBig := p.Big()
+		q := new(big.Int).Rsh(pBig, 1)
+		if !pBig.ProbablyPrime(20) || !q.ProbablyPrime(20) {
+			t.Errorf("%v is not a safe prime", p)
+		}
+	}
+	if _, err := GenerateSafePrime(rng, 2); err == nil {
+		t.Errorf("expected error for 2 bit safe prime")
+	}
+}
@@ -0,0 +1,296 @@
+package saferith
+
+import (
+	"errors"
+	"fmt"
+)
+
+// The largest absolute value allowed for the coefficient of a SolinasTerm.
+//
+// This makes sure that a coefficient fits in a single limb, on every platform.
+const maxSolinasCoefficient = 1<<31 - 1
+
+// SolinasTerm is a single term, Coefficient * 2^Exponent, in the description of a SolinasModulus.
+type SolinasTerm struct {
+	Coefficient int64
+	Exponent    int
+}
+
+// SolinasModulus is a prime with a special form, allowing for very fast reduction.
+//
+// Such a prime is p = 2^k + t_1 + ... + t_n, with each t_i a small multiple of a
+// power of two, and the sum of the terms much smaller than 2^k. Many standardized
+// primes have this form, for example:
+//
+//	secp256k1:  2^256 - 2^32 - 977
+//	P-256:      2^256 - 2^224 + 2^192 + 2^96 - 1
+//	Curve25519: 2^255 - 19
+//
+// Since 2^k = c mod p, with c = 2^k - p, reducing a number can be done by folding
+// the bits above 2^k back down, after multiplying them by c. Since c only has a few
+// terms, this only needs a few additions and subtractions, instead of a division.
+// Each fold removes k - log2(c) bits, so this works best when c is small, like for
+// secp256k1 and Curve25519. For P-256, c has 224 bits, so many folds would be needed,
+// and the word oriented reduction from FIPS 186 is used instead, for inputs of at
+// most 512 bits.
+//
+// A SolinasModulus is created with NewSolinasModulus.
+type SolinasModulus struct {
+	// The exponent of the leading power of two
+	k int
+	// The terms of c = 2^k - p, which are the negation of the terms describing p
+	terms []SolinasTerm
+	// The number of bits in c
+	cBits int
+	// The generic version of this modulus, for use with other operations
+	m *Modulus
+	// Whether or not this is the P-256 prime, which has a faster reduction
+	p256 bool
+}
+
+// NewSolinasModulus creates a new SolinasModulus, for the prime 2^k + terms[0] + ... + terms[n - 1].
+//
+// Each term needs a non-zero coefficient, with an absolute value smaller than 2^31,
+// and an exponent between 0 and k - 1. The sum of the terms needs to be negative, and
+// its absolute value needs to be smaller than 2^(k - 2). An error is returned otherwise.
+//
+// The primality of the result isn't checked, but reduction works for any modulus of this form.
+//
+// This leaks the description of the modulus, which should be public.
+func NewSolinasModulus(k int, terms []SolinasTerm) (*SolinasModulus, error) {
+	if k < 2 {
+		return nil, errors.New("leading exponent must be at least 2")
+	}
+	// We calculate c = -(terms[0] + ... + terms[n - 1]) in two parts
+	cPos := new(Nat)
+	cNeg := new(Nat)
+	negated := make([]SolinasTerm, len(terms))
+	for i, term := range terms {
+		if term.Coefficient == 0 || term.Coefficient > maxSolinasCoefficient || term.Coefficient < -maxSolinasCoefficient {
+			return nil, fmt.Errorf("invalid coefficient: %d", term.Coefficient)
+		}
+		if term.Exponent < 0 || term.Exponent >= k {
+			return nil, fmt.Errorf("invalid exponent: %d", term.Exponent)
+		}
+		negated[i] = SolinasTerm{Coefficient: -term.Coefficient, Exponent: term.Exponent}
+		if term.Coefficient < 0 {
+			shifted := new(Nat).Lsh(new(Nat).SetUint64(uint64(-term.Coefficient)), uint(term.Exponent), -1)
+			cPos.Add(cPos, shifted, -1)
+		} else {
+			shifted := new(Nat).Lsh(new(Nat).SetUint64(uint64(term.Coefficient)), uint(term.Exponent), -1)
+			cNeg.Add(cNeg, shifted, -1)
+		}
+	}
+	if cPos.CmpVartime(cNeg) <= 0 {
+		return nil, errors.New("sum of terms must be negative")
+	}
+	c := new(Nat).Sub(cPos, cNeg, cPos.AnnouncedLen())
+	cBits := c.TrueLen()
+	if cBits > k-2 {
+		return nil, errors.New("sum of terms is too large")
+	}
+	p := new(Nat).Lsh(new(Nat).SetUint64(1), uint(k), k+1)
+	p.Sub(p, c, k)
+	p256, _ := new(Nat).SetHex(p256Hex)
+	return &SolinasModulus{
+		k:     k,
+		terms: negated,
+		cBits: cBits,
+		m:     ModulusFromNat(p),
+		p256:  k == 256 && p.Eq(p256) == 1,
+	}, nil
+}
+
+// Modulus returns the generic Modulus with the same value.
+//
+// The results of Reduce are reduced modulo this Modulus, and can be used with
+// other operations directly.
+func (s *SolinasModulus) Modulus() *Modulus {
+	return s.m
+}
+
+// foldTerm calculates acc <- acc + coefficient * h * 2^exponent
+//
+// The caller needs to make sure that this doesn't overflow, or go negative,
+// and that acc has room for the shifted product.
+//
+// scratch needs at least len(h) + 1 limbs, and tmp as many limbs as acc.
+func foldTerm(acc, h, scratch, tmp []Word, term SolinasTerm) {
+	coefficient := term.Coefficient
+	if coefficient < 0 {
+		coefficient = -coefficient
+	}
+	product := scratch[:len(h)+1]
+	product[len(h)] = mulAddVWW(product[:len(h)], h, Word(coefficient), 0)
+
+	for i := 0; i < len(tmp); i++ {
+		tmp[i] = 0
+	}
+	limbShift := term.Exponent / _W
+	bitShift := uint(term.Exponent % _W)
+	shifted := tmp[limbShift : limbShift+len(product)+1]
+	copy(shifted, product)
+	shlVU(shifted, shifted, bitShift)
+
+	if term.Coefficient < 0 {
+		subVV(acc, acc, tmp)
+	} else {
+		addVV(acc, acc, tmp)
+	}
+}
+
+// Reduce calculates z <- x mod p, returning z.
+//
+// This is much faster than using Mod with the generic Modulus, only needing
+// a few additions and subtractions for each fold of the bits of x above 2^k.
+//
+// The limbs of z are used as scratch space, so reusing z across calls avoids allocating.
+//
+// This doesn't leak the value of x, only its announced length.
+//
+// The capacity of the resulting number matches the capacity of the modulus.
+func (s *SolinasModulus) Reduce(z *Nat, x *Nat) *Nat {
+	k := s.k
+	xLimbs := x.unaliasedLimbs(z)
+	// LEAK: the announced length of x
+	// OK: this is public
+	if s.p256 && x.announced <= 2*k {
+		return s.reduceP256(z, xLimbs)
+	}
+	n := x.announced
+	if n < k+1 {
+		n = k + 1
+	}
+	// The intermediate values can exceed n bits by the size of a coefficient, and
+	// the extra limbs leave room for that, as well as for shifting the terms.
+	width := limbCount(n) + 4
+	// We use the limbs of z as scratch space, with the result ending up at the start.
+	buf := z.resizedLimbs(_W * (4*width + 1))
+	acc := buf[:width]
+	for i := 0; i < len(acc); i++ {
+		acc[i] = 0
+	}
+	copy(acc, xLimbs)
+	h := buf[width : 2*width]
+	tmp := buf[2*width : 3*width]
+	scratch := buf[3*width:]
+
+	// Write acc = h * 2^k + l. Since 2^k = c mod p, acc = l + h * c mod p, and this
+	// is smaller when acc has more than k + 1 bits. Since c has at most k - 2 bits,
+	// the length decreases by at least 1 each time.
+	for n > k+1 {
+		hBits := n - k
+		hLimbs := h[:limbCount(hBits)]
+		for i := 0; i < len(h); i++ {
+			h[i] = 0
+		}
+		copy(h, acc[k/_W:])
+		if k%_W > 0 {
+			shrVU(h, h, uint(k%_W))
+		}
+		maskEnd(hLimbs, hBits)
+		// Keep only the lower k bits of acc
+		for i := limbCount(k); i < len(acc); i++ {
+			acc[i] = 0
+		}
+		maskEnd(acc[:limbCount(k)], k)
+
+		// Add in the positive terms of c first, so that the intermediate result
+		// never goes negative, since c > 0.
+		for _, term := range s.terms {
+			if term.Coefficient > 0 {
+				foldTerm(acc, hLimbs, scratch, tmp, term)
+			}
+		}
+		for _, term := range s.terms {
+			if term.Coefficient < 0 {
+				foldTerm(acc, hLimbs, scratch, tmp, term)
+			}
+		}
+		// acc < 2^k + h * c < 2^k + 2^(n - k + cBits)
+		if n-k+s.cBits > k {
+			n = n - k + s.cBits + 1
+		} else {
+			n = k + 1
+		}
+	}
+
+	// Now, acc < 2^(k + 1), and p > 2^k - 2^(k - 2), so acc < 3p
+	pPadded := tmp
+	for i := 0; i < len(pPadded); i++ {
+		pPadded[i] = 0
+	}
+	copy(pPadded, s.m.nat.limbs)
+	for i := 0; i < 2; i++ {
+		borrow := subVV(scratch[:width], acc, pPadded)
+		ctCondCopy(1^Choice(borrow), acc, scratch[:width])
+	}
+
+	z.limbs = acc[:len(s.m.nat.limbs)]
+	z.announced = s.m.nat.announced
+	z.reduced = s.m
+	return z
+}
+
+// The P-256 prime, 2^256 - 2^224 + 2^192 + 2^96 - 1
+const p256Hex = "FFFFFFFF00000001000000000000000000000000FFFFFFFFFFFFFFFFFFFFFFFF"
+
+// The 32 bit words of 5p, for the P-256 prime p, starting with the least significant
+var p256FiveP = [9]int64{0xFFFFFFFB, 0xFFFFFFFF, 0xFFFFFFFF, 4, 0, 0, 5, 0xFFFFFFFB, 4}
+
+// reduceP256 sets z <- x mod p, for the P-256 prime p, and x of at most 512 bits.
+//
+// This follows the reduction in FIPS 186, Appendix D.2.3. Writing x with 32 bit
+// words, the result is a sum of 9 numbers made from these words, some of them
+// doubled or negated, which is calculated one column of words at a time.
+func (s *SolinasModulus) reduceP256(z *Nat, xLimbs []Word) *Nat {
+	var a [16]int64
+	for i := 0; i < 16; i++ {
+		// LEAK: the number of limbs in x
+		// OK: this is public
+		if j := i * 32 / _W; j < len(xLimbs) {
+			a[i] = int64(uint32(xLimbs[j] >> (uint(i*32) % _W)))
+		}
+	}
+	// The sum can be negative, but adding 5p keeps it positive, and below 16p.
+	columns := [9]int64{
+		a[0] + a[8] + a[9] - a[11] - a[12] - a[13] - a[14],
+		a[1] + a[9] + a[10] - a[12] - a[13] - a[14] - a[15],
+		a[2] + a[10] + a[11] - a[13] - a[14] - a[15],
+		a[3] + 2*a[11] + 2*a[12] + a[13] - a[15] - a[8] - a[9],
+		a[4] + 2*a[12] + 2*a[13] + a[14] - a[9] - a[10],
+		a[5] + 2*a[13] + 2*a[14] + a[15] - a[10] - a[11],
+		a[6] + 3*a[14] + 2*a[15] + a[13] - a[8] - a[9],
+		a[7] + 3*a[15] + a[8] - a[10] - a[11] - a[12] - a[13],
+		0,
+	}
+
+	size := len(s.m.nat.limbs)
+	// We use the limbs of z as scratch space, with the result ending up at the start.
+	buf := z.resizedLimbs(_W * 3 * (size + 1))
+	acc := buf[:size+1]
+	shifted := buf[size+1 : 2*(size+1)]
+	scratch := buf[2*(size+1):]
+	for i := 0; i < len(acc); i++ {
+		acc[i] = 0
+	}
+	var carry int64
+	for i := 0; i < 9; i++ {
+		v := columns[i] + p256FiveP[i] + carry
+		acc[i*32/_W] |= Word(uint32(v)) << (uint(i*32) % _W)
+		// This shift is arithmetic, so negative columns borrow from the next one
+		carry = v >> 32
+	}
+
+	// acc < 16p, so subtracting 8p, 4p, 2p, and then p, when possible, reduces it
+	for j := 3; j >= 0; j-- {
+		shifted[size] = shlVU(shifted[:size], s.m.nat.limbs, uint(j))
+		borrow := subVV(scratch, acc, shifted)
+		ctCondCopy(1^Choice(borrow), acc, scratch)
+	}
+
+	z.limbs = acc[:size]
+	z.announced = s.m.nat.announced
+	z.reduced = s.m
+	return z
+}
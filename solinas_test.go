@@ -0,0 +1,159 @@
+package saferith
+
+import (
+	"math/big"
+	"testing"
+	"testing/quick"
+)
+
+func solinasSecp256k1() *SolinasModulus {
+	s, _ := NewSolinasModulus(256, []SolinasTerm{{-1, 32}, {-977, 0}})
+	return s
+}
+
+func solinasP256() *SolinasModulus {
+	s, _ := NewSolinasModulus(256, []SolinasTerm{{-1, 224}, {1, 192}, {1, 96}, {-1, 0}})
+	return s
+}
+
+func solinas25519() *SolinasModulus {
+	s, _ := NewSolinasModulus(255, []SolinasTerm{{-19, 0}})
+	return s
+}
+
+func testSolinasReduceMatchesMod(x Nat) bool {
+	// Make the input larger than the product of two reduced numbers, as well as smaller
+	wide := new(Nat).Mul(&x, &x, -1)
+	for _, s := range []*SolinasModulus{solinasSecp256k1(), solinasP256(), solinas25519()} {
+		for _, input := range []*Nat{&x, wide} {
+			expected := new(Nat).Mod(input, s.Modulus())
+			actual := s.Reduce(new(Nat), input)
+			if !actual.checkInvariants() || actual.reduced != s.Modulus() || actual.Eq(expected) != 1 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func TestSolinasReduceMatchesMod(t *testing.T) {
+	err := quick.Check(testSolinasReduceMatchesMod, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSolinasModulusExamples(t *testing.T) {
+	// The values of the standard primes
+	expected, _ := ModulusFromHex("FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFEFFFFFC2F")
+	if solinasSecp256k1().Modulus().EqualVartime(expected) != true {
+		t.Errorf("%+v != %+v", expected, solinasSecp256k1().Modulus())
+	}
+	expected = modulus256()
+	if solinasP256().Modulus().EqualVartime(expected) != true {
+		t.Errorf("%+v != %+v", expected, solinasP256().Modulus())
+	}
+	expected, _ = ModulusFromHex("7FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFED")
+	if solinas25519().Modulus().EqualVartime(expected) != true {
+		t.Errorf("%+v != %+v", expected, solinas25519().Modulus())
+	}
+	// Small moduli, with every input of a few bytes
+	for _, terms := range [][]SolinasTerm{{{-5, 0}}, {{-1, 4}, {-1, 0}}, {{-1, 5}, {1, 2}}, {{-63, 0}}} {
+		s, err := NewSolinasModulus(8, terms)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := uint64(0); i < 1<<16; i += 7 {
+			x := new(Nat).SetUint64(i).Resize(16)
+			expected := new(Nat).Mod(x, s.Modulus())
+			actual := s.Reduce(x, x)
+			if actual.Eq(expected) != 1 {
+				t.Errorf("%+v != %+v", expected, actual)
+			}
+		}
+	}
+	// The largest allowed value for c
+	s, err := NewSolinasModulus(64, []SolinasTerm{{-1, 62}, {1, 0}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	x := new(Nat).SetBytes([]byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF})
+	if s.Reduce(new(Nat), x).Eq(new(Nat).Mod(x, s.Modulus())) != 1 {
+		t.Errorf("mismatch with the largest allowed value of c")
+	}
+}
+
+func TestSolinasReduceP256Examples(t *testing.T) {
+	s := solinasP256()
+	if !s.p256 {
+		t.Fatalf("expected the P-256 prime to be detected")
+	}
+	// The terms can be given in any order
+	reordered, _ := NewSolinasModulus(256, []SolinasTerm{{-1, 0}, {1, 96}, {1, 192}, {-1, 224}})
+	if !reordered.p256 {
+		t.Errorf("expected the P-256 prime to be detected, with the terms reordered")
+	}
+	p := s.Modulus().Big()
+	one := big.NewInt(1)
+	pMinusOne := new(big.Int).Sub(p, one)
+	max512 := new(big.Int).Sub(new(big.Int).Lsh(one, 512), one)
+	// Inputs making some of the columns as negative, or as positive, as possible
+	negative := new(big.Int).Lsh(new(big.Int).Sub(new(big.Int).Lsh(one, 256), one), 256)
+	positive := new(big.Int).Sub(new(big.Int).Lsh(one, 256), one)
+	examples := []*big.Int{
+		big.NewInt(0),
+		one,
+		pMinusOne,
+		p,
+		new(big.Int).Add(p, one),
+		new(big.Int).Mul(pMinusOne, pMinusOne),
+		new(big.Int).Mul(p, p),
+		max512,
+		negative,
+		positive,
+	}
+	for _, example := range examples {
+		expected := new(big.Int).Mod(example, p)
+		for _, bits := range []int{example.BitLen(), 257, 512} {
+			if example.BitLen() > bits {
+				continue
+			}
+			x := new(Nat).SetBig(example, bits)
+			actual := s.Reduce(new(Nat), x)
+			if !actual.checkInvariants() || actual.Big().Cmp(expected) != 0 {
+				t.Errorf("%v mod p: %+v != %+v", example, expected, actual)
+			}
+			// Reducing in place should work too
+			actual = s.Reduce(x, x)
+			if actual.Big().Cmp(expected) != 0 {
+				t.Errorf("%v mod p, in place: %+v != %+v", example, expected, actual)
+			}
+		}
+	}
+	// Only the P-256 prime uses this reduction
+	if solinasSecp256k1().p256 || solinas25519().p256 {
+		t.Errorf("expected only the P-256 prime to be detected")
+	}
+}
+
+func TestNewSolinasModulusErrors(t *testing.T) {
+	invalid := []struct {
+		k     int
+		terms []SolinasTerm
+	}{
+		{1, []SolinasTerm{{-1, 0}}},
+		{256, nil},
+		{256, []SolinasTerm{{1, 0}}},
+		{256, []SolinasTerm{{0, 0}}},
+		{256, []SolinasTerm{{-1, 256}}},
+		{256, []SolinasTerm{{-1, -1}}},
+		{256, []SolinasTerm{{-1 << 31, 0}}},
+		{256, []SolinasTerm{{-1, 255}}},
+		{256, []SolinasTerm{{-1, 10}, {1, 10}}},
+	}
+	for _, example := range invalid {
+		if _, err := NewSolinasModulus(example.k, example.terms); err == nil {
+			t.Errorf("expected an error for 2^%d + %+v", example.k, example.terms)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package saferith
+
+// WordBits is the number of bits in a Word.
+const WordBits = _W
+
+// AddWords calculates z <- x + y, returning the carry, which is 0 or 1.
+//
+// The slices are little endian, i.e. the least significant Word comes first,
+// like the limbs of a Nat. z, x, and y must all have the same length, and
+// this function will panic otherwise. z may alias x or y.
+//
+// This uses the same constant-time routines as the rest of this package,
+// only leaking the length of the slices.
+func AddWords(z, x, y []Word) Word {
+	if len(x) != len(z) || len(y) != len(z) {
+		panic("AddWords: mismatched arguments")
+	}
+	return addVV(z, x, y)
+}
+
+// SubWords calculates z <- x - y, returning the borrow, which is 0 or 1.
+//
+// The slices are little endian, i.e. the least significant Word comes first,
+// like the limbs of a Nat. z, x, and y must all have the same length, and
+// this function will panic otherwise. z may alias x or y.
+//
+// This uses the same constant-time routines as the rest of this package,
+// only leaking the length of the slices.
+func SubWords(z, x, y []Word) Word {
+	if len(x) != len(z) || len(y) != len(z) {
+		panic("SubWords: mismatched arguments")
+	}
+	return subVV(z, x, y)
+}
+
+// MulAddWord calculates z <- z + x * y, returning the Word carried out of z.
+//
+// The slices are little endian, i.e. the least significant Word comes first,
+// like the limbs of a Nat. z and x must have the same length, and this function
+// will panic otherwise. z may alias x.
+//
+// This is the basic building block for schoolbook multiplication, and Montgomery
+// reduction: multiplying by a multi-word y is done by calling this function
+// for each Word of y, on successive windows of the output.
+//
+// This uses the same constant-time routines as the rest of this package,
+// only leaking the length of the slices.
+func MulAddWord(z, x []Word, y Word) Word {
+	if len(x) != len(z) {
+		panic("MulAddWord: mismatched arguments")
+	}
+	return addMulVVW(z, x, y)
+}
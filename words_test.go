@@ -0,0 +1,103 @@
+package saferith
+
+import (
+	"math/big"
+	"testing"
+	"testing/quick"
+)
+
+// wordsBig interprets a little endian slice of Words as a big.Int
+func wordsBig(x []Word) *big.Int {
+	out := new(big.Int)
+	for i := len(x) - 1; i >= 0; i-- {
+		out.Lsh(out, _W)
+		out.Or(out, new(big.Int).SetUint64(uint64(x[i])))
+	}
+	return out
+}
+
+// sameLength truncates x and y to have the same length
+func sameLength(x, y []Word) ([]Word, []Word) {
+	if len(x) < len(y) {
+		return x, y[:len(x)]
+	}
+	return x[:len(y)], y
+}
+
+func testAddWordsMatchesBig(x, y []Word) bool {
+	x, y = sameLength(x, y)
+	z := make([]Word, len(x))
+	c := AddWords(z, x, y)
+	expected := new(big.Int).Add(wordsBig(x), wordsBig(y))
+	actual := new(big.Int).Lsh(big.NewInt(int64(c)), uint(_W*len(z)))
+	actual.Add(actual, wordsBig(z))
+	return expected.Cmp(actual) == 0
+}
+
+func TestAddWordsMatchesBig(t *testing.T) {
+	err := quick.Check(testAddWordsMatchesBig, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func testSubWordsMatchesBig(x, y []Word) bool {
+	x, y = sameLength(x, y)
+	z := make([]Word, len(x))
+	c := SubWords(z, x, y)
+	expected := new(big.Int).Sub(wordsBig(x), wordsBig(y))
+	actual := new(big.Int).Lsh(big.NewInt(int64(c)), uint(_W*len(z)))
+	actual.Sub(wordsBig(z), actual)
+	return expected.Cmp(actual) == 0
+}
+
+func TestSubWordsMatchesBig(t *testing.T) {
+	err := quick.Check(testSubWordsMatchesBig, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func testMulAddWordMatchesBig(z, x []Word, y Word) bool {
+	z, x = sameLength(z, x)
+	expected := new(big.Int).Mul(wordsBig(x), new(big.Int).SetUint64(uint64(y)))
+	expected.Add(expected, wordsBig(z))
+	c := MulAddWord(z, x, y)
+	actual := new(big.Int).Lsh(new(big.Int).SetUint64(uint64(c)), uint(_W*len(z)))
+	actual.Add(actual, wordsBig(z))
+	return expected.Cmp(actual) == 0
+}
+
+func TestMulAddWordMatchesBig(t *testing.T) {
+	err := quick.Check(testMulAddWordMatchesBig, &quick.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestAddWordsExamples(t *testing.T) {
+	x := []Word{^Word(0), ^Word(0)}
+	y := []Word{1, 0}
+	expected := []Word{0, 0}
+	c := AddWords(x, x, y)
+	if c != 1 || cmpEq(expected, x) != 1 {
+		t.Errorf("%+v != %+v", expected, x)
+	}
+	c = SubWords(x, x, y)
+	expected = []Word{^Word(0), ^Word(0)}
+	if c != 1 || cmpEq(expected, x) != 1 {
+		t.Errorf("%+v != %+v", expected, x)
+	}
+	if WordBits != _W {
+		t.Errorf("%+v != %+v", WordBits, _W)
+	}
+}
+
+func TestAddWordsPanicsOnMismatch(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected a panic")
+		}
+	}()
+	AddWords(make([]Word, 2), make([]Word, 2), make([]Word, 1))
+}